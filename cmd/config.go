@@ -0,0 +1,495 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"auto-message-sender/infra/sender"
+	"auto-message-sender/internal/models"
+	"auto-message-sender/internal/schedule"
+	"auto-message-sender/internal/services"
+)
+
+// getScheduleFromEnv reads when the auto sender may send, e.g.
+// SENDER_TIMEZONE="Europe/Istanbul" SENDER_WINDOWS="Mon-Fri 09:00-21:00".
+// When SENDER_CRON is set batches run at the cron times and SENDER_INTERVAL is
+// not used.
+func getScheduleFromEnv() (*schedule.Schedule, error) {
+	timezone := os.Getenv("SENDER_TIMEZONE")
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SENDER_TIMEZONE value %q: %w", timezone, err)
+	}
+	return schedule.New(os.Getenv("SENDER_CRON"), os.Getenv("SENDER_WINDOWS"), os.Getenv("SENDER_BLACKOUTS"), location)
+}
+
+// getSenderConfigFromEnv reads the auto sender config, the interval, batch size and
+// concurrency can be changed later on a running sender with PUT /sender/config
+func getSenderConfigFromEnv() (models.SenderConfig, error) {
+	var err error
+	var config models.SenderConfig
+	config.InitialDelay, err = getDurationFromEnv("SENDER_INITIAL_DELAY", time.Second)
+	if err != nil {
+		return models.SenderConfig{}, err
+	}
+	config.Interval, err = getDurationFromEnv("SENDER_INTERVAL", 2*time.Minute)
+	if err != nil {
+		return models.SenderConfig{}, err
+	}
+	config.BatchSize, err = getIntFromEnv("SENDER_BATCH_SIZE", 2)
+	if err != nil {
+		return models.SenderConfig{}, err
+	}
+	config.Concurrency, err = getIntFromEnv("SENDER_CONCURRENCY", 1)
+	if err != nil {
+		return models.SenderConfig{}, err
+	}
+	err = config.Validate()
+	if err != nil {
+		return models.SenderConfig{}, err
+	}
+	return config, nil
+}
+
+type leaseConfig struct {
+	InstanceID     string
+	LeaseTimeout   time.Duration
+	ReaperInterval time.Duration
+}
+
+// getLeaseConfigFromEnv reads the lease settings, a lease must outlast maxSendTime
+func getLeaseConfigFromEnv(maxSendTime time.Duration) (leaseConfig, error) {
+	var err error
+	config := leaseConfig{
+		InstanceID: os.Getenv("INSTANCE_ID"),
+	}
+	if config.InstanceID == "" {
+		config.InstanceID = defaultInstanceID()
+	}
+	config.LeaseTimeout, err = getDurationFromEnv("LEASE_TIMEOUT", 5*time.Minute)
+	if err != nil {
+		return leaseConfig{}, err
+	}
+	config.ReaperInterval, err = getDurationFromEnv("LEASE_REAPER_INTERVAL", time.Minute)
+	if err != nil {
+		return leaseConfig{}, err
+	}
+	if config.LeaseTimeout <= 0 || config.ReaperInterval <= 0 {
+		return leaseConfig{}, fmt.Errorf("LEASE_TIMEOUT and LEASE_REAPER_INTERVAL must be positive")
+	}
+	if config.LeaseTimeout <= maxSendTime {
+		return leaseConfig{}, fmt.Errorf("LEASE_TIMEOUT must be longer than the longest send of a message %s: %s", maxSendTime, config.LeaseTimeout)
+	}
+	return config, nil
+}
+
+// getRetryPolicyFromEnv reads how failed messages are retried, MAX_SEND_ATTEMPTS
+// also limits how many times a message with an expired lease is handed out again
+func getRetryPolicyFromEnv() (services.RetryPolicy, error) {
+	var err error
+	var policy services.RetryPolicy
+	policy.MaxAttempts, err = getIntFromEnv("MAX_SEND_ATTEMPTS", 5)
+	if err != nil {
+		return services.RetryPolicy{}, err
+	}
+	policy.BaseDelay, err = getDurationFromEnv("RETRY_BASE_DELAY", 30*time.Second)
+	if err != nil {
+		return services.RetryPolicy{}, err
+	}
+	policy.MaxDelay, err = getDurationFromEnv("RETRY_MAX_DELAY", 30*time.Minute)
+	if err != nil {
+		return services.RetryPolicy{}, err
+	}
+	err = policy.Validate()
+	if err != nil {
+		return services.RetryPolicy{}, err
+	}
+	return policy, nil
+}
+
+// getRateLimitConfigFromEnv reads the outbound rate limits, both the provider
+// rate limit and the recipient limit are disabled by default
+func getRateLimitConfigFromEnv() (sender.RateLimitConfig, error) {
+	var err error
+	var config sender.RateLimitConfig
+	config.RequestsPerSecond, err = getFloatFromEnv("RATE_LIMIT_RPS", 0)
+	if err != nil {
+		return sender.RateLimitConfig{}, err
+	}
+	config.Burst, err = getIntFromEnv("RATE_LIMIT_BURST", max(1, int(math.Ceil(config.RequestsPerSecond))))
+	if err != nil {
+		return sender.RateLimitConfig{}, err
+	}
+	config.MaxWait, err = getDurationFromEnv("RATE_LIMIT_MAX_WAIT", time.Second)
+	if err != nil {
+		return sender.RateLimitConfig{}, err
+	}
+	config.RecipientLimit, err = getIntFromEnv("RATE_LIMIT_PER_RECIPIENT", 0)
+	if err != nil {
+		return sender.RateLimitConfig{}, err
+	}
+	config.RecipientWindow, err = getDurationFromEnv("RATE_LIMIT_RECIPIENT_WINDOW", time.Hour)
+	if err != nil {
+		return sender.RateLimitConfig{}, err
+	}
+	config.Mode = os.Getenv("RATE_LIMIT_MODE")
+	if config.Mode == "" {
+		config.Mode = sender.RateLimitModeDefer
+	}
+	err = config.Validate()
+	if err != nil {
+		return sender.RateLimitConfig{}, err
+	}
+	return config, nil
+}
+
+// getCircuitBreakerConfigFromEnv reads when the webhook circuit breaker opens,
+// CIRCUIT_BREAKER_FAILURE_THRESHOLD="0" disables it
+func getCircuitBreakerConfigFromEnv() (sender.CircuitBreakerConfig, error) {
+	var err error
+	config := sender.CircuitBreakerConfig{
+		Name: "webhook",
+	}
+	config.FailureThreshold, err = getIntFromEnv("CIRCUIT_BREAKER_FAILURE_THRESHOLD", 5)
+	if err != nil {
+		return sender.CircuitBreakerConfig{}, err
+	}
+	config.OpenTimeout, err = getDurationFromEnv("CIRCUIT_BREAKER_OPEN_TIMEOUT", 30*time.Second)
+	if err != nil {
+		return sender.CircuitBreakerConfig{}, err
+	}
+	config.HalfOpenSuccesses, err = getIntFromEnv("CIRCUIT_BREAKER_HALF_OPEN_SUCCESSES", 1)
+	if err != nil {
+		return sender.CircuitBreakerConfig{}, err
+	}
+	err = config.Validate()
+	if err != nil {
+		return sender.CircuitBreakerConfig{}, err
+	}
+	return config, nil
+}
+
+// getCacheRetentionPolicyFromEnv reads how long and how many sent messages are
+// kept in the cache, CACHE_TTL="0" and CACHE_MAX_ENTRIES="0" disable the limits
+func getCacheRetentionPolicyFromEnv() (models.CacheRetentionPolicy, error) {
+	var err error
+	var policy models.CacheRetentionPolicy
+	policy.TTL, err = getDurationFromEnv("CACHE_TTL", 7*24*time.Hour)
+	if err != nil {
+		return models.CacheRetentionPolicy{}, err
+	}
+	policy.MaxEntries, err = getIntFromEnv("CACHE_MAX_ENTRIES", 100000)
+	if err != nil {
+		return models.CacheRetentionPolicy{}, err
+	}
+	policy.TrimInterval, err = getDurationFromEnv("CACHE_TRIM_INTERVAL", time.Minute)
+	if err != nil {
+		return models.CacheRetentionPolicy{}, err
+	}
+	err = policy.Validate()
+	if err != nil {
+		return models.CacheRetentionPolicy{}, err
+	}
+	return policy, nil
+}
+
+// getMessageCacheTTLFromEnv reads how long the snapshot of a looked up message is
+// cached, changes that are not made through the API are visible after that
+func getMessageCacheTTLFromEnv() (time.Duration, error) {
+	ttl, err := getDurationFromEnv("MESSAGE_CACHE_TTL", time.Minute)
+	if err != nil {
+		return 0, err
+	}
+	if ttl <= 0 {
+		return 0, fmt.Errorf("MESSAGE_CACHE_TTL must be positive: %s", ttl)
+	}
+	return ttl, nil
+}
+
+type webhookEndpoint struct {
+	Name     string
+	URL      string
+	Priority int
+	Weight   int
+}
+
+// getWebhookEndpointsFromEnv reads the upstream webhook endpoints separated by
+// ";", each one is "<name> <url> [priority=<n>] [weight=<n>]", e.g.
+// WEBHOOK_ENDPOINTS="primary https://a.example.com/send weight=3;backup https://b.example.com/send priority=1".
+// WEBHOOK_SITE_URL is the only endpoint if it is not set.
+func getWebhookEndpointsFromEnv() ([]webhookEndpoint, error) {
+	value := os.Getenv("WEBHOOK_ENDPOINTS")
+	if value == "" {
+		webhookSiteURL := getWebhookSiteURLFromEnv()
+		if webhookSiteURL == "" {
+			return nil, errors.New("WEBHOOK_SITE_URL or WEBHOOK_ENDPOINTS must be set")
+		}
+		return []webhookEndpoint{{Name: "webhook", URL: webhookSiteURL}}, nil
+	}
+	var endpoints []webhookEndpoint
+	for entry := range strings.SplitSeq(value, ";") {
+		fields := strings.Fields(entry)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("invalid WEBHOOK_ENDPOINTS entry %q, \"<name> <url>\" expected", entry)
+		}
+		endpoint := webhookEndpoint{Name: fields[0], URL: fields[1]}
+		for _, option := range fields[2:] {
+			key, number, ok := strings.Cut(option, "=")
+			value, err := strconv.Atoi(number)
+			if !ok || err != nil {
+				return nil, fmt.Errorf("invalid WEBHOOK_ENDPOINTS option %q of %s", option, endpoint.Name)
+			}
+			switch key {
+			case "priority":
+				endpoint.Priority = value
+			case "weight":
+				endpoint.Weight = value
+			default:
+				return nil, fmt.Errorf("unknown WEBHOOK_ENDPOINTS option %q of %s", option, endpoint.Name)
+			}
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints, nil
+}
+
+// getFailoverConfigFromEnv reads when a webhook endpoint is taken out of rotation,
+// FAILOVER_ERROR_RATE_THRESHOLD="0" keeps every endpoint in rotation
+func getFailoverConfigFromEnv() (sender.FailoverConfig, error) {
+	var err error
+	var config sender.FailoverConfig
+	config.ErrorRateThreshold, err = getFloatFromEnv("FAILOVER_ERROR_RATE_THRESHOLD", 0.5)
+	if err != nil {
+		return sender.FailoverConfig{}, err
+	}
+	config.MinRequests, err = getIntFromEnv("FAILOVER_MIN_REQUESTS", 10)
+	if err != nil {
+		return sender.FailoverConfig{}, err
+	}
+	config.Window, err = getDurationFromEnv("FAILOVER_WINDOW", time.Minute)
+	if err != nil {
+		return sender.FailoverConfig{}, err
+	}
+	config.EjectionTime, err = getDurationFromEnv("FAILOVER_EJECTION_TIME", 30*time.Second)
+	if err != nil {
+		return sender.FailoverConfig{}, err
+	}
+	err = config.Validate()
+	if err != nil {
+		return sender.FailoverConfig{}, err
+	}
+	return config, nil
+}
+
+// getSuccessStatusCodesFromEnv reads the webhook response codes that mean a
+// message was accepted, e.g. WEBHOOK_SUCCESS_STATUS_CODES="200,202". Any 2xx
+// code is accepted if it is not set.
+func getSuccessStatusCodesFromEnv() ([]int, error) {
+	value := os.Getenv("WEBHOOK_SUCCESS_STATUS_CODES")
+	if value == "" {
+		return nil, nil
+	}
+	var statusCodes []int
+	for part := range strings.SplitSeq(value, ",") {
+		statusCode, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || statusCode < 100 || statusCode > 599 {
+			return nil, fmt.Errorf("invalid WEBHOOK_SUCCESS_STATUS_CODES value %q", value)
+		}
+		statusCodes = append(statusCodes, statusCode)
+	}
+	return statusCodes, nil
+}
+
+// getWebhookAuthenticatorsFromEnv reads the outbound webhook authentication, a
+// bearer token or basic auth, static headers separated by ";" e.g.
+// WEBHOOK_HEADERS="X-Api-Key: secret" and an HMAC-SHA256 request signature
+func getWebhookAuthenticatorsFromEnv() ([]sender.RequestAuthenticator, error) {
+	var authenticators []sender.RequestAuthenticator
+	bearerToken := os.Getenv("WEBHOOK_AUTH_BEARER_TOKEN")
+	username := os.Getenv("WEBHOOK_AUTH_BASIC_USERNAME")
+	password := os.Getenv("WEBHOOK_AUTH_BASIC_PASSWORD")
+	if bearerToken != "" && (username != "" || password != "") {
+		return nil, errors.New("WEBHOOK_AUTH_BEARER_TOKEN and WEBHOOK_AUTH_BASIC_USERNAME can not be used together")
+	}
+	if bearerToken != "" {
+		authenticators = append(authenticators, sender.BearerTokenAuthenticator{Token: bearerToken})
+	}
+	if username != "" || password != "" {
+		authenticators = append(authenticators, sender.BasicAuthenticator{Username: username, Password: password})
+	}
+	headers, err := getHeadersFromEnv("WEBHOOK_HEADERS")
+	if err != nil {
+		return nil, err
+	}
+	if len(headers) > 0 {
+		authenticators = append(authenticators, sender.HeaderAuthenticator{Headers: headers})
+	}
+	if secret := os.Getenv("WEBHOOK_HMAC_SECRET"); secret != "" {
+		hmacAuthenticator := sender.NewHMACAuthenticator([]byte(secret))
+		if header := os.Getenv("WEBHOOK_HMAC_HEADER"); header != "" {
+			hmacAuthenticator.SignatureHeader = header
+		}
+		if header := os.Getenv("WEBHOOK_HMAC_TIMESTAMP_HEADER"); header != "" {
+			hmacAuthenticator.TimestampHeader = header
+		}
+		authenticators = append(authenticators, hmacAuthenticator)
+	}
+	return authenticators, nil
+}
+
+// getDeliveryReportVerifierFromEnv returns nil when DELIVERY_REPORT_SECRET is not
+// set, the delivery report callback is disabled then
+func getDeliveryReportVerifierFromEnv() (*sender.HMACVerifier, error) {
+	secret := os.Getenv("DELIVERY_REPORT_SECRET")
+	if secret == "" {
+		return nil, nil
+	}
+	maxAge, err := getDurationFromEnv("DELIVERY_REPORT_MAX_AGE", 5*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	if maxAge <= 0 {
+		return nil, fmt.Errorf("DELIVERY_REPORT_MAX_AGE must be positive: %s", maxAge)
+	}
+	return sender.NewHMACVerifier([]byte(secret), maxAge), nil
+}
+
+// getProviderAdapterFromEnv reads the request format of the provider behind
+// WEBHOOK_SITE_URL and returns the adapter with its name, PROVIDER_SENDER is
+// required by the SMS gateway formats
+func getProviderAdapterFromEnv() (sender.ProviderAdapter, string, error) {
+	name := os.Getenv("PROVIDER_ADAPTER")
+	if name == "" {
+		name = sender.DefaultProviderAdapter
+	}
+	config := sender.ProviderAdapterConfig{
+		Sender: os.Getenv("PROVIDER_SENDER"),
+	}
+	if name == "template" {
+		var err error
+		config.Template, err = getTemplateAdapterConfigFromEnv()
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	adapter, err := sender.NewProviderAdapter(name, config)
+	if err != nil {
+		return nil, "", err
+	}
+	return adapter, name, nil
+}
+
+// getTemplateAdapterConfigFromEnv reads the "template" provider adapter, the body
+// template is read from PROVIDER_TEMPLATE_FILE or PROVIDER_TEMPLATE_BODY
+func getTemplateAdapterConfigFromEnv() (sender.TemplateAdapterConfig, error) {
+	config := sender.TemplateAdapterConfig{
+		Method:        os.Getenv("PROVIDER_TEMPLATE_METHOD"),
+		ContentType:   os.Getenv("PROVIDER_TEMPLATE_CONTENT_TYPE"),
+		Body:          os.Getenv("PROVIDER_TEMPLATE_BODY"),
+		MessageIDPath: os.Getenv("PROVIDER_RESPONSE_ID_PATH"),
+		StatusPath:    os.Getenv("PROVIDER_RESPONSE_STATUS_PATH"),
+	}
+	if file := os.Getenv("PROVIDER_TEMPLATE_FILE"); file != "" {
+		body, err := os.ReadFile(file)
+		if err != nil {
+			return sender.TemplateAdapterConfig{}, fmt.Errorf("PROVIDER_TEMPLATE_FILE error: %w", err)
+		}
+		config.Body = string(body)
+	}
+	var err error
+	config.Headers, err = getHeadersFromEnv("PROVIDER_TEMPLATE_HEADERS")
+	if err != nil {
+		return sender.TemplateAdapterConfig{}, err
+	}
+	return config, nil
+}
+
+// getSMTPConfigFromEnv reads the SMTP server of the email channel, email is
+// disabled when SMTP_ADDR is empty
+func getSMTPConfigFromEnv() (sender.SMTPConfig, bool, error) {
+	config := sender.SMTPConfig{
+		Addr:     os.Getenv("SMTP_ADDR"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("SMTP_FROM"),
+		Subject:  os.Getenv("SMTP_SUBJECT"),
+	}
+	if config.Addr == "" {
+		return sender.SMTPConfig{}, false, nil
+	}
+	err := config.Validate()
+	if err != nil {
+		return sender.SMTPConfig{}, false, err
+	}
+	return config, true, nil
+}
+
+func defaultInstanceID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
+
+func getDurationFromEnv(name string, defaultValue time.Duration) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s value %q: %w", name, value, err)
+	}
+	return duration, nil
+}
+
+func getIntFromEnv(name string, defaultValue int) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s value %q: %w", name, value, err)
+	}
+	return number, nil
+}
+
+// getHeadersFromEnv reads headers separated by ";", e.g. "X-Api-Key: secret;X-Tenant: campaign"
+func getHeadersFromEnv(name string) (map[string]string, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil, nil
+	}
+	headers := make(map[string]string)
+	for header := range strings.SplitSeq(value, ";") {
+		headerName, headerValue, ok := strings.Cut(header, ":")
+		headerName = strings.TrimSpace(headerName)
+		if !ok || headerName == "" {
+			return nil, fmt.Errorf("invalid %s header %q", name, header)
+		}
+		headers[headerName] = strings.TrimSpace(headerValue)
+	}
+	return headers, nil
+}
+
+func getFloatFromEnv(name string, defaultValue float64) (float64, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s value %q: %w", name, value, err)
+	}
+	return number, nil
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"errors"
+	"expvar"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -11,11 +12,14 @@ import (
 	"sync"
 	"syscall"
 	"time"
+	// embedded timezone database, the runtime image may not have one
+	_ "time/tzdata"
 
 	"auto-message-sender/infra/cache"
 	"auto-message-sender/infra/repository"
 	"auto-message-sender/infra/sender"
 	"auto-message-sender/internal/handlers"
+	"auto-message-sender/internal/models"
 	"auto-message-sender/internal/services"
 
 	"github.com/jackc/pgx/v5"
@@ -47,30 +51,199 @@ func main() {
 	}
 	defer client.Close()
 
-	webhookSiteURL := getWebhookSiteURLFromEnv()
-	if webhookSiteURL == "" {
-		logger.Error("webhook site url is empty")
-		panic("webhook site url is empty")
+	senderConfig, err := getSenderConfigFromEnv()
+	if err != nil {
+		logger.Error("getSenderConfigFromEnv error", "error", err)
+		panic(err)
+	}
+	sendSchedule, err := getScheduleFromEnv()
+	if err != nil {
+		logger.Error("getScheduleFromEnv error", "error", err)
+		panic(err)
+	}
+	logger.Info("auto message sender schedule", "schedule", sendSchedule.String())
+	retryPolicy, err := getRetryPolicyFromEnv()
+	if err != nil {
+		logger.Error("getRetryPolicyFromEnv error", "error", err)
+		panic(err)
+	}
+	cacheRetentionPolicy, err := getCacheRetentionPolicyFromEnv()
+	if err != nil {
+		logger.Error("getCacheRetentionPolicyFromEnv error", "error", err)
+		panic(err)
+	}
+	messageCacheTTL, err := getMessageCacheTTLFromEnv()
+	if err != nil {
+		logger.Error("getMessageCacheTTLFromEnv error", "error", err)
+		panic(err)
+	}
+	rateLimitConfig, err := getRateLimitConfigFromEnv()
+	if err != nil {
+		logger.Error("getRateLimitConfigFromEnv error", "error", err)
+		panic(err)
+	}
+	circuitBreakerConfig, err := getCircuitBreakerConfigFromEnv()
+	if err != nil {
+		logger.Error("getCircuitBreakerConfigFromEnv error", "error", err)
+		panic(err)
+	}
+	successStatusCodes, err := getSuccessStatusCodesFromEnv()
+	if err != nil {
+		logger.Error("getSuccessStatusCodesFromEnv error", "error", err)
+		panic(err)
+	}
+	webhookAuthenticators, err := getWebhookAuthenticatorsFromEnv()
+	if err != nil {
+		logger.Error("getWebhookAuthenticatorsFromEnv error", "error", err)
+		panic(err)
+	}
+	providerAdapter, providerAdapterName, err := getProviderAdapterFromEnv()
+	if err != nil {
+		logger.Error("getProviderAdapterFromEnv error", "error", err)
+		panic(err)
+	}
+
+	deliveryReportVerifier, err := getDeliveryReportVerifierFromEnv()
+	if err != nil {
+		logger.Error("getDeliveryReportVerifierFromEnv error", "error", err)
+		panic(err)
+	}
+	smtpConfig, emailEnabled, err := getSMTPConfigFromEnv()
+	if err != nil {
+		logger.Error("getSMTPConfigFromEnv error", "error", err)
+		panic(err)
+	}
+
+	webhookEndpoints, err := getWebhookEndpointsFromEnv()
+	if err != nil {
+		logger.Error("getWebhookEndpointsFromEnv error", "error", err)
+		panic(err)
+	}
+	failoverConfig, err := getFailoverConfigFromEnv()
+	if err != nil {
+		logger.Error("getFailoverConfigFromEnv error", "error", err)
+		panic(err)
+	}
+	// a send waits for the recipient limit and fails over through every endpoint
+	maxSendTime := rateLimitConfig.MaxWait + time.Duration(len(webhookEndpoints))*(rateLimitConfig.MaxWait+sender.RequestTimeout)
+	leaseConfig, err := getLeaseConfigFromEnv(maxSendTime)
+	if err != nil {
+		logger.Error("getLeaseConfigFromEnv error", "error", err)
+		panic(err)
+	}
+	// every endpoint has its own provider rate limit and circuit breaker, the
+	// recipient limit is shared by all of them
+	endpointRateLimitConfig := rateLimitConfig
+	endpointRateLimitConfig.RecipientLimit = 0
+	recipientRateLimitConfig := rateLimitConfig
+	recipientRateLimitConfig.RequestsPerSecond = 0
+	metricsRegistry := sender.NewMetricsRegistry()
+	metricsRegistry.Publish()
+	failoverEndpoints := make([]sender.FailoverEndpoint, 0, len(webhookEndpoints))
+	circuitBreakers := make(sender.CircuitBreakers, 0, len(webhookEndpoints))
+	for _, endpoint := range webhookEndpoints {
+		endpointCircuitBreakerConfig := circuitBreakerConfig
+		endpointCircuitBreakerConfig.Name = circuitBreakerConfig.Name + "/" + endpoint.Name
+		circuitBreakerMessageSender, err2 := sender.NewCircuitBreakerMessageSender(logger, metricsRegistry, sender.NewWebhookMessageSender(
+			endpoint.URL,
+			sender.WithSuccessStatusCodes(successStatusCodes...),
+			sender.WithAuthenticators(webhookAuthenticators...),
+			sender.WithProviderAdapter(providerAdapter),
+		), endpointCircuitBreakerConfig)
+		if err2 != nil {
+			logger.Error("sender.NewCircuitBreakerMessageSender error", "error", err2)
+			panic(err2)
+		}
+		circuitBreakers = append(circuitBreakers, circuitBreakerMessageSender)
+		failoverEndpoints = append(failoverEndpoints, sender.FailoverEndpoint{
+			Name:     endpoint.Name,
+			Provider: providerAdapterName,
+			Priority: endpoint.Priority,
+			Weight:   endpoint.Weight,
+			Sender:   sender.NewRateLimitedMessageSender(circuitBreakerMessageSender, endpointRateLimitConfig),
+		})
+	}
+	failoverMessageSender, err := sender.NewFailoverMessageSender(logger, metricsRegistry, failoverEndpoints, failoverConfig)
+	if err != nil {
+		logger.Error("sender.NewFailoverMessageSender error", "error", err)
+		panic(err)
+	}
+	rateLimitedMessageSender := sender.NewRateLimitedMessageSender(failoverMessageSender, recipientRateLimitConfig)
+	// messages of a channel that is not configured fail permanently
+	channelRouterMessageSender := sender.NewChannelRouterMessageSender().
+		Route(models.ChannelSMS, rateLimitedMessageSender)
+	if emailEnabled {
+		smtpMessageSender, err2 := sender.NewSMTPMessageSender(smtpConfig)
+		if err2 != nil {
+			logger.Error("sender.NewSMTPMessageSender error", "error", err2)
+			panic(err2)
+		}
+		channelRouterMessageSender.Route(models.ChannelEmail, smtpMessageSender)
 	}
-	webhookMessageSender := sender.NewWebhookMessageSender(webhookSiteURL)
-	webhookMessageSenderWithLogger := sender.NewWebhookMessageSenderWithLogger(logger, webhookMessageSender)
-	messageRepository := repository.NewMessagePostgresqlRepository(conn)
+	if chatWebhookURL := getChatWebhookURLFromEnv(); chatWebhookURL != "" {
+		channelRouterMessageSender.Route(models.ChannelChat, sender.NewChatWebhookMessageSender(chatWebhookURL))
+	}
+	webhookMessageSenderWithLogger := sender.NewWebhookMessageSenderWithLogger(logger, channelRouterMessageSender)
+	messageRepository := repository.NewMessagePostgresqlRepository(conn, leaseConfig.InstanceID)
 	messageRepositoryWithLogger := repository.NewMessageRepositoryWithLogger(logger, messageRepository)
-	setCache := cache.NewSetCache(client)
+	setCache := cache.NewSetCache(client, cacheRetentionPolicy.TTL)
 	setCacheWithLogger := cache.NewSetCacheWithLogger(logger, setCache)
-	autoMessageSenderServices := services.NewAutoMessageSender(messageRepositoryWithLogger, webhookMessageSenderWithLogger, setCacheWithLogger, 2)
+	indexed, err := setCache.BuildIndex(ctx)
+	if err != nil {
+		logger.Error("setCache.BuildIndex error", "error", err)
+		panic(err)
+	}
+	if indexed > 0 {
+		logger.Info("sent message index built", "indexed", indexed)
+	}
+	autoMessageSenderServices := services.NewAutoMessageSender(messageRepositoryWithLogger, webhookMessageSenderWithLogger, setCacheWithLogger, senderConfig, retryPolicy, sendSchedule)
+	leaseReaper := services.NewLeaseReaper(messageRepositoryWithLogger, leaseConfig.ReaperInterval, leaseConfig.LeaseTimeout, retryPolicy.MaxAttempts)
+	sentMessageRetention := cache.NewSentMessageRetention(client, cacheRetentionPolicy)
+	sentMessageRetentionWithLogger := cache.NewSentMessageRetentionWithLogger(logger, sentMessageRetention)
+	cacheTrimmer := services.NewCacheTrimmer(sentMessageRetentionWithLogger, cacheRetentionPolicy.TrimInterval)
 
 	getListCache := cache.NewGetListCache(client)
 	getListCacheWithLogger := cache.NewGetListCacheWithLogger(logger, getListCache)
 	messagesService := services.NewRetrieveSentMessagesService(getListCacheWithLogger)
+	messageCache := cache.NewMessageCache(client, messageCacheTTL)
+	messageCacheWithLogger := cache.NewMessageCacheWithLogger(logger, messageCache)
+	messageLookupService := services.NewMessageLookupService(messageRepositoryWithLogger, getListCacheWithLogger, messageCacheWithLogger)
 
-	messagesHandler := handlers.NewMessagesHandler(messagesService)
+	createMessageService := services.NewCreateMessageService(messageRepositoryWithLogger, autoMessageSenderServices)
+	importMessagesService := services.NewImportMessagesService(messageRepositoryWithLogger, autoMessageSenderServices)
+	failedMessagesService := services.NewFailedMessagesService(messageRepositoryWithLogger, messageCacheWithLogger)
+	deliveryReportService := services.NewDeliveryReportService(messageRepositoryWithLogger, setCacheWithLogger, messageCacheWithLogger)
+
+	messagesHandler := handlers.NewMessagesHandler(messagesService, messageLookupService)
+	createMessageHandler := handlers.NewCreateMessageHandler(createMessageService)
+	importMessagesHandler := handlers.NewImportMessagesHandler(importMessagesService)
+	failedMessagesHandler := handlers.NewFailedMessagesHandler(failedMessagesService)
 	autoSenderStartStopHandler := handlers.NewAutoSenderStartStopHandler(autoMessageSenderServices)
+	senderStatusHandler := handlers.NewSenderStatusHandler(autoMessageSenderServices, circuitBreakers, failoverMessageSender, sentMessageRetentionWithLogger)
+	senderConfigHandler := handlers.NewSenderConfigHandler(autoMessageSenderServices)
+	senderScheduleHandler := handlers.NewSenderScheduleHandler(autoMessageSenderServices)
+	deliveryReportsHandler := handlers.NewDeliveryReportsHandler(deliveryReportService, deliveryReportVerifier)
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("GET /messages", messagesHandler.RetrieveSentMessagesHandler)
+	mux.HandleFunc("POST /messages", createMessageHandler.CreateMessage)
+	mux.HandleFunc("POST /messages/import", importMessagesHandler.ImportMessages)
+	mux.HandleFunc("GET /messages/failed", failedMessagesHandler.ListFailedMessages)
+	mux.HandleFunc("POST /messages/failed/retry", failedMessagesHandler.RetryFailedMessages)
+	mux.HandleFunc("GET /messages/{id}", messagesHandler.GetMessage)
+	mux.HandleFunc("POST /messages/{id}/retry", failedMessagesHandler.RetryFailedMessage)
 	mux.HandleFunc("POST /start", autoSenderStartStopHandler.Start)
 	mux.HandleFunc("POST /stop", autoSenderStartStopHandler.Stop)
+	mux.HandleFunc("GET /sender/status", senderStatusHandler.GetStatus)
+	mux.HandleFunc("GET /sender/config", senderConfigHandler.GetConfig)
+	mux.HandleFunc("PUT /sender/config", senderConfigHandler.UpdateConfig)
+	mux.HandleFunc("GET /sender/schedule", senderScheduleHandler.GetSchedule)
+	if deliveryReportVerifier != nil {
+		mux.HandleFunc("POST /callbacks/delivery-reports", deliveryReportsHandler.RecordDeliveryReport)
+	} else {
+		logger.Info("DELIVERY_REPORT_SECRET is not set, delivery report callback is disabled")
+	}
+	mux.Handle("GET /debug/vars", expvar.Handler())
 	mux.HandleFunc("GET /health", func(w http.ResponseWriter, _ *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		_, _ = fmt.Fprintf(w, "OK")
@@ -86,10 +259,17 @@ func main() {
 	}
 
 	// All services are started here and wait for the context to be done or error
-	startServices(ctx, logger, &server, autoMessageSenderServices)
+	startServices(ctx, logger, &server, autoMessageSenderServices, leaseReaper, cacheTrimmer)
 }
 
-func startServices(ctx context.Context, logger *slog.Logger, server *http.Server, autoMessageSenderServices *services.AutoMessageSender) {
+func startServices(
+	ctx context.Context,
+	logger *slog.Logger,
+	server *http.Server,
+	autoMessageSenderServices *services.AutoMessageSender,
+	leaseReaper *services.LeaseReaper,
+	cacheTrimmer *services.CacheTrimmer,
+) {
 	wg := sync.WaitGroup{}
 	wg.Go(func() {
 		logger.Info("starting http server")
@@ -126,6 +306,24 @@ func startServices(ctx context.Context, logger *slog.Logger, server *http.Server
 			logger.Info("auto message sender stopped")
 		}
 	})
+	wg.Go(func() {
+		logger.Info("starting lease reaper")
+		err2 := leaseReaper.Run(ctx)
+		if err2 != nil {
+			logger.Error("lease reaper error", "error", err2)
+			panic(err2)
+		}
+		logger.Info("lease reaper stopped")
+	})
+	wg.Go(func() {
+		logger.Info("starting cache trimmer")
+		err2 := cacheTrimmer.Run(ctx)
+		if err2 != nil {
+			logger.Error("cache trimmer error", "error", err2)
+			panic(err2)
+		}
+		logger.Info("cache trimmer stopped")
+	})
 	wg.Wait()
 }
 
@@ -168,6 +366,10 @@ func getWebhookSiteURLFromEnv() string {
 	return os.Getenv("WEBHOOK_SITE_URL")
 }
 
+func getChatWebhookURLFromEnv() string {
+	return os.Getenv("CHAT_WEBHOOK_URL")
+}
+
 func newSlogLogger() *slog.Logger {
 	return slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
 		Level: slog.LevelDebug,
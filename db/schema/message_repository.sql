@@ -1,16 +1,99 @@
-CREATE TYPE sending_status AS ENUM (
-    'waiting',
-    'pending',
-    'sent',
-    'failed'
-    );
+-- the schema can be run again on an existing database, it adds what is missing
+DO
+$$
+    BEGIN
+        CREATE TYPE sending_status AS ENUM (
+            'waiting',
+            'pending',
+            'sent',
+            'failed'
+            );
+    EXCEPTION
+        WHEN duplicate_object THEN NULL;
+    END
+$$;
 
 CREATE TABLE IF NOT EXISTS messages
 (
-    message_id      UUID PRIMARY KEY,
-    phone_number    VARCHAR(20),
-    message_content VARCHAR(160),
-    sending_status  sending_status,
-    created_at      TIMESTAMP,
-    updated_at      TIMESTAMP
+    message_id           UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    channel              VARCHAR(16) NOT NULL DEFAULT 'sms',
+    phone_number         VARCHAR(20),
+    recipient            VARCHAR(320),
+    message_content      VARCHAR(10000),
+    sending_status       sending_status,
+    send_at              TIMESTAMP,
+    attempt_count        INTEGER NOT NULL DEFAULT 0,
+    replay_count         INTEGER NOT NULL DEFAULT 0,
+    replayed_attempts    INTEGER NOT NULL DEFAULT 0,
+    claimed_by           VARCHAR(255),
+    claimed_at           TIMESTAMP,
+    last_error           TEXT,
+    next_attempt_at      TIMESTAMP,
+    provider_message_id  VARCHAR(255),
+    provider             VARCHAR(64),
+    provider_accepted_at TIMESTAMP,
+    delivery_status      VARCHAR(16),
+    delivery_status_at   TIMESTAMP,
+    delivery_error_code  VARCHAR(255),
+    created_at           TIMESTAMP,
+    updated_at           TIMESTAMP
 );
+
+-- upgrades a messages table created by an earlier version
+ALTER TABLE messages
+    ALTER COLUMN message_id SET DEFAULT gen_random_uuid();
+
+ALTER TABLE messages
+    ADD COLUMN IF NOT EXISTS attempt_count INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS claimed_by    VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS claimed_at    TIMESTAMP;
+
+ALTER TABLE messages
+    ADD COLUMN IF NOT EXISTS last_error      TEXT,
+    ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMP;
+
+ALTER TABLE messages
+    ADD COLUMN IF NOT EXISTS replay_count      INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS replayed_attempts INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE messages
+    ADD COLUMN IF NOT EXISTS send_at TIMESTAMP;
+
+ALTER TABLE messages
+    ADD COLUMN IF NOT EXISTS provider_message_id  VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS provider_accepted_at TIMESTAMP;
+
+ALTER TABLE messages
+    ADD COLUMN IF NOT EXISTS channel   VARCHAR(16) NOT NULL DEFAULT 'sms',
+    ADD COLUMN IF NOT EXISTS recipient VARCHAR(320),
+    ALTER COLUMN message_content TYPE VARCHAR(10000);
+
+ALTER TABLE messages
+    ADD COLUMN IF NOT EXISTS provider            VARCHAR(64),
+    ADD COLUMN IF NOT EXISTS delivery_status     VARCHAR(16),
+    ADD COLUMN IF NOT EXISTS delivery_status_at  TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS delivery_error_code VARCHAR(255);
+
+CREATE INDEX IF NOT EXISTS messages_pending_claimed_at_idx ON messages (claimed_at) WHERE sending_status = 'pending';
+-- replaced by messages_waiting_due_at_idx
+DROP INDEX IF EXISTS messages_waiting_created_at_idx;
+CREATE INDEX IF NOT EXISTS messages_waiting_due_at_idx ON messages (COALESCE(send_at, created_at), created_at) WHERE sending_status = 'waiting';
+-- replaced by messages_provider_provider_message_id_idx
+DROP INDEX IF EXISTS messages_provider_message_id_idx;
+CREATE INDEX IF NOT EXISTS messages_provider_provider_message_id_idx ON messages (provider, provider_message_id) WHERE provider_message_id IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS message_attempts
+(
+    attempt_id          BIGSERIAL PRIMARY KEY,
+    message_id          UUID    NOT NULL REFERENCES messages (message_id) ON DELETE CASCADE,
+    attempt_number      INTEGER NOT NULL,
+    provider            VARCHAR(64),
+    endpoint            VARCHAR(255),
+    status_code         INTEGER,
+    latency_ms          BIGINT  NOT NULL DEFAULT 0,
+    provider_message_id VARCHAR(255),
+    error               TEXT,
+    created_at          TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS message_attempts_message_id_idx ON message_attempts (message_id, attempt_id);
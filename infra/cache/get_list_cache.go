@@ -2,6 +2,7 @@ package cache
 
 import (
 	"context"
+	"strconv"
 	"time"
 
 	"auto-message-sender/internal/models"
@@ -10,7 +11,8 @@ import (
 )
 
 type getListCache interface {
-	GetList(ctx context.Context) ([]models.MessageSenderResponse, error)
+	GetList(ctx context.Context, filter models.SentMessageFilter) (models.SentMessagePage, error)
+	Get(ctx context.Context, messageID string) (models.MessageSenderResponse, bool, error)
 }
 
 var _ getListCache = (*GetListCache)(nil)
@@ -25,34 +27,151 @@ func NewGetListCache(client *redis.Client) *GetListCache {
 	}
 }
 
+// responseData is the hash of a sent message, empty fields are not written
 type responseData struct {
-	Message   string    `redis:"message"`
-	MessageID string    `redis:"message_id"`
-	SentAt    time.Time `redis:"sent_at"`
+	Message          string    `redis:"message,omitempty"`
+	MessageID        string    `redis:"message_id"`
+	SentAt           time.Time `redis:"sent_at,omitempty"`
+	DeliveryStatus   string    `redis:"delivery_status,omitempty"`
+	DeliveryStatusAt time.Time `redis:"delivery_status_at,omitempty"`
 }
 
-func (c *GetListCache) GetList(ctx context.Context) ([]models.MessageSenderResponse, error) {
-	scanKeys := c.client.Keys(ctx, "sent_message*")
-	if scanKeys.Err() != nil {
-		return nil, scanKeys.Err()
-	}
-	resultKeys := scanKeys.Val()
-	messages := make([]models.MessageSenderResponse, 0, 10)
-	for _, key := range resultKeys {
-		scan := c.client.HGetAll(ctx, key)
-		if scan.Err() != nil {
-			return nil, scan.Err()
-		}
-		var data responseData
-		err2 := scan.Scan(&data)
-		if err2 != nil {
-			return nil, err2
-		}
-		messages = append(messages, models.MessageSenderResponse{
-			Message:   data.Message,
-			MessageID: data.MessageID,
-			SentAt:    data.SentAt.UTC(),
-		})
-	}
-	return messages, nil
+// removeMissingScript removes the index entries in ARGV whose hash in KEYS[2:]
+// is gone, an entry cached again since it was read is kept
+var removeMissingScript = redis.NewScript(`
+local removed = 0
+for i, member in ipairs(ARGV) do
+	if redis.call('EXISTS', KEYS[i + 1]) == 0 then
+		removed = removed + redis.call('ZREM', KEYS[1], member)
+	end
+end
+return removed
+`)
+
+// GetList returns a page of the sent messages from the index, newest first.
+// Index entries whose hash has expired are removed and the page is filled from
+// the following entries.
+func (c *GetListCache) GetList(ctx context.Context, filter models.SentMessageFilter) (models.SentMessagePage, error) {
+	minScore, maxScore := "-inf", "+inf"
+	if !filter.Since.IsZero() {
+		minScore = strconv.FormatFloat(sentMessageScore(filter.Since), 'f', -1, 64)
+	}
+	if !filter.Until.IsZero() {
+		maxScore = "(" + strconv.FormatFloat(sentMessageScore(filter.Until), 'f', -1, 64)
+	}
+	var cursor *sentMessageCursor
+	if filter.Cursor != "" {
+		parsed, err := parseSentMessageCursor(filter.Cursor)
+		if err != nil {
+			return models.SentMessagePage{}, err
+		}
+		cursor = &parsed
+	}
+	page := models.SentMessagePage{
+		Messages: make([]models.MessageSenderResponse, 0, filter.Limit),
+	}
+	// entries are the index entries of page.Messages
+	entries := make([]redis.Z, 0, filter.Limit)
+	for {
+		rangeArgs := redis.ZRangeArgs{
+			Key:     sentMessageIndexKey,
+			Start:   minScore,
+			Stop:    maxScore,
+			ByScore: true,
+			Rev:     true,
+			Count:   int64(filter.Limit-len(entries)) + 1,
+		}
+		readCursor := cursor
+		if len(entries) > 0 {
+			next := nextSentMessageCursor(cursor, entries)
+			readCursor = &next
+		}
+		if readCursor != nil {
+			rangeArgs.Stop = strconv.FormatInt(readCursor.score, 10)
+			rangeArgs.Offset = readCursor.skip
+		}
+		read, err := c.client.ZRangeArgsWithScores(ctx, rangeArgs).Result()
+		if err != nil {
+			return models.SentMessagePage{}, err
+		}
+		if len(read) == 0 {
+			return page, nil
+		}
+		pipeline := c.client.Pipeline()
+		commands := make([]*redis.MapStringStringCmd, 0, len(read))
+		for _, entry := range read {
+			messageID, _ := entry.Member.(string)
+			commands = append(commands, pipeline.HGetAll(ctx, sentMessageKey(messageID)))
+		}
+		_, err = pipeline.Exec(ctx)
+		if err != nil {
+			return models.SentMessagePage{}, err
+		}
+		missingKeys := []string{sentMessageIndexKey}
+		var missingMembers []any
+		full := false
+		for i, command := range commands {
+			if len(command.Val()) == 0 {
+				missingKeys = append(missingKeys, sentMessageKey(read[i].Member.(string)))
+				missingMembers = append(missingMembers, read[i].Member)
+				continue
+			}
+			if len(entries) == filter.Limit {
+				full = true
+				break
+			}
+			var data responseData
+			err = command.Scan(&data)
+			if err != nil {
+				return models.SentMessagePage{}, err
+			}
+			page.Messages = append(page.Messages, data.response())
+			entries = append(entries, read[i])
+		}
+		if len(missingMembers) > 0 {
+			err = removeMissingScript.Run(ctx, c.client, missingKeys, missingMembers...).Err()
+			if err != nil {
+				return models.SentMessagePage{}, err
+			}
+		}
+		if full {
+			page.NextCursor = nextSentMessageCursor(cursor, entries).String()
+			return page, nil
+		}
+		if int64(len(read)) < rangeArgs.Count {
+			return page, nil
+		}
+	}
+}
+
+// Get returns the sent message the provider accepted with messageID, false is
+// returned if it is not cached
+func (c *GetListCache) Get(ctx context.Context, messageID string) (models.MessageSenderResponse, bool, error) {
+	command := c.client.HGetAll(ctx, sentMessageKey(messageID))
+	if command.Err() != nil {
+		return models.MessageSenderResponse{}, false, command.Err()
+	}
+	if len(command.Val()) == 0 {
+		return models.MessageSenderResponse{}, false, nil
+	}
+	var data responseData
+	err := command.Scan(&data)
+	if err != nil {
+		return models.MessageSenderResponse{}, false, err
+	}
+	return data.response(), true, nil
+}
+
+func (d responseData) response() models.MessageSenderResponse {
+	message := models.MessageSenderResponse{
+		Message:        d.Message,
+		MessageID:      d.MessageID,
+		SentAt:         d.SentAt.UTC(),
+		DeliveryStatus: d.DeliveryStatus,
+	}
+	if !d.DeliveryStatusAt.IsZero() {
+		deliveryStatusAt := d.DeliveryStatusAt.UTC()
+		message.DeliveryStatusAt = &deliveryStatusAt
+	}
+	return message
 }
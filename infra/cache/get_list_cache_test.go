@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"auto-message-sender/internal/models"
+)
+
+func TestGetListSkipsExpiredEntries(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	now := time.Date(2025, 11, 13, 10, 0, 0, 0, time.UTC)
+	setCache := NewSetCache(client, 0)
+	// provider-2 and provider-3 are sent in the same microsecond
+	sentAts := []time.Time{
+		now.Add(-7 * time.Minute),
+		now.Add(-6 * time.Minute),
+		now.Add(-5 * time.Minute),
+		now.Add(-5 * time.Minute),
+		now.Add(-4 * time.Minute),
+		now.Add(-3 * time.Minute),
+		now.Add(-2 * time.Minute),
+		now.Add(-time.Minute),
+	}
+	for i, sentAt := range sentAts {
+		err := setCache.Set(ctx, models.MessageSenderResponse{Message: "Accepted", MessageID: fmt.Sprintf("provider-%d", i), SentAt: sentAt})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the hashes expire, their index entries are left behind
+	expired := []string{"provider-7", "provider-6", "provider-3", "provider-1"}
+	for _, messageID := range expired {
+		err := client.Del(ctx, sentMessageKey(messageID)).Err()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	getListCache := NewGetListCache(client)
+
+	var messageIDs []string
+	var pages int
+	filter := models.SentMessageFilter{Limit: 2}
+	for {
+		page, err := getListCache.GetList(ctx, filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages++
+		if page.NextCursor != "" && len(page.Messages) != filter.Limit {
+			t.Fatalf("page %d: expected %d messages, got %+v", pages, filter.Limit, page.Messages)
+		}
+		for _, message := range page.Messages {
+			messageIDs = append(messageIDs, message.MessageID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		filter.Cursor = page.NextCursor
+	}
+	if pages != 2 || fmt.Sprint(messageIDs) != "[provider-5 provider-4 provider-2 provider-0]" {
+		t.Fatalf("expected the 4 cached messages in 2 pages, got %v in %d pages", messageIDs, pages)
+	}
+	members, err := client.ZRevRange(ctx, sentMessageIndexKey, 0, -1).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(members) != "[provider-5 provider-4 provider-2 provider-0]" {
+		t.Fatalf("expected the expired entries to be removed from the index, got %v", members)
+	}
+}
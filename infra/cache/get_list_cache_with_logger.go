@@ -21,16 +21,26 @@ func NewGetListCacheWithLogger(logger *slog.Logger, baseService getListCache) *G
 	}
 }
 
-func (g *GetListCacheWithLogger) GetList(ctx context.Context) ([]models.MessageSenderResponse, error) {
-	messages, err := g.baseService.GetList(ctx)
+func (g *GetListCacheWithLogger) GetList(ctx context.Context, filter models.SentMessageFilter) (models.SentMessagePage, error) {
+	page, err := g.baseService.GetList(ctx, filter)
 	if err != nil {
 		g.logger.Error("GetListCacheWithLogger.GetList error:", "error", err)
-		return messages, err
+		return page, err
 	}
-	if len(messages) == 0 {
+	if len(page.Messages) == 0 {
 		g.logger.Debug("GetListCacheWithLogger.GetList success but message not found")
-		return messages, nil
+		return page, nil
 	}
-	g.logger.Debug("GetListCacheWithLogger.GetList success:", "count", len(messages))
-	return messages, nil
+	g.logger.Debug("GetListCacheWithLogger.GetList success:", "count", len(page.Messages), "nextCursor", page.NextCursor)
+	return page, nil
+}
+
+func (g *GetListCacheWithLogger) Get(ctx context.Context, messageID string) (models.MessageSenderResponse, bool, error) {
+	message, found, err := g.baseService.Get(ctx, messageID)
+	if err != nil {
+		g.logger.Error("GetListCacheWithLogger.Get error:", "error", err, "messageID", messageID)
+		return message, found, err
+	}
+	g.logger.Debug("GetListCacheWithLogger.Get success:", "messageID", messageID, "found", found)
+	return message, found, nil
 }
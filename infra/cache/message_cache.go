@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"auto-message-sender/internal/models"
+)
+
+type messageCache interface {
+	GetMessage(ctx context.Context, messageID string) (models.MessageDetails, bool, error)
+	SetMessage(ctx context.Context, details models.MessageDetails) error
+	DeleteMessage(ctx context.Context, messageID string) error
+}
+
+var _ messageCache = (*MessageCache)(nil)
+
+// MessageCache keeps snapshots of looked up messages, so repeated lookups of a
+// message do not reach PostgreSQL. Snapshots expire after ttl, changes that are
+// not deleted explicitly are visible after that.
+type MessageCache struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+func NewMessageCache(client *redis.Client, ttl time.Duration) *MessageCache {
+	return &MessageCache{
+		client: client,
+		ttl:    ttl,
+	}
+}
+
+func messageKey(messageID string) string {
+	return fmt.Sprintf("message_%s", messageID)
+}
+
+// GetMessage returns the snapshot of a message, false is returned if it is not cached
+func (c *MessageCache) GetMessage(ctx context.Context, messageID string) (models.MessageDetails, bool, error) {
+	data, err := c.client.Get(ctx, messageKey(messageID)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return models.MessageDetails{}, false, nil
+	}
+	if err != nil {
+		return models.MessageDetails{}, false, err
+	}
+	var details models.MessageDetails
+	err = json.Unmarshal(data, &details)
+	if err != nil {
+		return models.MessageDetails{}, false, fmt.Errorf("json.Unmarshal error: %w", err)
+	}
+	return details, true, nil
+}
+
+func (c *MessageCache) SetMessage(ctx context.Context, details models.MessageDetails) error {
+	data, err := json.Marshal(details)
+	if err != nil {
+		return fmt.Errorf("json.Marshal error: %w", err)
+	}
+	return c.client.Set(ctx, messageKey(details.MessageID), data, c.ttl).Err()
+}
+
+// DeleteMessage removes the snapshot of a message that changed
+func (c *MessageCache) DeleteMessage(ctx context.Context, messageID string) error {
+	return c.client.Del(ctx, messageKey(messageID)).Err()
+}
@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"context"
+	"log/slog"
+
+	"auto-message-sender/internal/models"
+)
+
+var _ messageCache = (*MessageCacheWithLogger)(nil)
+
+type MessageCacheWithLogger struct {
+	logger      *slog.Logger
+	baseService messageCache
+}
+
+func NewMessageCacheWithLogger(logger *slog.Logger, baseService messageCache) *MessageCacheWithLogger {
+	return &MessageCacheWithLogger{
+		logger:      logger,
+		baseService: baseService,
+	}
+}
+
+func (m *MessageCacheWithLogger) GetMessage(ctx context.Context, messageID string) (models.MessageDetails, bool, error) {
+	details, found, err := m.baseService.GetMessage(ctx, messageID)
+	if err != nil {
+		m.logger.Error("MessageCacheWithLogger.GetMessage error:", "error", err, "messageID", messageID)
+		return details, found, err
+	}
+	m.logger.Debug("MessageCacheWithLogger.GetMessage success:", "messageID", messageID, "found", found)
+	return details, found, nil
+}
+
+func (m *MessageCacheWithLogger) SetMessage(ctx context.Context, details models.MessageDetails) error {
+	err := m.baseService.SetMessage(ctx, details)
+	if err != nil {
+		m.logger.Error("MessageCacheWithLogger.SetMessage error:", "error", err, "messageID", details.MessageID)
+		return err
+	}
+	m.logger.Debug("MessageCacheWithLogger.SetMessage success:", "messageID", details.MessageID)
+	return nil
+}
+
+func (m *MessageCacheWithLogger) DeleteMessage(ctx context.Context, messageID string) error {
+	err := m.baseService.DeleteMessage(ctx, messageID)
+	if err != nil {
+		m.logger.Error("MessageCacheWithLogger.DeleteMessage error:", "error", err, "messageID", messageID)
+		return err
+	}
+	m.logger.Debug("MessageCacheWithLogger.DeleteMessage success:", "messageID", messageID)
+	return nil
+}
@@ -0,0 +1,8 @@
+package cache
+
+import (
+	"expvar"
+)
+
+// sentMessageCacheMetrics are published on /debug/vars
+var sentMessageCacheMetrics = expvar.NewMap("sent_message_cache")
@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"auto-message-sender/internal/models"
+)
+
+// sentMessageIndexKey is a sorted set of the cached sent messages, the members
+// are provider message ids scored by the send time in microseconds
+const sentMessageIndexKey = "sent_message_index"
+
+func sentMessageKey(messageID string) string {
+	return fmt.Sprintf("sent_message_%s", messageID)
+}
+
+// sentMessageScore is the index score of a message sent at sentAt, microseconds
+// fit in the exact integer range of a float64 score
+func sentMessageScore(sentAt time.Time) float64 {
+	return float64(sentAt.UnixMicro())
+}
+
+// sentMessageCursor points to the last index entry of a page. The next page
+// starts at score and skips the entries with that score already returned, so
+// messages sent in the same microsecond are neither lost nor repeated.
+type sentMessageCursor struct {
+	score int64
+	skip  int64
+}
+
+func parseSentMessageCursor(value string) (sentMessageCursor, error) {
+	scoreText, skipText, ok := strings.Cut(value, "_")
+	score, err := strconv.ParseInt(scoreText, 10, 64)
+	if !ok || err != nil {
+		return sentMessageCursor{}, fmt.Errorf("%w: %q", models.ErrInvalidCursor, value)
+	}
+	skip, err := strconv.ParseInt(skipText, 10, 64)
+	if err != nil || skip < 1 {
+		return sentMessageCursor{}, fmt.Errorf("%w: %q", models.ErrInvalidCursor, value)
+	}
+	return sentMessageCursor{score: score, skip: skip}, nil
+}
+
+func (c sentMessageCursor) String() string {
+	return strconv.FormatInt(c.score, 10) + "_" + strconv.FormatInt(c.skip, 10)
+}
+
+// nextSentMessageCursor returns the cursor after entries, a page read with
+// cursor and returned newest first
+func nextSentMessageCursor(cursor *sentMessageCursor, entries []redis.Z) sentMessageCursor {
+	next := sentMessageCursor{
+		score: int64(entries[len(entries)-1].Score),
+	}
+	for _, entry := range entries {
+		if int64(entry.Score) == next.score {
+			next.skip++
+		}
+	}
+	if cursor != nil && cursor.score == next.score {
+		next.skip += cursor.skip
+	}
+	return next
+}
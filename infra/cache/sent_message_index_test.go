@@ -0,0 +1,38 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+
+	"auto-message-sender/internal/models"
+)
+
+func TestSentMessageCursor(t *testing.T) {
+	for _, value := range []string{"", "1731373791133430", "1731373791133430_0", "abc_1", "1731373791133430_x"} {
+		_, err := parseSentMessageCursor(value)
+		if !errors.Is(err, models.ErrInvalidCursor) {
+			t.Fatalf("%q: expected ErrInvalidCursor, got %v", value, err)
+		}
+	}
+
+	// pages of two over the scores 30, 20, 20, 20, 10 newest first
+	first := nextSentMessageCursor(nil, []redis.Z{{Score: 30}, {Score: 20}})
+	if first.String() != "20_1" {
+		t.Fatalf("unexpected first cursor %s", first)
+	}
+	parsed, err := parseSentMessageCursor(first.String())
+	if err != nil || parsed != first {
+		t.Fatalf("expected %s to round trip, got %s, %v", first, parsed, err)
+	}
+	// the second page starts at 20 and skips the entry already returned
+	second := nextSentMessageCursor(&parsed, []redis.Z{{Score: 20}, {Score: 20}})
+	if second.String() != "20_3" {
+		t.Fatalf("unexpected second cursor %s", second)
+	}
+	third := nextSentMessageCursor(&second, []redis.Z{{Score: 10}})
+	if third.String() != "10_1" {
+		t.Fatalf("unexpected third cursor %s", third)
+	}
+}
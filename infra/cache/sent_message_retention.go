@@ -0,0 +1,149 @@
+package cache
+
+import (
+	"context"
+	"expvar"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"auto-message-sender/internal/models"
+)
+
+// trimBatchSize is the default number of index entries removed in one round trip
+const trimBatchSize = 1000
+
+// trimExpiredScript removes up to ARGV[2] index entries scored below ARGV[1]
+// and returns them, the read and the removal are atomic so instances trimming
+// at the same time do not count an entry twice
+var trimExpiredScript = redis.NewScript(`
+local members = redis.call('ZRANGE', KEYS[1], '-inf', ARGV[1], 'BYSCORE', 'LIMIT', 0, ARGV[2])
+if #members > 0 then
+	redis.call('ZREM', KEYS[1], unpack(members))
+end
+return members
+`)
+
+// trimOverflowScript removes up to ARGV[2] of the oldest index entries beyond the
+// ARGV[1] most recent ones and returns them
+var trimOverflowScript = redis.NewScript(`
+local over = redis.call('ZCARD', KEYS[1]) - tonumber(ARGV[1])
+if over <= 0 then
+	return {}
+end
+local members = redis.call('ZRANGE', KEYS[1], 0, math.min(over, tonumber(ARGV[2])) - 1)
+redis.call('ZREM', KEYS[1], unpack(members))
+return members
+`)
+
+type sentMessageRetention interface {
+	Trim(ctx context.Context) (expired int64, overflow int64, err error)
+	Status() models.CacheRetentionStatus
+}
+
+var _ sentMessageRetention = (*SentMessageRetention)(nil)
+
+// SentMessageRetention trims the sent message index to the retention policy and
+// deletes the hashes of the removed entries. The hashes also expire on their
+// own, the ones cached before the policy was set up are deleted by Trim.
+type SentMessageRetention struct {
+	client *redis.Client
+	policy models.CacheRetentionPolicy
+	now    func() time.Time
+	// batchSize is the number of index entries removed in one round trip
+	batchSize int
+
+	mu         sync.Mutex
+	lastTrimAt time.Time
+
+	expiredMetric  *expvar.Int
+	overflowMetric *expvar.Int
+}
+
+func NewSentMessageRetention(client *redis.Client, policy models.CacheRetentionPolicy) *SentMessageRetention {
+	r := &SentMessageRetention{
+		client:         client,
+		policy:         policy,
+		now:            time.Now,
+		batchSize:      trimBatchSize,
+		expiredMetric:  new(expvar.Int),
+		overflowMetric: new(expvar.Int),
+	}
+	sentMessageCacheMetrics.Set("evicted_expired", r.expiredMetric)
+	sentMessageCacheMetrics.Set("evicted_overflow", r.overflowMetric)
+	return r
+}
+
+// Trim removes the sent messages older than the TTL, then the oldest ones beyond
+// MaxEntries, and returns how many were removed for each reason
+func (r *SentMessageRetention) Trim(ctx context.Context) (int64, int64, error) {
+	now := r.now()
+	var expired, overflow int64
+	var err error
+	if r.policy.TTL > 0 {
+		cutoff := "(" + strconv.FormatFloat(sentMessageScore(now.Add(-r.policy.TTL)), 'f', -1, 64)
+		expired, err = r.trim(ctx, trimExpiredScript, cutoff)
+		r.expiredMetric.Add(expired)
+		if err != nil {
+			return expired, 0, err
+		}
+	}
+	if r.policy.MaxEntries > 0 {
+		overflow, err = r.trim(ctx, trimOverflowScript, r.policy.MaxEntries)
+		r.overflowMetric.Add(overflow)
+		if err != nil {
+			return expired, overflow, err
+		}
+	}
+	r.mu.Lock()
+	r.lastTrimAt = now
+	r.mu.Unlock()
+	return expired, overflow, nil
+}
+
+// trim runs script in batches until it removes less than a batch, the hashes of
+// the removed entries are deleted after each batch
+func (r *SentMessageRetention) trim(ctx context.Context, script *redis.Script, limit any) (int64, error) {
+	var removed int64
+	for {
+		members, err := script.Run(ctx, r.client, []string{sentMessageIndexKey}, limit, r.batchSize).StringSlice()
+		if err != nil {
+			return removed, err
+		}
+		if len(members) == 0 {
+			return removed, nil
+		}
+		removed += int64(len(members))
+		keys := make([]string, 0, len(members))
+		for _, member := range members {
+			keys = append(keys, sentMessageKey(member))
+		}
+		err = r.client.Del(ctx, keys...).Err()
+		if err != nil {
+			return removed, err
+		}
+		if len(members) < r.batchSize {
+			return removed, nil
+		}
+	}
+}
+
+// Status returns the retention policy and the evictions of this instance
+func (r *SentMessageRetention) Status() models.CacheRetentionStatus {
+	status := models.CacheRetentionStatus{
+		TTL:             r.policy.TTL.String(),
+		MaxEntries:      r.policy.MaxEntries,
+		TrimInterval:    r.policy.TrimInterval.String(),
+		EvictedExpired:  r.expiredMetric.Value(),
+		EvictedOverflow: r.overflowMetric.Value(),
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.lastTrimAt.IsZero() {
+		lastTrimAt := r.lastTrimAt
+		status.LastTrimAt = &lastTrimAt
+	}
+	return status
+}
@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"auto-message-sender/internal/models"
+)
+
+// newTestClient connects to the redis database in REDIS_TEST_URL and flushes it
+// before and after the test, the test is skipped when the variable is not set
+func newTestClient(t *testing.T) *redis.Client {
+	t.Helper()
+	url := os.Getenv("REDIS_TEST_URL")
+	if url == "" {
+		t.Skip("REDIS_TEST_URL is not set, skipping redis integration test")
+	}
+	ctx := context.Background()
+	opt, err := redis.ParseURL(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := redis.NewClient(opt)
+	err = client.FlushDB(ctx).Err()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = client.FlushDB(ctx).Err()
+		_ = client.Close()
+	})
+	return client
+}
+
+func TestSentMessageRetentionTrim(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	now := time.Date(2025, 11, 13, 10, 0, 0, 0, time.UTC)
+	setCache := NewSetCache(client, 0)
+	// 2 messages older than the TTL and 6 recent ones, 3 of them beyond MaxEntries
+	sentAts := []time.Time{now.Add(-2 * time.Hour), now.Add(-90 * time.Minute)}
+	for i := range 6 {
+		sentAts = append(sentAts, now.Add(-time.Duration(6-i)*time.Minute))
+	}
+	for i, sentAt := range sentAts {
+		err := setCache.Set(ctx, models.MessageSenderResponse{Message: "Accepted", MessageID: fmt.Sprintf("provider-%d", i), SentAt: sentAt})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	retention := NewSentMessageRetention(client, models.CacheRetentionPolicy{TTL: time.Hour, MaxEntries: 3, TrimInterval: time.Minute})
+	retention.now = func() time.Time { return now }
+	// every limit takes more than one batch
+	retention.batchSize = 2
+
+	expired, overflow, err := retention.Trim(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expired != 2 || overflow != 3 {
+		t.Fatalf("expected 2 expired and 3 overflow evictions, got %d and %d", expired, overflow)
+	}
+	members, err := client.ZRange(ctx, sentMessageIndexKey, 0, -1).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(members) != "[provider-5 provider-6 provider-7]" {
+		t.Fatalf("expected the 3 most recent messages to be kept, got %v", members)
+	}
+	for i := range sentAts {
+		exists, err2 := client.Exists(ctx, sentMessageKey(fmt.Sprintf("provider-%d", i))).Result()
+		if err2 != nil {
+			t.Fatal(err2)
+		}
+		if kept := i >= 5; (exists == 1) != kept {
+			t.Fatalf("provider-%d: expected the hash to be kept %v, got %d", i, kept, exists)
+		}
+	}
+
+	expired, overflow, err = retention.Trim(ctx)
+	if err != nil || expired != 0 || overflow != 0 {
+		t.Fatalf("expected nothing left to trim, got %d, %d, %v", expired, overflow, err)
+	}
+	status := retention.Status()
+	if status.EvictedExpired != 2 || status.EvictedOverflow != 3 || status.LastTrimAt == nil || !status.LastTrimAt.Equal(now) {
+		t.Fatalf("unexpected status %+v", status)
+	}
+}
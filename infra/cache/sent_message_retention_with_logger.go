@@ -0,0 +1,40 @@
+package cache
+
+import (
+	"context"
+	"log/slog"
+
+	"auto-message-sender/internal/models"
+)
+
+var _ sentMessageRetention = (*SentMessageRetentionWithLogger)(nil)
+
+type SentMessageRetentionWithLogger struct {
+	logger      *slog.Logger
+	baseService sentMessageRetention
+}
+
+func NewSentMessageRetentionWithLogger(logger *slog.Logger, baseService sentMessageRetention) *SentMessageRetentionWithLogger {
+	return &SentMessageRetentionWithLogger{
+		logger:      logger,
+		baseService: baseService,
+	}
+}
+
+func (s *SentMessageRetentionWithLogger) Trim(ctx context.Context) (int64, int64, error) {
+	expired, overflow, err := s.baseService.Trim(ctx)
+	if err != nil {
+		s.logger.Error("SentMessageRetentionWithLogger.Trim error:", "error", err, "expired", expired, "overflow", overflow)
+		return expired, overflow, err
+	}
+	if expired == 0 && overflow == 0 {
+		s.logger.Debug("SentMessageRetentionWithLogger.Trim success but nothing to evict")
+		return expired, overflow, nil
+	}
+	s.logger.Info("SentMessageRetentionWithLogger.Trim evicted sent messages:", "expired", expired, "overflow", overflow)
+	return expired, overflow, nil
+}
+
+func (s *SentMessageRetentionWithLogger) Status() models.CacheRetentionStatus {
+	return s.baseService.Status()
+}
@@ -1,8 +1,10 @@
 package cache
 
 import (
+	"cmp"
 	"context"
-	"fmt"
+	"errors"
+	"time"
 
 	"auto-message-sender/internal/models"
 
@@ -11,31 +13,137 @@ import (
 
 type setCache interface {
 	Set(ctx context.Context, message models.MessageSenderResponse) error
+	SetDeliveryStatus(ctx context.Context, report models.DeliveryReport) error
 }
 
 var _ setCache = (*SetCache)(nil)
 
+// errEmptyMessageID is returned for a response without a provider message id,
+// all of them would be cached under the same key
+var errEmptyMessageID = errors.New("empty message id")
+
 type SetCache struct {
 	client *redis.Client
+	// ttl is the expiry of the sent message hashes, they do not expire if 0
+	ttl time.Duration
 }
 
-func NewSetCache(client *redis.Client) *SetCache {
+func NewSetCache(client *redis.Client, ttl time.Duration) *SetCache {
 	return &SetCache{
 		client: client,
+		ttl:    ttl,
 	}
 }
 
+// Set caches the sent message and adds it to the index in one transaction, a
+// message without a send time is indexed at the current time
 func (c *SetCache) Set(ctx context.Context, message models.MessageSenderResponse) error {
+	if message.MessageID == "" {
+		return errEmptyMessageID
+	}
 	newData := responseData{
 		Message:   message.Message,
 		MessageID: message.MessageID,
 		SentAt:    message.SentAt,
 	}
-	key := fmt.Sprintf("sent_message_%s", message.MessageID)
-	result := c.client.HSet(ctx, key, newData)
-	err := result.Err()
+	key := sentMessageKey(message.MessageID)
+	score := sentMessageScore(cmp.Or(message.SentAt, time.Now()))
+	_, err := c.client.TxPipelined(ctx, func(pipeline redis.Pipeliner) error {
+		pipeline.HSet(ctx, key, newData)
+		if c.ttl > 0 {
+			pipeline.Expire(ctx, key, c.ttl)
+		}
+		pipeline.ZAdd(ctx, sentMessageIndexKey, redis.Z{Score: score, Member: message.MessageID})
+		return nil
+	})
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+// BuildIndex adds the sent messages cached before the index existed to the
+// index, it does nothing if the index exists. The keys are read with SCAN so
+// Redis is not blocked.
+func (c *SetCache) BuildIndex(ctx context.Context) (int64, error) {
+	exists, err := c.client.Exists(ctx, sentMessageIndexKey).Result()
+	if err != nil || exists > 0 {
+		return 0, err
+	}
+	var indexed int64
+	iterator := c.client.ScanType(ctx, 0, sentMessageKey("*"), 1000, "hash").Iterator()
+	keys := make([]string, 0, 1000)
+	for iterator.Next(ctx) {
+		keys = append(keys, iterator.Val())
+		if len(keys) < cap(keys) {
+			continue
+		}
+		count, err2 := c.indexKeys(ctx, keys)
+		if err2 != nil {
+			return indexed, err2
+		}
+		indexed += count
+		keys = keys[:0]
+	}
+	if iterator.Err() != nil {
+		return indexed, iterator.Err()
+	}
+	count, err := c.indexKeys(ctx, keys)
+	return indexed + count, err
+}
+
+// indexKeys reads the send time of the sent message hashes in keys and adds them
+// to the index, hashes without a send time are left out
+func (c *SetCache) indexKeys(ctx context.Context, keys []string) (int64, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	pipeline := c.client.Pipeline()
+	commands := make([]*redis.SliceCmd, 0, len(keys))
+	for _, key := range keys {
+		commands = append(commands, pipeline.HMGet(ctx, key, "message_id", "sent_at"))
+	}
+	_, err := pipeline.Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+	members := make([]redis.Z, 0, len(keys))
+	for _, command := range commands {
+		var data responseData
+		err = command.Scan(&data)
+		if err != nil {
+			return 0, err
+		}
+		if data.SentAt.IsZero() {
+			continue
+		}
+		members = append(members, redis.Z{Score: sentMessageScore(data.SentAt), Member: data.MessageID})
+	}
+	if len(members) == 0 {
+		return 0, nil
+	}
+	return c.client.ZAddNX(ctx, sentMessageIndexKey, members...).Result()
+}
+
+// setDeliveryStatusScript sets the fields in ARGV[2:] on the hash KEYS[1] if it
+// exists and restarts its expiry of ARGV[1] milliseconds, 0 keeps no expiry
+var setDeliveryStatusScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+redis.call('HSET', KEYS[1], unpack(ARGV, 2))
+if tonumber(ARGV[1]) > 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return 1
+`)
+
+// SetDeliveryStatus adds the delivery state to a sent message that is still cached
+func (c *SetCache) SetDeliveryStatus(ctx context.Context, report models.DeliveryReport) error {
+	key := sentMessageKey(report.ProviderMessageID)
+	return setDeliveryStatusScript.Run(ctx, c.client, []string{key},
+		c.ttl.Milliseconds(),
+		"delivery_status", report.Status,
+		"delivery_status_at", report.Timestamp.UTC().Format(time.RFC3339Nano),
+	).Err()
+}
@@ -0,0 +1,49 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"auto-message-sender/internal/models"
+)
+
+func TestSetCacheRejectsEmptyMessageID(t *testing.T) {
+	// the client is not reached, nil would panic otherwise
+	err := NewSetCache(nil, 0).Set(context.Background(), models.MessageSenderResponse{Message: "Accepted"})
+	if !errors.Is(err, errEmptyMessageID) {
+		t.Fatalf("expected errEmptyMessageID, got %v", err)
+	}
+}
+
+func TestSetDeliveryStatusUpdatesCachedMessagesOnly(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	setCache := NewSetCache(client, time.Hour)
+	sentAt := time.Date(2025, 11, 13, 10, 0, 0, 0, time.UTC)
+	err := setCache.Set(ctx, models.MessageSenderResponse{Message: "Accepted", MessageID: "provider-1", SentAt: sentAt})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deliveredAt := sentAt.Add(time.Minute)
+	for _, messageID := range []string{"provider-1", "provider-2"} {
+		err = setCache.SetDeliveryStatus(ctx, models.DeliveryReport{ProviderMessageID: messageID, Status: "delivered", Timestamp: deliveredAt})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	getListCache := NewGetListCache(client)
+	message, ok, err := getListCache.Get(ctx, "provider-1")
+	if err != nil || !ok {
+		t.Fatalf("expected the cached message, got %v, %v", ok, err)
+	}
+	if message.Message != "Accepted" || message.DeliveryStatus != "delivered" || message.DeliveryStatusAt == nil || !message.DeliveryStatusAt.Equal(deliveredAt) {
+		t.Fatalf("expected the delivery status to be added, got %+v", message)
+	}
+	_, ok, err = getListCache.Get(ctx, "provider-2")
+	if err != nil || ok {
+		t.Fatalf("expected a message that is not cached to stay uncached, got %v, %v", ok, err)
+	}
+}
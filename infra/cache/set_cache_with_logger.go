@@ -30,3 +30,13 @@ func (s *SetCacheWithLogger) Set(ctx context.Context, message models.MessageSend
 	s.logger.Debug("SetCacheWithLogger.Set success:", "messageID", message.MessageID)
 	return nil
 }
+
+func (s *SetCacheWithLogger) SetDeliveryStatus(ctx context.Context, report models.DeliveryReport) error {
+	err := s.baseService.SetDeliveryStatus(ctx, report)
+	if err != nil {
+		s.logger.Error("SetCacheWithLogger.SetDeliveryStatus error:", "error", err)
+		return err
+	}
+	s.logger.Debug("SetCacheWithLogger.SetDeliveryStatus success:", "messageID", report.ProviderMessageID, "status", report.Status)
+	return nil
+}
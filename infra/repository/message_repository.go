@@ -1,7 +1,15 @@
 package repository
 
 import (
+	"cmp"
 	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 
@@ -11,57 +19,542 @@ import (
 type messageRepository interface {
 	GetUnsentMessages(ctx context.Context, limit int) ([]models.Message, error)
 	UpdateMessageStatus(ctx context.Context, messageID, sendingStatus string) error
+	MarkMessageSent(ctx context.Context, attempts []models.MessageAttempt) error
+	RecordProviderMessageID(ctx context.Context, messageID, provider, providerMessageID string) error
+	RecordDeliveryReport(ctx context.Context, report models.DeliveryReport) (string, error)
+	CreateMessage(ctx context.Context, message models.Message) (string, error)
+	ImportMessages(ctx context.Context, messages iter.Seq2[models.Message, error]) (int64, error)
+	ReleaseExpiredLeases(ctx context.Context, leaseTimeout time.Duration, maxAttempts int) (released int64, failed int64, err error)
+	RenewLease(ctx context.Context, messageID string) error
+	ScheduleMessageRetry(ctx context.Context, attempts []models.MessageAttempt, delay time.Duration) error
+	MarkMessageFailed(ctx context.Context, attempts []models.MessageAttempt) error
+	DeferMessage(ctx context.Context, messageID string, delay time.Duration) error
+	NextDueAt(ctx context.Context) (*time.Time, error)
+	ListFailedMessages(ctx context.Context, filter models.FailedMessageFilter) ([]models.Message, error)
+	RetryFailedMessage(ctx context.Context, messageID string) error
+	RetryFailedMessages(ctx context.Context, filter models.FailedMessageFilter) ([]string, error)
+	ListMessageAttempts(ctx context.Context, messageID string) ([]models.MessageAttempt, error)
+	GetMessage(ctx context.Context, messageID string) (models.Message, error)
 }
 
 var _ messageRepository = (*MessagePostgresqlRepository)(nil)
 
+// messageColumns is the column list read by scanMessage
+const messageColumns = "message_id, channel, phone_number, COALESCE(recipient, ''), message_content, sending_status, send_at, attempt_count, replay_count, replayed_attempts, COALESCE(last_error, ''), next_attempt_at, COALESCE(claimed_by, ''), claimed_at, COALESCE(provider_message_id, ''), COALESCE(provider, ''), provider_accepted_at, COALESCE(delivery_status, ''), delivery_status_at, COALESCE(delivery_error_code, ''), created_at, updated_at"
+
+func scanMessage(row pgx.Row) (models.Message, error) {
+	var msg models.Message
+	err := row.Scan(
+		&msg.MessageID,
+		&msg.Channel,
+		&msg.PhoneNumber,
+		&msg.Recipient,
+		&msg.MessageContent,
+		&msg.SendingStatus,
+		&msg.SendAt,
+		&msg.AttemptCount,
+		&msg.ReplayCount,
+		&msg.ReplayedAttempts,
+		&msg.LastError,
+		&msg.NextAttemptAt,
+		&msg.ClaimedBy,
+		&msg.ClaimedAt,
+		&msg.ProviderMessageID,
+		&msg.Provider,
+		&msg.ProviderAcceptedAt,
+		&msg.DeliveryStatus,
+		&msg.DeliveryStatusAt,
+		&msg.DeliveryErrorCode,
+		&msg.CreatedAt,
+		&msg.UpdatedAt,
+	)
+	return msg, err
+}
+
 type MessagePostgresqlRepository struct {
+	// mu serializes access to conn, pgx.Conn is not safe for concurrent use
+	mu   sync.Mutex
 	conn *pgx.Conn
+	// instanceID owns the leases of the messages claimed by this repository
+	instanceID string
 }
 
-func NewMessagePostgresqlRepository(conn *pgx.Conn) *MessagePostgresqlRepository {
+func NewMessagePostgresqlRepository(conn *pgx.Conn, instanceID string) *MessagePostgresqlRepository {
 	return &MessagePostgresqlRepository{
-		conn: conn,
+		conn:       conn,
+		instanceID: instanceID,
 	}
 }
 
+// GetUnsentMessages leases up to limit due waiting messages to this instance,
+// earliest due first. Rows locked by another instance are skipped.
 func (r *MessagePostgresqlRepository) GetUnsentMessages(ctx context.Context, limit int) ([]models.Message, error) {
-	tx, err := r.conn.BeginTx(ctx, pgx.TxOptions{})
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rows, err := r.conn.Query(ctx,
+		`UPDATE messages
+		SET sending_status = 'pending', claimed_by = $2, claimed_at = NOW(), attempt_count = attempt_count + 1, updated_at = NOW()
+		WHERE message_id IN (
+			SELECT message_id FROM messages
+			WHERE sending_status = 'waiting'
+				AND (next_attempt_at IS NULL OR next_attempt_at <= NOW())
+				AND (send_at IS NULL OR send_at <= NOW())
+			ORDER BY COALESCE(send_at, created_at), created_at, message_id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+messageColumns,
+		limit,
+		r.instanceID,
+	)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
+	var messages []models.Message
+	for rows.Next() {
+		msg, err2 := scanMessage(rows)
+		if err2 != nil {
+			return nil, err2
+		}
+		messages = append(messages, msg)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	// RETURNING does not keep the order of the sub select
+	slices.SortFunc(messages, func(a, b models.Message) int {
+		return cmp.Or(
+			dueAt(a).Compare(dueAt(b)),
+			a.CreatedAt.Compare(b.CreatedAt),
+			strings.Compare(a.MessageID, b.MessageID),
+		)
+	})
+	return messages, nil
+}
+
+func (r *MessagePostgresqlRepository) UpdateMessageStatus(ctx context.Context, messageID, sendingStatus string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, err := r.conn.Exec(ctx, "UPDATE messages SET sending_status = $1, updated_at = NOW() WHERE message_id = $2", sendingStatus, messageID)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// MarkMessageSent marks a message as sent and records the attempts of the send
+// in the same transaction, the last one sent it
+func (r *MessagePostgresqlRepository) MarkMessageSent(ctx context.Context, attempts []models.MessageAttempt) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	attempt, err := lastAttempt(attempts)
+	if err != nil {
+		return err
+	}
+	return r.recordAttempts(ctx, attempts,
+		"UPDATE messages SET sending_status = 'sent', updated_at = NOW() WHERE message_id = $1 AND "+leasedCondition,
+		attempt.MessageID,
+		r.instanceID,
+	)
+}
+
+// RecordProviderMessageID stores the provider and the id it returned for an
+// accepted message, it is the dedupe record checked before a message is sent again
+func (r *MessagePostgresqlRepository) RecordProviderMessageID(ctx context.Context, messageID, provider, providerMessageID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, err := r.conn.Exec(ctx,
+		"UPDATE messages SET provider_message_id = $1, provider = NULLIF($2, ''), provider_accepted_at = NOW(), updated_at = NOW() WHERE message_id = $3",
+		providerMessageID,
+		provider,
+		messageID,
+	)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// RecordDeliveryReport stores the delivery state and returns the message id,
+// ErrStaleDeliveryReport is returned for an older report
+func (r *MessagePostgresqlRepository) RecordDeliveryReport(ctx context.Context, report models.DeliveryReport) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var messageID string
+	err := r.conn.QueryRow(ctx,
+		"UPDATE messages SET delivery_status = $2, delivery_status_at = $3, delivery_error_code = NULLIF($4, ''), updated_at = NOW() "+
+			"WHERE provider = $5 AND provider_message_id = $1 AND (delivery_status_at IS NULL OR delivery_status_at <= $3) RETURNING message_id",
+		report.ProviderMessageID,
+		report.Status,
+		report.Timestamp.UTC(),
+		report.ErrorCode,
+		report.Provider,
+	).Scan(&messageID)
+	if err == nil {
+		return messageID, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return "", err
+	}
+	err = r.conn.QueryRow(ctx,
+		"SELECT message_id FROM messages WHERE provider = $1 AND provider_message_id = $2 LIMIT 1",
+		report.Provider,
+		report.ProviderMessageID,
+	).Scan(&messageID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", models.ErrMessageNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return messageID, models.ErrStaleDeliveryReport
+}
+
+// CreateMessage validates and stores a new message with the waiting status and
+// returns the generated message id
+func (r *MessagePostgresqlRepository) CreateMessage(ctx context.Context, message models.Message) (string, error) {
+	err := message.Validate()
+	if err != nil {
+		return "", err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var messageID string
+	err = r.conn.QueryRow(ctx,
+		"INSERT INTO messages (channel, phone_number, recipient, message_content, send_at, sending_status, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, 'waiting', NOW(), NOW()) RETURNING message_id",
+		message.MessageChannel(),
+		message.PhoneNumber,
+		message.Recipient,
+		message.MessageContent,
+		utcTime(message.SendAt),
+	).Scan(&messageID)
+	if err != nil {
+		return "", fmt.Errorf("insert message error: %w", err)
+	}
+	return messageID, nil
+}
+
+// ImportMessages streams messages into the messages table with COPY inside a
+// single transaction. If the sequence yields an error or a message is invalid the
+// whole import is rolled back. The connection is held while the sequence is
+// read, it must not wait on a client.
+func (r *MessagePostgresqlRepository) ImportMessages(ctx context.Context, messages iter.Seq2[models.Message, error]) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	tx, err := r.conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return 0, err
+	}
 	defer tx.Rollback(ctx)
-	messages, err := r.getUnsentMessages(ctx, tx, limit)
+	next, stop := iter.Pull2(messages)
+	defer stop()
+	now := time.Now().UTC()
+	// pgx reports a failing source as a postgres error, sourceErr keeps the
+	// original one so callers can still inspect it
+	var sourceErr error
+	source := pgx.CopyFromFunc(func() ([]any, error) {
+		message, err2, ok := next()
+		if !ok {
+			return nil, nil
+		}
+		if err2 == nil {
+			err2 = message.Validate()
+		}
+		if err2 != nil {
+			sourceErr = err2
+			return nil, err2
+		}
+		return []any{message.MessageChannel(), message.PhoneNumber, message.Recipient, message.MessageContent, utcTime(message.SendAt), "waiting", now, now}, nil
+	})
+	count, err := tx.CopyFrom(ctx,
+		pgx.Identifier{"messages"},
+		[]string{"channel", "phone_number", "recipient", "message_content", "send_at", "sending_status", "created_at", "updated_at"},
+		source,
+	)
+	if sourceErr != nil {
+		return 0, fmt.Errorf("read messages error: %w", sourceErr)
+	}
 	if err != nil {
-		return nil, err
+		return 0, fmt.Errorf("copy messages error: %w", err)
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// ReleaseExpiredLeases returns messages leased longer than leaseTimeout to the
+// waiting status, or fails them after maxAttempts claims
+func (r *MessagePostgresqlRepository) ReleaseExpiredLeases(ctx context.Context, leaseTimeout time.Duration, maxAttempts int) (int64, int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rows, err := r.conn.Query(ctx,
+		`UPDATE messages
+		SET sending_status = CASE WHEN attempt_count - replayed_attempts >= $2 THEN 'failed'::sending_status ELSE 'waiting'::sending_status END,
+			updated_at = NOW()
+		WHERE sending_status = 'pending'
+			AND (claimed_at IS NULL OR claimed_at < NOW() - make_interval(secs => $1))
+		RETURNING sending_status`,
+		leaseTimeout.Seconds(),
+		maxAttempts,
+	)
+	if err != nil {
+		return 0, 0, err
+	}
+	var released, failed int64
+	for rows.Next() {
+		var sendingStatus string
+		err2 := rows.Scan(&sendingStatus)
+		if err2 != nil {
+			rows.Close()
+			return 0, 0, err2
+		}
+		if sendingStatus == "failed" {
+			failed++
+			continue
+		}
+		released++
+	}
+	if rows.Err() != nil {
+		return 0, 0, rows.Err()
+	}
+	return released, failed, nil
+}
+
+// RenewLease restarts the lease of a message, ErrLeaseLost if it is not ours
+func (r *MessagePostgresqlRepository) RenewLease(ctx context.Context, messageID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	tag, err := r.conn.Exec(ctx, "UPDATE messages SET claimed_at = NOW() WHERE message_id = $1 AND "+leasedCondition, messageID, r.instanceID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return models.ErrLeaseLost
+	}
+	return nil
+}
+
+// ScheduleMessageRetry returns a message to the waiting status after a failed
+// send, it is not picked up again before delay passes. The attempts are
+// recorded in the same transaction and the error of the last one is kept as the
+// last error.
+func (r *MessagePostgresqlRepository) ScheduleMessageRetry(ctx context.Context, attempts []models.MessageAttempt, delay time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	attempt, err := lastAttempt(attempts)
+	if err != nil {
+		return err
+	}
+	return r.recordAttempts(ctx, attempts,
+		"UPDATE messages SET sending_status = 'waiting', last_error = $3, next_attempt_at = NOW() + make_interval(secs => $4), updated_at = NOW() WHERE message_id = $1 AND "+leasedCondition,
+		attempt.MessageID,
+		r.instanceID,
+		attempt.Error,
+		delay.Seconds(),
+	)
+}
+
+// MarkMessageFailed marks a message that ran out of attempts or failed
+// permanently as failed, the attempts are recorded in the same transaction
+func (r *MessagePostgresqlRepository) MarkMessageFailed(ctx context.Context, attempts []models.MessageAttempt) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	attempt, err := lastAttempt(attempts)
+	if err != nil {
+		return err
+	}
+	return r.recordAttempts(ctx, attempts,
+		"UPDATE messages SET sending_status = 'failed', last_error = $3, next_attempt_at = NULL, updated_at = NOW() WHERE message_id = $1 AND "+leasedCondition,
+		attempt.MessageID,
+		r.instanceID,
+		attempt.Error,
+	)
+}
+
+// lastAttempt returns the attempt that decides the status change, the attempts
+// before it are the failover endpoints that failed during the same send
+func lastAttempt(attempts []models.MessageAttempt) (models.MessageAttempt, error) {
+	if len(attempts) == 0 {
+		return models.MessageAttempt{}, errors.New("no message attempt to record")
+	}
+	return attempts[len(attempts)-1], nil
+}
+
+// leasedCondition matches the message $1 while it is pending with the lease of instance $2
+const leasedCondition = "sending_status = 'pending' AND claimed_by = $2"
+
+// recordAttempts inserts attempts and runs statusChange in one transaction, mu
+// must be held
+func (r *MessagePostgresqlRepository) recordAttempts(ctx context.Context, attempts []models.MessageAttempt, statusChange string, args ...any) error {
+	tx, err := r.conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	for _, attempt := range attempts {
+		_, err = tx.Exec(ctx,
+			"INSERT INTO message_attempts (message_id, attempt_number, provider, endpoint, status_code, latency_ms, provider_message_id, error, created_at) "+
+				"VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, 0), $6, NULLIF($7, ''), NULLIF($8, ''), $9)",
+			attempt.MessageID,
+			attempt.AttemptNumber,
+			attempt.Provider,
+			attempt.Endpoint,
+			attempt.StatusCode,
+			attempt.LatencyMS,
+			attempt.ProviderMessageID,
+			attempt.Error,
+			cmp.Or(attempt.CreatedAt, time.Now()).UTC(),
+		)
+		if err != nil {
+			return fmt.Errorf("insert message attempt error: %w", err)
+		}
+	}
+	tag, err := tx.Exec(ctx, statusChange, args...)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return models.ErrLeaseLost
+	}
+	return tx.Commit(ctx)
+}
+
+// GetMessage returns a single message, ErrMessageNotFound is returned for an
+// unknown message id
+func (r *MessagePostgresqlRepository) GetMessage(ctx context.Context, messageID string) (models.Message, error) {
+	if !models.ValidMessageID(messageID) {
+		return models.Message{}, models.ErrMessageNotFound
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	message, err := scanMessage(r.conn.QueryRow(ctx, "SELECT "+messageColumns+" FROM messages WHERE message_id = $1", messageID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.Message{}, models.ErrMessageNotFound
 	}
-	err = r.setMessageStatusToPending(ctx, tx, messages)
+	if err != nil {
+		return models.Message{}, err
+	}
+	return message, nil
+}
+
+// ListMessageAttempts returns the send attempts of a message, oldest first
+func (r *MessagePostgresqlRepository) ListMessageAttempts(ctx context.Context, messageID string) ([]models.MessageAttempt, error) {
+	if !models.ValidMessageID(messageID) {
+		return nil, models.ErrMessageNotFound
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rows, err := r.conn.Query(ctx,
+		"SELECT attempt_id, message_id, attempt_number, COALESCE(provider, ''), COALESCE(endpoint, ''), COALESCE(status_code, 0), latency_ms, "+
+			"COALESCE(provider_message_id, ''), COALESCE(error, ''), created_at FROM message_attempts WHERE message_id = $1 ORDER BY attempt_id",
+		messageID,
+	)
 	if err != nil {
 		return nil, err
 	}
-	err = tx.Commit(ctx)
+	defer rows.Close()
+	attempts := make([]models.MessageAttempt, 0)
+	for rows.Next() {
+		var attempt models.MessageAttempt
+		err = rows.Scan(
+			&attempt.AttemptID,
+			&attempt.MessageID,
+			&attempt.AttemptNumber,
+			&attempt.Provider,
+			&attempt.Endpoint,
+			&attempt.StatusCode,
+			&attempt.LatencyMS,
+			&attempt.ProviderMessageID,
+			&attempt.Error,
+			&attempt.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, attempt)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return attempts, nil
+}
+
+// DeferMessage returns a claimed message to the waiting status for delay
+// without counting the claim as an attempt
+func (r *MessagePostgresqlRepository) DeferMessage(ctx context.Context, messageID string, delay time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	tag, err := r.conn.Exec(ctx,
+		"UPDATE messages SET sending_status = 'waiting', attempt_count = GREATEST(attempt_count - 1, replayed_attempts), next_attempt_at = NOW() + make_interval(secs => $3), updated_at = NOW() WHERE message_id = $1 AND "+leasedCondition,
+		messageID,
+		r.instanceID,
+		delay.Seconds(),
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return models.ErrLeaseLost
+	}
+	return nil
+}
+
+// NextDueAt returns when the first waiting message that is not due yet becomes due, or nil
+func (r *MessagePostgresqlRepository) NextDueAt(ctx context.Context) (*time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var nextDueAt *time.Time
+	err := r.conn.QueryRow(ctx,
+		"SELECT MIN(GREATEST(send_at, next_attempt_at)) FROM messages WHERE sending_status = 'waiting' AND (send_at > NOW() OR next_attempt_at > NOW())",
+	).Scan(&nextDueAt)
 	if err != nil {
 		return nil, err
 	}
-	return messages, nil
+	return nextDueAt, nil
+}
+
+// failedMessageCondition builds the WHERE clause and its arguments that select the
+// failed messages matching filter
+func failedMessageCondition(filter models.FailedMessageFilter) (string, []any) {
+	conditions := []string{"sending_status = 'failed'"}
+	var args []any
+	if filter.PhoneNumber != "" {
+		args = append(args, filter.PhoneNumber)
+		conditions = append(conditions, fmt.Sprintf("phone_number = $%d", len(args)))
+	}
+	if filter.ErrorContains != "" {
+		args = append(args, filter.ErrorContains)
+		conditions = append(conditions, fmt.Sprintf("strpos(lower(last_error), lower($%d)) > 0", len(args)))
+	}
+	if !filter.Since.IsZero() {
+		args = append(args, filter.Since.UTC())
+		conditions = append(conditions, fmt.Sprintf("updated_at >= $%d", len(args)))
+	}
+	if !filter.Until.IsZero() {
+		args = append(args, filter.Until.UTC())
+		conditions = append(conditions, fmt.Sprintf("updated_at < $%d", len(args)))
+	}
+	return strings.Join(conditions, " AND "), args
 }
 
-func (r *MessagePostgresqlRepository) getUnsentMessages(ctx context.Context, tx pgx.Tx, limit int) ([]models.Message, error) {
-	rows, err := tx.Query(ctx, "SELECT message_id, phone_number, message_content, updated_at, created_at FROM messages WHERE sending_status = 'waiting' LIMIT $1", limit)
+// ListFailedMessages returns the failed messages matching filter, most recently failed first
+func (r *MessagePostgresqlRepository) ListFailedMessages(ctx context.Context, filter models.FailedMessageFilter) ([]models.Message, error) {
+	condition, args := failedMessageCondition(filter)
+	args = append(args, filter.Limit, filter.Offset)
+	query := fmt.Sprintf("SELECT %s FROM messages WHERE %s ORDER BY updated_at DESC, message_id LIMIT $%d OFFSET $%d", messageColumns, condition, len(args)-1, len(args))
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rows, err := r.conn.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
-	var messages []models.Message
+	defer rows.Close()
+	messages := make([]models.Message, 0)
 	for rows.Next() {
-		var msg models.Message
-		err2 := rows.Scan(
-			&msg.MessageID,
-			&msg.PhoneNumber,
-			&msg.MessageContent,
-			&msg.UpdatedAt,
-			&msg.CreatedAt,
-		)
-		msg.SendingStatus = "waiting"
+		msg, err2 := scanMessage(rows)
 		if err2 != nil {
 			return nil, err2
 		}
@@ -73,20 +566,77 @@ func (r *MessagePostgresqlRepository) getUnsentMessages(ctx context.Context, tx
 	return messages, nil
 }
 
-func (r *MessagePostgresqlRepository) setMessageStatusToPending(ctx context.Context, tx pgx.Tx, messages []models.Message) error {
-	for _, message := range messages {
-		_, err := tx.Exec(ctx, "UPDATE messages SET sending_status = 'pending' WHERE message_id = $1", message.MessageID)
-		if err != nil {
-			return err
-		}
+// retryFailedMessageSet is the SET clause that moves a failed message back to the
+// queue. The attempt count and the last error are kept for inspection, the
+// attempts made so far no longer count against the retry limit.
+const retryFailedMessageSet = "sending_status = 'waiting', replay_count = replay_count + 1, replayed_attempts = attempt_count, " +
+	"next_attempt_at = NULL, claimed_by = NULL, claimed_at = NULL, updated_at = NOW()"
+
+// RetryFailedMessage moves a single failed message back to the waiting status
+func (r *MessagePostgresqlRepository) RetryFailedMessage(ctx context.Context, messageID string) error {
+	if !models.ValidMessageID(messageID) {
+		return models.ErrMessageNotFound
 	}
-	return nil
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	tag, err := r.conn.Exec(ctx, "UPDATE messages SET "+retryFailedMessageSet+" WHERE message_id = $1 AND sending_status = 'failed'", messageID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() > 0 {
+		return nil
+	}
+	var exists bool
+	err = r.conn.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM messages WHERE message_id = $1)", messageID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return models.ErrMessageNotFound
+	}
+	return models.ErrMessageNotFailed
 }
 
-func (r *MessagePostgresqlRepository) UpdateMessageStatus(ctx context.Context, messageID, sendingStatus string) error {
-	_, err := r.conn.Exec(ctx, "UPDATE messages SET sending_status = $1 WHERE message_id = $2", sendingStatus, messageID)
+// RetryFailedMessages moves every failed message matching filter back to the
+// waiting status and returns their ids, Limit and Offset of the filter are not
+// applied
+func (r *MessagePostgresqlRepository) RetryFailedMessages(ctx context.Context, filter models.FailedMessageFilter) ([]string, error) {
+	condition, args := failedMessageCondition(filter)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rows, err := r.conn.Query(ctx, "UPDATE messages SET "+retryFailedMessageSet+" WHERE "+condition+" RETURNING message_id", args...)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	messageIDs := make([]string, 0)
+	for rows.Next() {
+		var messageID string
+		err2 := rows.Scan(&messageID)
+		if err2 != nil {
+			rows.Close()
+			return nil, err2
+		}
+		messageIDs = append(messageIDs, messageID)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return messageIDs, nil
+}
+
+// dueAt is the time a message becomes due without a retry delay
+func dueAt(message models.Message) time.Time {
+	if message.SendAt != nil {
+		return *message.SendAt
+	}
+	return message.CreatedAt
+}
+
+// utcTime converts t to UTC, pgx stores a timestamp without its zone
+func utcTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
 }
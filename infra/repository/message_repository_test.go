@@ -0,0 +1,634 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"auto-message-sender/internal/models"
+)
+
+// newTestConn connects to the database in POSTGRESQL_TEST_DSN using a fresh
+// schema created from setupSQL and db/schema, the test is skipped when the
+// variable is not set
+func newTestConn(t *testing.T, setupSQL ...string) func() *pgx.Conn {
+	t.Helper()
+	dsn := os.Getenv("POSTGRESQL_TEST_DSN")
+	if dsn == "" {
+		t.Skip("POSTGRESQL_TEST_DSN is not set, skipping postgresql integration test")
+	}
+	ctx := context.Background()
+	schemaSQL, err := os.ReadFile("../../db/schema/message_repository.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	schemaName := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	adminConn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = adminConn.Exec(ctx, "CREATE SCHEMA "+schemaName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_, _ = adminConn.Exec(ctx, "DROP SCHEMA "+schemaName+" CASCADE")
+		_ = adminConn.Close(ctx)
+	})
+	config, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.RuntimeParams["search_path"] = schemaName
+	connect := func() *pgx.Conn {
+		conn, err2 := pgx.ConnectConfig(ctx, config)
+		if err2 != nil {
+			t.Fatal(err2)
+		}
+		t.Cleanup(func() {
+			_ = conn.Close(ctx)
+		})
+		return conn
+	}
+	conn := connect()
+	for _, sql := range append(setupSQL, string(schemaSQL)) {
+		_, err = conn.Exec(ctx, sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return connect
+}
+
+func TestGetUnsentMessagesConcurrentClaimers(t *testing.T) {
+	connect := newTestConn(t)
+	ctx := context.Background()
+	const messageCount = 500
+	_, err := connect().Exec(ctx,
+		"INSERT INTO messages (phone_number, message_content, sending_status, created_at, updated_at) SELECT '+905558889900', 'message ' || i, 'waiting', NOW(), NOW() FROM generate_series(1, $1) AS i",
+		messageCount,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const claimerCount = 8
+	claimed := make([][]string, claimerCount)
+	errs := make(chan error, claimerCount)
+	wg := sync.WaitGroup{}
+	for i := range claimerCount {
+		repository := NewMessagePostgresqlRepository(connect(), fmt.Sprintf("claimer-%d", i))
+		wg.Go(func() {
+			for {
+				messages, err2 := repository.GetUnsentMessages(ctx, 7)
+				if err2 != nil {
+					errs <- err2
+					return
+				}
+				if len(messages) == 0 {
+					return
+				}
+				for _, message := range messages {
+					if message.SendingStatus != "pending" || message.ClaimedBy != fmt.Sprintf("claimer-%d", i) || message.AttemptCount != 1 {
+						errs <- fmt.Errorf("unexpected claimed message: %+v", message)
+						return
+					}
+					claimed[i] = append(claimed[i], message.MessageID)
+				}
+			}
+		})
+	}
+	wg.Wait()
+	close(errs)
+	for err2 := range errs {
+		t.Fatal(err2)
+	}
+
+	seen := make(map[string]int)
+	for i, messageIDs := range claimed {
+		for _, messageID := range messageIDs {
+			if previous, ok := seen[messageID]; ok {
+				t.Fatalf("message %s claimed by both claimer-%d and claimer-%d", messageID, previous, i)
+			}
+			seen[messageID] = i
+		}
+	}
+	if len(seen) != messageCount {
+		t.Fatalf("expected %d claimed messages, got %d", messageCount, len(seen))
+	}
+}
+
+func TestGetUnsentMessagesScheduled(t *testing.T) {
+	connect := newTestConn(t)
+	ctx := context.Background()
+	repository := NewMessagePostgresqlRepository(connect(), "claimer")
+	now := time.Now()
+	future := now.Add(time.Hour)
+	earlier := now.Add(-2 * time.Hour)
+	var messageIDs []string
+	for _, message := range []models.Message{
+		{PhoneNumber: "+905558889900", MessageContent: "not scheduled"},
+		{PhoneNumber: "+905558889911", MessageContent: "scheduled in the future", SendAt: &future},
+		{PhoneNumber: "+905558889922", MessageContent: "scheduled in the past", SendAt: &earlier},
+	} {
+		messageID, err := repository.CreateMessage(ctx, message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		messageIDs = append(messageIDs, messageID)
+	}
+
+	messages, err := repository.GetUnsentMessages(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 || messages[0].MessageID != messageIDs[2] || messages[1].MessageID != messageIDs[0] {
+		t.Fatalf("expected the past scheduled message before the unscheduled one, got %+v", messages)
+	}
+	nextDueAt, err := repository.NextDueAt(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nextDueAt == nil || nextDueAt.Sub(future).Abs() > time.Millisecond {
+		t.Fatalf("expected next due time %s, got %v", future, nextDueAt)
+	}
+}
+
+func TestRecordProviderMessageIDSurvivesLeaseExpiry(t *testing.T) {
+	connect := newTestConn(t)
+	ctx := context.Background()
+	repository := NewMessagePostgresqlRepository(connect(), "claimer")
+	messageID, err := repository.CreateMessage(ctx, models.Message{PhoneNumber: "+905558889900", MessageContent: "accepted before a crash"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = repository.GetUnsentMessages(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repository.RecordProviderMessageID(ctx, messageID, "webhook", "provider-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the instance crashes before marking the message sent, the lease expires
+	time.Sleep(10 * time.Millisecond)
+	released, _, err := repository.ReleaseExpiredLeases(ctx, 0, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if released != 1 {
+		t.Fatalf("expected 1 released lease, got %d", released)
+	}
+	messages, err := repository.GetUnsentMessages(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 || messages[0].ProviderMessageID != "provider-1" || messages[0].ProviderAcceptedAt == nil {
+		t.Fatalf("expected the provider message id to be returned, got %+v", messages)
+	}
+}
+
+func TestIdempotencyKeyAfterLeaseExpiry(t *testing.T) {
+	connect := newTestConn(t)
+	ctx := context.Background()
+	repository := NewMessagePostgresqlRepository(connect(), "claimer")
+	messageID, err := repository.CreateMessage(ctx, models.Message{PhoneNumber: "+905558889900", MessageContent: "sent before a crash"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claimed, err := repository.GetUnsentMessages(ctx, 10)
+	if err != nil || len(claimed) != 1 {
+		t.Fatalf("expected the message to be claimed, got %+v, %v", claimed, err)
+	}
+	// the provider accepts the message and the instance crashes before the
+	// provider message id is recorded, the lease expires and it is claimed again
+	time.Sleep(10 * time.Millisecond)
+	_, _, err = repository.ReleaseExpiredLeases(ctx, 0, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reclaimed, err := repository.GetUnsentMessages(ctx, 10)
+	if err != nil || len(reclaimed) != 1 {
+		t.Fatalf("expected the message to be claimed again, got %+v, %v", reclaimed, err)
+	}
+	if reclaimed[0].AttemptCount != 2 || reclaimed[0].IdempotencyKey() != claimed[0].IdempotencyKey() {
+		t.Fatalf("expected the second claim to send key %q, got %q", claimed[0].IdempotencyKey(), reclaimed[0].IdempotencyKey())
+	}
+
+	err = repository.MarkMessageFailed(ctx, []models.MessageAttempt{{MessageID: messageID, AttemptNumber: 2, Error: "rejected"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repository.RetryFailedMessage(ctx, messageID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed, err := repository.GetUnsentMessages(ctx, 10)
+	if err != nil || len(replayed) != 1 {
+		t.Fatalf("expected the replayed message to be claimed, got %+v, %v", replayed, err)
+	}
+	if replayed[0].IdempotencyKey() == claimed[0].IdempotencyKey() {
+		t.Fatalf("expected a new key after the replay, got %q", replayed[0].IdempotencyKey())
+	}
+}
+
+func TestReleaseExpiredLeases(t *testing.T) {
+	connect := newTestConn(t)
+	conn := connect()
+	ctx := context.Background()
+	repository := NewMessagePostgresqlRepository(conn, "claimer")
+	var messageIDs []string
+	for _, content := range []string{"expired lease", "expired lease of the last attempt", "active lease"} {
+		messageID, err := repository.CreateMessage(ctx, models.Message{PhoneNumber: "+905558889900", MessageContent: content})
+		if err != nil {
+			t.Fatal(err)
+		}
+		messageIDs = append(messageIDs, messageID)
+	}
+	_, err := repository.GetUnsentMessages(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = conn.Exec(ctx, "UPDATE messages SET claimed_at = NOW() - INTERVAL '1 hour' WHERE message_id = ANY($1::uuid[])", messageIDs[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = conn.Exec(ctx, "UPDATE messages SET attempt_count = 3 WHERE message_id = $1", messageIDs[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	released, failed, err := repository.ReleaseExpiredLeases(ctx, time.Minute, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if released != 1 || failed != 1 {
+		t.Fatalf("expected 1 released and 1 failed lease, got %d and %d", released, failed)
+	}
+	for i, want := range []string{"waiting", "failed", "pending"} {
+		message, err2 := repository.GetMessage(ctx, messageIDs[i])
+		if err2 != nil {
+			t.Fatal(err2)
+		}
+		if message.SendingStatus != want {
+			t.Fatalf("message %d: expected %s, got %s", i, want, message.SendingStatus)
+		}
+	}
+}
+
+func TestSchemaUpgrade(t *testing.T) {
+	// the schema of the first version
+	connect := newTestConn(t, `
+CREATE TYPE sending_status AS ENUM ('waiting', 'pending', 'sent', 'failed');
+CREATE TABLE messages
+(
+    message_id      UUID PRIMARY KEY,
+    phone_number    VARCHAR(20),
+    message_content VARCHAR(160),
+    sending_status  sending_status,
+    created_at      TIMESTAMP,
+    updated_at      TIMESTAMP
+);
+INSERT INTO messages (message_id, phone_number, message_content, sending_status, created_at, updated_at)
+VALUES (gen_random_uuid(), '+905558889900', 'old', 'waiting', NOW(), NOW());`)
+	ctx := context.Background()
+	schemaSQL, err := os.ReadFile("../../db/schema/message_repository.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = connect().Exec(ctx, string(schemaSQL))
+	if err != nil {
+		t.Fatalf("schema is not re-runnable: %v", err)
+	}
+	repository := NewMessagePostgresqlRepository(connect(), "claimer")
+	_, err = repository.CreateMessage(ctx, models.Message{
+		Channel:        models.ChannelEmail,
+		Recipient:      "user@example.com",
+		MessageContent: strings.Repeat("a", 200),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messages, err := repository.GetUnsentMessages(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(messages))
+	}
+	if messages[0].Channel != models.ChannelSMS || messages[0].MessageContent != "old" {
+		t.Fatalf("expected the old message as sms, got %+v", messages[0])
+	}
+}
+
+func TestCreateMessageChannels(t *testing.T) {
+	connect := newTestConn(t)
+	ctx := context.Background()
+	repository := NewMessagePostgresqlRepository(connect(), "claimer")
+	want := []models.Message{
+		{Channel: models.ChannelSMS, PhoneNumber: "+905558889900", MessageContent: "sms"},
+		{Channel: models.ChannelEmail, Recipient: "user@example.com", MessageContent: "email"},
+		{Channel: models.ChannelChat, MessageContent: "chat"},
+	}
+	for _, message := range want {
+		_, err := repository.CreateMessage(ctx, message)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	messages, err := repository.GetUnsentMessages(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != len(want) {
+		t.Fatalf("expected %d messages, got %d", len(want), len(messages))
+	}
+	for i, message := range messages {
+		if message.Channel != want[i].Channel || message.PhoneNumber != want[i].PhoneNumber || message.Recipient != want[i].Recipient {
+			t.Fatalf("message %d: expected %+v, got %+v", i, want[i], message)
+		}
+	}
+}
+
+func TestRecordDeliveryReport(t *testing.T) {
+	connect := newTestConn(t)
+	ctx := context.Background()
+	repository := NewMessagePostgresqlRepository(connect(), "claimer")
+	messageID, err := repository.CreateMessage(ctx, models.Message{PhoneNumber: "+905558889900", MessageContent: "delivered later"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repository.RecordProviderMessageID(ctx, messageID, "webhook", "provider-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// another provider returned the same id for an email
+	emailID, err := repository.CreateMessage(ctx, models.Message{Channel: models.ChannelEmail, Recipient: "user@example.com", MessageContent: "delivered by another provider"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repository.RecordProviderMessageID(ctx, emailID, "smtp", "provider-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+
+	reportedID, err := repository.RecordDeliveryReport(ctx, models.DeliveryReport{Provider: "webhook", ProviderMessageID: "provider-1", Status: models.DeliveryStatusUndelivered, Timestamp: now, ErrorCode: "30003"})
+	if err != nil || reportedID != messageID {
+		t.Fatalf("expected %s, got %q, %v", messageID, reportedID, err)
+	}
+	_, err = repository.RecordDeliveryReport(ctx, models.DeliveryReport{Provider: "webhook", ProviderMessageID: "provider-1", Status: models.DeliveryStatusDelivered, Timestamp: now.Add(-time.Minute)})
+	if !errors.Is(err, models.ErrStaleDeliveryReport) {
+		t.Fatalf("expected ErrStaleDeliveryReport, got %v", err)
+	}
+	reportedID, err = repository.RecordDeliveryReport(ctx, models.DeliveryReport{Provider: "smtp", ProviderMessageID: "provider-1", Status: models.DeliveryStatusDelivered, Timestamp: now.Add(-time.Minute)})
+	if err != nil || reportedID != emailID {
+		t.Fatalf("expected %s of the other provider, got %q, %v", emailID, reportedID, err)
+	}
+	for _, report := range []models.DeliveryReport{
+		{Provider: "webhook", ProviderMessageID: "provider-2", Status: models.DeliveryStatusDelivered, Timestamp: now},
+		{Provider: "chat_webhook", ProviderMessageID: "provider-1", Status: models.DeliveryStatusDelivered, Timestamp: now},
+	} {
+		_, err = repository.RecordDeliveryReport(ctx, report)
+		if !errors.Is(err, models.ErrMessageNotFound) {
+			t.Fatalf("%+v: expected ErrMessageNotFound, got %v", report, err)
+		}
+	}
+
+	message, err := repository.GetMessage(ctx, messageID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message.Provider != "webhook" || message.DeliveryStatus != models.DeliveryStatusUndelivered ||
+		message.DeliveryStatusAt == nil || !message.DeliveryStatusAt.Equal(now) || message.DeliveryErrorCode != "30003" {
+		t.Fatalf("expected the undelivered state, got %+v", message)
+	}
+}
+
+func TestMessageAttempts(t *testing.T) {
+	connect := newTestConn(t)
+	ctx := context.Background()
+	repository := NewMessagePostgresqlRepository(connect(), "claimer")
+	messageID, err := repository.CreateMessage(ctx, models.Message{PhoneNumber: "+905558889900", MessageContent: "sent at the second attempt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = repository.GetUnsentMessages(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repository.ScheduleMessageRetry(ctx, []models.MessageAttempt{{
+		MessageID:     messageID,
+		AttemptNumber: 1,
+		Provider:      "webhook",
+		Endpoint:      "primary",
+		StatusCode:    503,
+		LatencyMS:     120,
+		Error:         "endpoint primary: webhook message sender unexpected response code error: 503",
+	}}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = repository.GetUnsentMessages(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the second send fails over from primary to backup
+	err = repository.MarkMessageSent(ctx, []models.MessageAttempt{
+		{
+			MessageID:     messageID,
+			AttemptNumber: 2,
+			Provider:      "webhook",
+			Endpoint:      "primary",
+			StatusCode:    502,
+			LatencyMS:     40,
+			Error:         "endpoint primary: webhook message sender unexpected response code error: 502",
+		},
+		{
+			MessageID:         messageID,
+			AttemptNumber:     2,
+			Provider:          "webhook",
+			Endpoint:          "backup",
+			StatusCode:        202,
+			LatencyMS:         80,
+			ProviderMessageID: "provider-1",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attempts, err := repository.ListMessageAttempts(ctx, messageID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attempts) != 3 {
+		t.Fatalf("expected 3 attempts, got %+v", attempts)
+	}
+	if attempts[0].Endpoint != "primary" || attempts[0].StatusCode != 503 || attempts[0].Error == "" || attempts[0].ProviderMessageID != "" {
+		t.Fatalf("unexpected first attempt %+v", attempts[0])
+	}
+	if attempts[1].AttemptNumber != 2 || attempts[1].Endpoint != "primary" || attempts[1].StatusCode != 502 || attempts[1].Error == "" {
+		t.Fatalf("unexpected failed over attempt %+v", attempts[1])
+	}
+	if attempts[2].AttemptNumber != 2 || attempts[2].Endpoint != "backup" || attempts[2].ProviderMessageID != "provider-1" ||
+		attempts[2].LatencyMS != 80 || attempts[2].Error != "" || attempts[2].CreatedAt.IsZero() {
+		t.Fatalf("unexpected last attempt %+v", attempts[2])
+	}
+	message, err := repository.GetMessage(ctx, messageID)
+	if err != nil || message.SendingStatus != "sent" || message.LastError == "" {
+		t.Fatalf("expected sent with the error of the first attempt, got %+v, %v", message, err)
+	}
+	_, err = repository.GetMessage(ctx, "00000000-0000-0000-0000-000000000000")
+	if !errors.Is(err, models.ErrMessageNotFound) {
+		t.Fatalf("expected ErrMessageNotFound, got %v", err)
+	}
+
+	// attempts of unknown messages are rejected by the foreign key
+	err = repository.MarkMessageFailed(ctx, []models.MessageAttempt{{MessageID: "00000000-0000-0000-0000-000000000000", Error: "unknown"}})
+	if err == nil {
+		t.Fatal("expected an error for an attempt of an unknown message")
+	}
+	err = repository.MarkMessageFailed(ctx, nil)
+	if err == nil {
+		t.Fatal("expected an error without attempts")
+	}
+	_, err = repository.ListMessageAttempts(ctx, "not-a-uuid")
+	if !errors.Is(err, models.ErrMessageNotFound) {
+		t.Fatalf("expected ErrMessageNotFound, got %v", err)
+	}
+}
+
+func TestLeaseLost(t *testing.T) {
+	connect := newTestConn(t)
+	ctx := context.Background()
+	repository := NewMessagePostgresqlRepository(connect(), "claimer")
+	otherRepository := NewMessagePostgresqlRepository(connect(), "other-claimer")
+	messageID, err := repository.CreateMessage(ctx, models.Message{PhoneNumber: "+905558889900", MessageContent: "slow batch"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = repository.GetUnsentMessages(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repository.RenewLease(ctx, messageID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the lease expires while the batch is still running and another instance
+	// claims and sends the message
+	time.Sleep(10 * time.Millisecond)
+	_, _, err = repository.ReleaseExpiredLeases(ctx, 0, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claimed, err := otherRepository.GetUnsentMessages(ctx, 10)
+	if err != nil || len(claimed) != 1 {
+		t.Fatalf("expected the message to be claimed again, got %+v, %v", claimed, err)
+	}
+	err = otherRepository.MarkMessageSent(ctx, []models.MessageAttempt{{MessageID: messageID, AttemptNumber: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = repository.RenewLease(ctx, messageID)
+	if !errors.Is(err, models.ErrLeaseLost) {
+		t.Fatalf("expected ErrLeaseLost, got %v", err)
+	}
+	err = repository.ScheduleMessageRetry(ctx, []models.MessageAttempt{{MessageID: messageID, AttemptNumber: 1, Error: "late failure"}}, 0)
+	if !errors.Is(err, models.ErrLeaseLost) {
+		t.Fatalf("expected ErrLeaseLost, got %v", err)
+	}
+	err = repository.MarkMessageFailed(ctx, []models.MessageAttempt{{MessageID: messageID, AttemptNumber: 1, Error: "late failure"}})
+	if !errors.Is(err, models.ErrLeaseLost) {
+		t.Fatalf("expected ErrLeaseLost, got %v", err)
+	}
+	err = repository.DeferMessage(ctx, messageID, 0)
+	if !errors.Is(err, models.ErrLeaseLost) {
+		t.Fatalf("expected ErrLeaseLost, got %v", err)
+	}
+	message, err := repository.GetMessage(ctx, messageID)
+	if err != nil || message.SendingStatus != "sent" || message.LastError != "" {
+		t.Fatalf("expected the message to stay sent, got %+v, %v", message, err)
+	}
+	attempts, err := repository.ListMessageAttempts(ctx, messageID)
+	if err != nil || len(attempts) != 1 {
+		t.Fatalf("expected only the attempt of the second claim, got %+v, %v", attempts, err)
+	}
+}
+
+func TestRetryFailedMessages(t *testing.T) {
+	connect := newTestConn(t)
+	ctx := context.Background()
+	repository := NewMessagePostgresqlRepository(connect(), "claimer")
+	var messageIDs []string
+	for _, phoneNumber := range []string{"+905558889900", "+905558889911", "+905558889922"} {
+		messageID, err := repository.CreateMessage(ctx, models.Message{PhoneNumber: phoneNumber, MessageContent: "failed"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		messageIDs = append(messageIDs, messageID)
+	}
+	_, err := repository.GetUnsentMessages(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, messageID := range messageIDs[:2] {
+		err = repository.MarkMessageFailed(ctx, []models.MessageAttempt{{MessageID: messageID, AttemptNumber: 1, Error: "webhook unavailable"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	failed, err := repository.ListFailedMessages(ctx, models.FailedMessageFilter{PhoneNumber: "+905558889900", ErrorContains: "UNAVAILABLE", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failed) != 1 || failed[0].MessageID != messageIDs[0] {
+		t.Fatalf("expected the failed message of +905558889900, got %+v", failed)
+	}
+	err = repository.RetryFailedMessage(ctx, messageIDs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	message, err := repository.GetMessage(ctx, messageIDs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message.SendingStatus != "waiting" || message.AttemptCount != 1 || message.ReplayCount != 1 || message.ReplayedAttempts != 1 || message.LastError == "" {
+		t.Fatalf("expected a replay that keeps the attempt history, got %+v", message)
+	}
+	messages, err := repository.GetUnsentMessages(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 || messages[0].AttemptCount != 2 || messages[0].AttemptsSinceReplay() != 1 {
+		t.Fatalf("expected the replayed message to start a new round of attempts, got %+v", messages)
+	}
+
+	err = repository.RetryFailedMessage(ctx, messageIDs[2])
+	if !errors.Is(err, models.ErrMessageNotFailed) {
+		t.Fatalf("expected ErrMessageNotFailed, got %v", err)
+	}
+	err = repository.RetryFailedMessage(ctx, "00000000-0000-0000-0000-000000000000")
+	if !errors.Is(err, models.ErrMessageNotFound) {
+		t.Fatalf("expected ErrMessageNotFound, got %v", err)
+	}
+	retried, err := repository.RetryFailedMessages(ctx, models.FailedMessageFilter{ErrorContains: "unavailable"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(retried) != 1 || retried[0] != messageIDs[1] {
+		t.Fatalf("expected %s to be retried, got %v", messageIDs[1], retried)
+	}
+}
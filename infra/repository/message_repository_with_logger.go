@@ -2,7 +2,9 @@ package repository
 
 import (
 	"context"
+	"iter"
 	"log/slog"
+	"time"
 
 	"auto-message-sender/internal/models"
 )
@@ -44,3 +46,170 @@ func (m *MessageRepositoryWithLogger) UpdateMessageStatus(ctx context.Context, m
 	m.logger.Debug("UpdateMessageStatus success:", "messageID", messageID, "sendingStatus", sendingStatus)
 	return nil
 }
+
+func (m *MessageRepositoryWithLogger) MarkMessageSent(ctx context.Context, attempts []models.MessageAttempt) error {
+	err := m.baseService.MarkMessageSent(ctx, attempts)
+	if err != nil {
+		m.logger.Error("MessageRepositoryWithLogger.MarkMessageSent error:", "error", err)
+		return err
+	}
+	attempt := attempts[len(attempts)-1]
+	m.logger.Debug("MessageRepositoryWithLogger.MarkMessageSent success:", "messageID", attempt.MessageID, "provider", attempt.Provider, "endpoint", attempt.Endpoint, "latencyMS", attempt.LatencyMS, "attempts", len(attempts))
+	return nil
+}
+
+func (m *MessageRepositoryWithLogger) RecordProviderMessageID(ctx context.Context, messageID, provider, providerMessageID string) error {
+	err := m.baseService.RecordProviderMessageID(ctx, messageID, provider, providerMessageID)
+	if err != nil {
+		m.logger.Error("MessageRepositoryWithLogger.RecordProviderMessageID error:", "error", err)
+		return err
+	}
+	m.logger.Debug("MessageRepositoryWithLogger.RecordProviderMessageID success:", "messageID", messageID, "provider", provider, "providerMessageID", providerMessageID)
+	return nil
+}
+
+func (m *MessageRepositoryWithLogger) RecordDeliveryReport(ctx context.Context, report models.DeliveryReport) (string, error) {
+	messageID, err := m.baseService.RecordDeliveryReport(ctx, report)
+	if err != nil {
+		m.logger.Error("MessageRepositoryWithLogger.RecordDeliveryReport error:", "error", err)
+		return messageID, err
+	}
+	m.logger.Debug("MessageRepositoryWithLogger.RecordDeliveryReport success:", "messageID", messageID, "report", report)
+	return messageID, nil
+}
+
+func (m *MessageRepositoryWithLogger) CreateMessage(ctx context.Context, message models.Message) (string, error) {
+	messageID, err := m.baseService.CreateMessage(ctx, message)
+	if err != nil {
+		m.logger.Error("MessageRepositoryWithLogger.CreateMessage error:", "error", err)
+		return messageID, err
+	}
+	m.logger.Debug("MessageRepositoryWithLogger.CreateMessage success:", "messageID", messageID)
+	return messageID, nil
+}
+
+func (m *MessageRepositoryWithLogger) ImportMessages(ctx context.Context, messages iter.Seq2[models.Message, error]) (int64, error) {
+	count, err := m.baseService.ImportMessages(ctx, messages)
+	if err != nil {
+		m.logger.Error("MessageRepositoryWithLogger.ImportMessages error:", "error", err)
+		return count, err
+	}
+	m.logger.Debug("MessageRepositoryWithLogger.ImportMessages success:", "count", count)
+	return count, nil
+}
+
+func (m *MessageRepositoryWithLogger) ReleaseExpiredLeases(ctx context.Context, leaseTimeout time.Duration, maxAttempts int) (int64, int64, error) {
+	released, failed, err := m.baseService.ReleaseExpiredLeases(ctx, leaseTimeout, maxAttempts)
+	if err != nil {
+		m.logger.Error("MessageRepositoryWithLogger.ReleaseExpiredLeases error:", "error", err)
+		return released, failed, err
+	}
+	if released == 0 && failed == 0 {
+		m.logger.Debug("MessageRepositoryWithLogger.ReleaseExpiredLeases success but expired lease not found")
+		return released, failed, nil
+	}
+	m.logger.Warn("MessageRepositoryWithLogger.ReleaseExpiredLeases released expired leases:", "released", released, "failed", failed)
+	return released, failed, nil
+}
+
+func (m *MessageRepositoryWithLogger) ScheduleMessageRetry(ctx context.Context, attempts []models.MessageAttempt, delay time.Duration) error {
+	err := m.baseService.ScheduleMessageRetry(ctx, attempts, delay)
+	if err != nil {
+		m.logger.Error("MessageRepositoryWithLogger.ScheduleMessageRetry error:", "error", err)
+		return err
+	}
+	attempt := attempts[len(attempts)-1]
+	m.logger.Warn("MessageRepositoryWithLogger.ScheduleMessageRetry success:", "messageID", attempt.MessageID, "delay", delay, "lastError", attempt.Error)
+	return nil
+}
+
+func (m *MessageRepositoryWithLogger) MarkMessageFailed(ctx context.Context, attempts []models.MessageAttempt) error {
+	err := m.baseService.MarkMessageFailed(ctx, attempts)
+	if err != nil {
+		m.logger.Error("MessageRepositoryWithLogger.MarkMessageFailed error:", "error", err)
+		return err
+	}
+	attempt := attempts[len(attempts)-1]
+	m.logger.Warn("MessageRepositoryWithLogger.MarkMessageFailed success:", "messageID", attempt.MessageID, "lastError", attempt.Error)
+	return nil
+}
+
+func (m *MessageRepositoryWithLogger) DeferMessage(ctx context.Context, messageID string, delay time.Duration) error {
+	err := m.baseService.DeferMessage(ctx, messageID, delay)
+	if err != nil {
+		m.logger.Error("MessageRepositoryWithLogger.DeferMessage error:", "error", err)
+		return err
+	}
+	m.logger.Debug("MessageRepositoryWithLogger.DeferMessage success:", "messageID", messageID, "delay", delay)
+	return nil
+}
+
+func (m *MessageRepositoryWithLogger) RenewLease(ctx context.Context, messageID string) error {
+	err := m.baseService.RenewLease(ctx, messageID)
+	if err != nil {
+		m.logger.Error("MessageRepositoryWithLogger.RenewLease error:", "error", err, "messageID", messageID)
+		return err
+	}
+	m.logger.Debug("MessageRepositoryWithLogger.RenewLease success:", "messageID", messageID)
+	return nil
+}
+
+func (m *MessageRepositoryWithLogger) NextDueAt(ctx context.Context) (*time.Time, error) {
+	nextDueAt, err := m.baseService.NextDueAt(ctx)
+	if err != nil {
+		m.logger.Error("MessageRepositoryWithLogger.NextDueAt error:", "error", err)
+		return nextDueAt, err
+	}
+	m.logger.Debug("MessageRepositoryWithLogger.NextDueAt success:", "nextDueAt", nextDueAt)
+	return nextDueAt, nil
+}
+
+func (m *MessageRepositoryWithLogger) ListFailedMessages(ctx context.Context, filter models.FailedMessageFilter) ([]models.Message, error) {
+	messages, err := m.baseService.ListFailedMessages(ctx, filter)
+	if err != nil {
+		m.logger.Error("MessageRepositoryWithLogger.ListFailedMessages error:", "error", err)
+		return messages, err
+	}
+	m.logger.Debug("MessageRepositoryWithLogger.ListFailedMessages success:", "count", len(messages))
+	return messages, nil
+}
+
+func (m *MessageRepositoryWithLogger) RetryFailedMessage(ctx context.Context, messageID string) error {
+	err := m.baseService.RetryFailedMessage(ctx, messageID)
+	if err != nil {
+		m.logger.Error("MessageRepositoryWithLogger.RetryFailedMessage error:", "error", err, "messageID", messageID)
+		return err
+	}
+	m.logger.Info("MessageRepositoryWithLogger.RetryFailedMessage success:", "messageID", messageID)
+	return nil
+}
+
+func (m *MessageRepositoryWithLogger) RetryFailedMessages(ctx context.Context, filter models.FailedMessageFilter) ([]string, error) {
+	messageIDs, err := m.baseService.RetryFailedMessages(ctx, filter)
+	if err != nil {
+		m.logger.Error("MessageRepositoryWithLogger.RetryFailedMessages error:", "error", err)
+		return messageIDs, err
+	}
+	m.logger.Info("MessageRepositoryWithLogger.RetryFailedMessages success:", "count", len(messageIDs))
+	return messageIDs, nil
+}
+
+func (m *MessageRepositoryWithLogger) ListMessageAttempts(ctx context.Context, messageID string) ([]models.MessageAttempt, error) {
+	attempts, err := m.baseService.ListMessageAttempts(ctx, messageID)
+	if err != nil {
+		m.logger.Error("MessageRepositoryWithLogger.ListMessageAttempts error:", "error", err, "messageID", messageID)
+		return attempts, err
+	}
+	m.logger.Debug("MessageRepositoryWithLogger.ListMessageAttempts success:", "messageID", messageID, "count", len(attempts))
+	return attempts, nil
+}
+
+func (m *MessageRepositoryWithLogger) GetMessage(ctx context.Context, messageID string) (models.Message, error) {
+	message, err := m.baseService.GetMessage(ctx, messageID)
+	if err != nil {
+		m.logger.Error("MessageRepositoryWithLogger.GetMessage error:", "error", err, "messageID", messageID)
+		return message, err
+	}
+	m.logger.Debug("MessageRepositoryWithLogger.GetMessage success:", "messageID", messageID, "sendingStatus", message.SendingStatus)
+	return message, nil
+}
@@ -0,0 +1,40 @@
+package sender
+
+import (
+	"context"
+	"fmt"
+
+	"auto-message-sender/internal/models"
+)
+
+var _ messageSender = (*ChannelRouterMessageSender)(nil)
+
+// ChannelRouterMessageSender sends every message with the sender of its channel.
+// A message of a channel without a sender fails permanently, retrying it would
+// not help until the channel is configured.
+type ChannelRouterMessageSender struct {
+	senders map[string]messageSender
+}
+
+func NewChannelRouterMessageSender() *ChannelRouterMessageSender {
+	return &ChannelRouterMessageSender{
+		senders: make(map[string]messageSender),
+	}
+}
+
+// Route sends the messages of channel, e.g. models.ChannelEmail, with sender. It
+// must be called before the router is used.
+func (s *ChannelRouterMessageSender) Route(channel string, sender messageSender) *ChannelRouterMessageSender {
+	s.senders[channel] = sender
+	return s
+}
+
+func (s *ChannelRouterMessageSender) SendMessage(ctx context.Context, message models.Message) (models.MessageSenderResponse, error) {
+	channelSender, ok := s.senders[message.MessageChannel()]
+	if !ok {
+		return models.MessageSenderResponse{}, &models.SendError{
+			Err: fmt.Errorf("%w: no sender configured for channel %q", models.ErrInvalidChannel, message.MessageChannel()),
+		}
+	}
+	return channelSender.SendMessage(ctx, message)
+}
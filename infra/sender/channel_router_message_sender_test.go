@@ -0,0 +1,77 @@
+package sender
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"auto-message-sender/infra/sender/sendertest"
+	"auto-message-sender/internal/models"
+)
+
+func TestChannelRouterMessageSender(t *testing.T) {
+	smsProvider := sendertest.NewWebhookProvider()
+	defer smsProvider.Close()
+	smtpServer := sendertest.NewSMTPServer("", "")
+	defer smtpServer.Close()
+	var mu sync.Mutex
+	var chatMessages []chatWebhookMessage
+	chatWebhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var message chatWebhookMessage
+		err := json.NewDecoder(r.Body).Decode(&message)
+		if err != nil || message.Text == "" {
+			http.Error(w, "invalid_payload", http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		chatMessages = append(chatMessages, message)
+		mu.Unlock()
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer chatWebhook.Close()
+
+	smtpMessageSender, err := NewSMTPMessageSender(SMTPConfig{Addr: smtpServer.Addr, From: "noreply@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := NewChannelRouterMessageSender().
+		Route(models.ChannelSMS, NewWebhookMessageSender(smsProvider.URL)).
+		Route(models.ChannelEmail, smtpMessageSender).
+		Route(models.ChannelChat, NewChatWebhookMessageSender(chatWebhook.URL))
+
+	messages := []models.Message{
+		{MessageID: "1", PhoneNumber: "+905551112233", MessageContent: "sms"},
+		{MessageID: "2", Channel: models.ChannelEmail, Recipient: "user@example.com", MessageContent: "email"},
+		{MessageID: "3", Channel: models.ChannelChat, Recipient: "#alerts", MessageContent: "chat"},
+	}
+	for _, message := range messages {
+		_, err = router.SendMessage(context.Background(), message)
+		if err != nil {
+			t.Fatalf("message %s: %v", message.MessageID, err)
+		}
+	}
+	if got := smsProvider.Messages(); len(got) != 1 || got[0].To != "+905551112233" || got[0].Content != "sms" {
+		t.Fatalf("unexpected sms messages %+v", got)
+	}
+	if got := smtpServer.Messages(); len(got) != 1 || got[0].To != "user@example.com" || got[0].Content != "email" {
+		t.Fatalf("unexpected email messages %+v", got)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(chatMessages) != 1 || chatMessages[0] != (chatWebhookMessage{Text: "chat", Channel: "#alerts"}) {
+		t.Fatalf("unexpected chat messages %+v", chatMessages)
+	}
+}
+
+func TestChannelRouterMessageSenderUnknownChannel(t *testing.T) {
+	router := NewChannelRouterMessageSender()
+	_, err := router.SendMessage(context.Background(), models.Message{MessageID: "1", Channel: models.ChannelEmail})
+	var sendError *models.SendError
+	if !errors.As(err, &sendError) || sendError.Retryable || !errors.Is(err, models.ErrInvalidChannel) {
+		t.Fatalf("expected permanent SendError, got %v", err)
+	}
+}
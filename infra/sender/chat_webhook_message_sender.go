@@ -0,0 +1,85 @@
+package sender
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"auto-message-sender/internal/models"
+)
+
+var _ messageSender = (*ChatWebhookMessageSender)(nil)
+
+// ChatWebhookProvider is the provider of the messages sent by ChatWebhookMessageSender
+const ChatWebhookProvider = "chat_webhook"
+
+// ChatWebhookMessageSender posts chat messages to a Slack or Teams style incoming
+// webhook. Such webhooks mostly answer with a plain "ok", the message id is
+// returned as the provider message id unless the platform answers with its own.
+type ChatWebhookMessageSender struct {
+	client     *http.Client
+	webhookURL string
+}
+
+func NewChatWebhookMessageSender(webhookURL string) *ChatWebhookMessageSender {
+	return &ChatWebhookMessageSender{
+		client: &http.Client{
+			Timeout: RequestTimeout,
+		},
+		webhookURL: webhookURL,
+	}
+}
+
+// chatWebhookMessage is understood by Slack and Teams incoming webhooks, the
+// channel overrides the default channel of the webhook where supported
+type chatWebhookMessage struct {
+	Text    string `json:"text"`
+	Channel string `json:"channel,omitempty"`
+}
+
+// chatWebhookResponse holds the message id of the platforms that answer with
+// the created message
+type chatWebhookResponse struct {
+	ID string `json:"id"`
+	TS string `json:"ts"`
+}
+
+func (s *ChatWebhookMessageSender) SendMessage(ctx context.Context, message models.Message) (models.MessageSenderResponse, error) {
+	body, err := json.Marshal(chatWebhookMessage{
+		Text:    message.MessageContent,
+		Channel: message.Recipient,
+	})
+	if err != nil {
+		return models.MessageSenderResponse{}, fmt.Errorf("json.Marshal error: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return models.MessageSenderResponse{}, fmt.Errorf("http.NewRequestWithContext error: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(IdempotencyKeyHeader, message.IdempotencyKey())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return models.MessageSenderResponse{}, &models.EndpointError{Provider: ChatWebhookProvider, Err: newRequestError(err)}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return models.MessageSenderResponse{}, &models.EndpointError{Provider: ChatWebhookProvider, Err: newResponseError(resp)}
+	}
+	// e.g. Discord answers with the created message when asked to wait
+	var created chatWebhookResponse
+	_ = json.NewDecoder(io.LimitReader(resp.Body, maxErrorBodySize)).Decode(&created)
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBodySize))
+	return models.MessageSenderResponse{
+		Message:    "Accepted",
+		MessageID:  cmp.Or(created.ID, created.TS, message.MessageID),
+		SentAt:     time.Now().UTC(),
+		Provider:   ChatWebhookProvider,
+		StatusCode: resp.StatusCode,
+	}, nil
+}
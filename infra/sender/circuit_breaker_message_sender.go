@@ -0,0 +1,206 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"auto-message-sender/internal/models"
+)
+
+// CircuitBreakerConfig controls when a CircuitBreakerMessageSender opens
+type CircuitBreakerConfig struct {
+	// Name identifies the breaker in logs and metrics, it must be unique
+	Name string
+	// FailureThreshold is the number of consecutive failed sends that opens
+	// the circuit, 0 disables the breaker
+	FailureThreshold int
+	// OpenTimeout is how long the circuit stays open before a probe is sent
+	OpenTimeout time.Duration
+	// HalfOpenSuccesses is the number of successful probes that close the circuit
+	HalfOpenSuccesses int
+}
+
+func (c CircuitBreakerConfig) Validate() error {
+	if c.FailureThreshold < 0 {
+		return fmt.Errorf("circuit breaker failure threshold must not be negative: %d", c.FailureThreshold)
+	}
+	if c.FailureThreshold == 0 {
+		return nil
+	}
+	if c.OpenTimeout <= 0 {
+		return fmt.Errorf("circuit breaker open timeout must be positive: %s", c.OpenTimeout)
+	}
+	if c.HalfOpenSuccesses < 1 {
+		return fmt.Errorf("circuit breaker half-open successes must be at least 1: %d", c.HalfOpenSuccesses)
+	}
+	return nil
+}
+
+var _ messageSender = (*CircuitBreakerMessageSender)(nil)
+
+// CircuitBreakerMessageSender is a messageSender decorator that defers messages
+// while the base sender is failing
+type CircuitBreakerMessageSender struct {
+	logger      *slog.Logger
+	baseService messageSender
+	config      CircuitBreakerConfig
+	now         func() time.Time
+
+	mu                  sync.Mutex
+	state               string
+	consecutiveFailures int
+	halfOpenSuccesses   int
+	probeInFlight       bool
+	openedAt            time.Time
+
+	stateMetric        *expvar.String
+	stateChangesMetric *expvar.Int
+	rejectedMetric     *expvar.Int
+	failuresMetric     *expvar.Int
+}
+
+func NewCircuitBreakerMessageSender(logger *slog.Logger, metricsRegistry *MetricsRegistry, baseService messageSender, config CircuitBreakerConfig) (*CircuitBreakerMessageSender, error) {
+	s := &CircuitBreakerMessageSender{
+		logger:             logger,
+		baseService:        baseService,
+		config:             config,
+		now:                time.Now,
+		state:              models.CircuitStateClosed,
+		stateMetric:        new(expvar.String),
+		stateChangesMetric: new(expvar.Int),
+		rejectedMetric:     new(expvar.Int),
+		failuresMetric:     new(expvar.Int),
+	}
+	s.stateMetric.Set(s.state)
+	metrics := new(expvar.Map).Init()
+	metrics.Set("state", s.stateMetric)
+	metrics.Set("state_changes", s.stateChangesMetric)
+	metrics.Set("rejected", s.rejectedMetric)
+	metrics.Set("failures", s.failuresMetric)
+	err := metricsRegistry.registerCircuitBreaker(config.Name, metrics)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *CircuitBreakerMessageSender) SendMessage(ctx context.Context, message models.Message) (models.MessageSenderResponse, error) {
+	if s.config.FailureThreshold == 0 {
+		return s.baseService.SendMessage(ctx, message)
+	}
+	probe, err := s.acquire()
+	if err != nil {
+		s.rejectedMetric.Add(1)
+		return models.MessageSenderResponse{}, err
+	}
+	response, err := s.baseService.SendMessage(ctx, message)
+	s.release(ctx, probe, err)
+	return response, err
+}
+
+// Status returns the current state of the breaker
+func (s *CircuitBreakerMessageSender) Status() models.CircuitBreakerStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := models.CircuitBreakerStatus{
+		Name:                s.config.Name,
+		State:               s.state,
+		ConsecutiveFailures: s.consecutiveFailures,
+	}
+	if s.state != models.CircuitStateClosed {
+		openedAt := s.openedAt
+		retryAt := s.openedAt.Add(s.config.OpenTimeout)
+		status.OpenedAt = &openedAt
+		status.RetryAt = &retryAt
+	}
+	return status
+}
+
+// CircuitBreakers are the breakers of several endpoints
+type CircuitBreakers []*CircuitBreakerMessageSender
+
+// Status returns the current state of every breaker
+func (b CircuitBreakers) Status() []models.CircuitBreakerStatus {
+	statuses := make([]models.CircuitBreakerStatus, 0, len(b))
+	for _, breaker := range b {
+		statuses = append(statuses, breaker.Status())
+	}
+	return statuses
+}
+
+// acquire decides whether a request may go through, probe is true for the probe
+// of a half-open circuit
+func (s *CircuitBreakerMessageSender) acquire() (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if s.state == models.CircuitStateOpen {
+		retryAt := s.openedAt.Add(s.config.OpenTimeout)
+		if now.Before(retryAt) {
+			return false, s.openError(retryAt.Sub(now))
+		}
+		s.setState(models.CircuitStateHalfOpen)
+	}
+	if s.state == models.CircuitStateHalfOpen {
+		if s.probeInFlight {
+			return false, s.openError(s.config.OpenTimeout)
+		}
+		s.probeInFlight = true
+		return true, nil
+	}
+	return false, nil
+}
+
+// release records the result of a request, deferred and cancelled sends are
+// ignored and a permanent error counts as a success
+func (s *CircuitBreakerMessageSender) release(ctx context.Context, probe bool, sendErr error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if probe {
+		s.probeInFlight = false
+	}
+	var deferredSendError *models.DeferredSendError
+	if sendErr != nil && (ctx.Err() != nil || errors.As(sendErr, &deferredSendError)) {
+		return
+	}
+	var sendError *models.SendError
+	if sendErr == nil || (errors.As(sendErr, &sendError) && !sendError.Retryable) {
+		s.consecutiveFailures = 0
+		if s.state == models.CircuitStateHalfOpen {
+			s.halfOpenSuccesses++
+			if s.halfOpenSuccesses >= s.config.HalfOpenSuccesses {
+				s.setState(models.CircuitStateClosed)
+			}
+		}
+		return
+	}
+	s.failuresMetric.Add(1)
+	s.consecutiveFailures++
+	if s.state == models.CircuitStateHalfOpen || s.consecutiveFailures >= s.config.FailureThreshold {
+		if s.state != models.CircuitStateOpen {
+			s.setState(models.CircuitStateOpen)
+		}
+		s.openedAt = s.now()
+	}
+}
+
+// setState changes the state of the breaker, mu must be held
+func (s *CircuitBreakerMessageSender) setState(state string) {
+	s.logger.Info("circuit breaker state changed", "name", s.config.Name, "from", s.state, "to", state, "consecutive_failures", s.consecutiveFailures)
+	s.state = state
+	s.halfOpenSuccesses = 0
+	s.stateMetric.Set(state)
+	s.stateChangesMetric.Add(1)
+}
+
+func (s *CircuitBreakerMessageSender) openError(retryAfter time.Duration) error {
+	return &models.DeferredSendError{
+		Err:   fmt.Errorf("%w: %s", models.ErrCircuitOpen, s.config.Name),
+		Delay: retryAfter,
+	}
+}
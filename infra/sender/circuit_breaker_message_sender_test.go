@@ -0,0 +1,131 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"auto-message-sender/internal/models"
+)
+
+type failingMessageSender struct {
+	err  error
+	sent int
+}
+
+func (s *failingMessageSender) SendMessage(_ context.Context, message models.Message) (models.MessageSenderResponse, error) {
+	s.sent++
+	if s.err != nil {
+		return models.MessageSenderResponse{}, s.err
+	}
+	return models.MessageSenderResponse{Message: "Accepted", MessageID: "provider-" + message.MessageID}, nil
+}
+
+func TestCircuitBreakerMessageSender(t *testing.T) {
+	now := time.Date(2025, 11, 13, 10, 0, 0, 0, time.UTC)
+	baseService := &failingMessageSender{err: errors.New("webhook unavailable")}
+	metricsRegistry := NewMetricsRegistry()
+	s, err := NewCircuitBreakerMessageSender(slog.New(slog.NewTextHandler(io.Discard, nil)), metricsRegistry, baseService, CircuitBreakerConfig{
+		Name:              "test",
+		FailureThreshold:  3,
+		OpenTimeout:       30 * time.Second,
+		HalfOpenSuccesses: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.now = func() time.Time { return now }
+	ctx := context.Background()
+	message := models.Message{MessageID: "1"}
+
+	for range 3 {
+		_, err := s.SendMessage(ctx, message)
+		if err == nil || errors.Is(err, models.ErrCircuitOpen) {
+			t.Fatalf("expected base sender error, got %v", err)
+		}
+	}
+	if state := s.Status().State; state != models.CircuitStateOpen {
+		t.Fatalf("expected open circuit, got %s", state)
+	}
+
+	now = now.Add(10 * time.Second)
+	_, err = s.SendMessage(ctx, message)
+	var deferredSendError *models.DeferredSendError
+	if !errors.Is(err, models.ErrCircuitOpen) || !errors.As(err, &deferredSendError) || deferredSendError.Delay != 20*time.Second {
+		t.Fatalf("expected deferred open circuit error for 20s, got %v", err)
+	}
+	if baseService.sent != 3 {
+		t.Fatalf("open circuit must not call the base sender, called %d times", baseService.sent)
+	}
+
+	// a failed probe opens the circuit again
+	now = now.Add(20 * time.Second)
+	_, err = s.SendMessage(ctx, message)
+	if err == nil || errors.Is(err, models.ErrCircuitOpen) {
+		t.Fatalf("expected probe error, got %v", err)
+	}
+	status := s.Status()
+	if status.State != models.CircuitStateOpen || !status.RetryAt.Equal(now.Add(30*time.Second)) {
+		t.Fatalf("expected circuit to open until %s, got %+v", now.Add(30*time.Second), status)
+	}
+
+	now = now.Add(30 * time.Second)
+	baseService.err = nil
+	for range 2 {
+		_, err = s.SendMessage(ctx, message)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if state := s.Status().State; state != models.CircuitStateClosed {
+		t.Fatalf("expected closed circuit, got %s", state)
+	}
+	metrics := metricsRegistry.circuitBreakers.Get("test").(*expvar.Map)
+	if state := metrics.Get("state").String(); state != `"closed"` {
+		t.Fatalf("expected closed state metric, got %s", state)
+	}
+	if stateChanges := metrics.Get("state_changes").String(); stateChanges != "5" {
+		t.Fatalf("expected 5 state changes, got %s", stateChanges)
+	}
+}
+
+func TestCircuitBreakerIgnoresDeferredSends(t *testing.T) {
+	baseService := &failingMessageSender{err: &models.DeferredSendError{Err: models.ErrRateLimited, Delay: time.Second}}
+	s, err := NewCircuitBreakerMessageSender(slog.New(slog.NewTextHandler(io.Discard, nil)), NewMetricsRegistry(), baseService, CircuitBreakerConfig{
+		Name:              "test",
+		FailureThreshold:  1,
+		OpenTimeout:       time.Minute,
+		HalfOpenSuccesses: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		_, _ = s.SendMessage(context.Background(), models.Message{MessageID: "1"})
+	}
+	if status := s.Status(); status.State != models.CircuitStateClosed || status.ConsecutiveFailures != 0 {
+		t.Fatalf("expected closed circuit without failures, got %+v", status)
+	}
+}
+
+func TestCircuitBreakerRejectsDuplicateNames(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	metricsRegistry := NewMetricsRegistry()
+	config := CircuitBreakerConfig{Name: "test", FailureThreshold: 1, OpenTimeout: time.Minute, HalfOpenSuccesses: 1}
+	_, err := NewCircuitBreakerMessageSender(logger, metricsRegistry, &failingMessageSender{}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewCircuitBreakerMessageSender(logger, metricsRegistry, &failingMessageSender{}, config)
+	if err == nil {
+		t.Fatal("expected a second breaker with the same name to be rejected")
+	}
+	_, err = NewCircuitBreakerMessageSender(logger, metricsRegistry, &failingMessageSender{}, CircuitBreakerConfig{})
+	if err == nil {
+		t.Fatal("expected a breaker without a name to be rejected")
+	}
+}
@@ -0,0 +1,319 @@
+package sender
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"auto-message-sender/internal/models"
+)
+
+// healthBuckets is the number of buckets of the health window
+const healthBuckets = 10
+
+// FailoverEndpoint is an upstream endpoint of a FailoverMessageSender
+type FailoverEndpoint struct {
+	// Name identifies the endpoint in logs, metrics and the sender status
+	Name string
+	// Provider is the provider adapter of the endpoint, recorded with the send
+	// attempts
+	Provider string
+	// Priority orders the endpoints, an endpoint is only used when every healthy
+	// endpoint with a lower priority failed
+	Priority int
+	// Weight is the share of messages the endpoint gets among the healthy
+	// endpoints of its priority, 1 if 0
+	Weight int
+	Sender messageSender
+}
+
+// FailoverConfig controls when an endpoint is taken out of rotation
+type FailoverConfig struct {
+	// ErrorRateThreshold is the failed request ratio in Window that takes an
+	// endpoint out of rotation, 0 keeps every endpoint in rotation
+	ErrorRateThreshold float64
+	// MinRequests is the number of requests in Window needed before the error
+	// rate of an endpoint is trusted
+	MinRequests int
+	Window      time.Duration
+	// EjectionTime is how long an unhealthy endpoint stays out of rotation
+	EjectionTime time.Duration
+}
+
+func (c FailoverConfig) Validate() error {
+	if c.ErrorRateThreshold < 0 || c.ErrorRateThreshold > 1 {
+		return fmt.Errorf("failover error rate threshold must be between 0 and 1: %g", c.ErrorRateThreshold)
+	}
+	if c.ErrorRateThreshold == 0 {
+		return nil
+	}
+	if c.MinRequests < 1 {
+		return fmt.Errorf("failover min requests must be at least 1: %d", c.MinRequests)
+	}
+	if c.Window < healthBuckets*time.Millisecond {
+		return fmt.Errorf("failover window must be at least %s: %s", healthBuckets*time.Millisecond, c.Window)
+	}
+	if c.EjectionTime <= 0 {
+		return fmt.Errorf("failover ejection time must be positive: %s", c.EjectionTime)
+	}
+	return nil
+}
+
+var _ messageSender = (*FailoverMessageSender)(nil)
+
+// FailoverMessageSender spreads messages over upstream endpoints by priority and
+// weight, a message is sent to the next endpoint if canFailOver allows it
+type FailoverMessageSender struct {
+	logger *slog.Logger
+	config FailoverConfig
+	now    func() time.Time
+
+	mu        sync.Mutex
+	endpoints []*failoverEndpoint
+}
+
+type failoverEndpoint struct {
+	FailoverEndpoint
+	// currentWeight is the smooth weighted round-robin state
+	currentWeight int
+	buckets       [healthBuckets]healthBucket
+	ejectedUntil  time.Time
+
+	requestsMetric  *expvar.Int
+	failuresMetric  *expvar.Int
+	ejectionsMetric *expvar.Int
+}
+
+type healthBucket struct {
+	start    time.Time
+	requests int
+	failures int
+}
+
+func NewFailoverMessageSender(logger *slog.Logger, metricsRegistry *MetricsRegistry, endpoints []FailoverEndpoint, config FailoverConfig) (*FailoverMessageSender, error) {
+	err := config.Validate()
+	if err != nil {
+		return nil, err
+	}
+	if len(endpoints) == 0 {
+		return nil, errors.New("failover sender needs at least one endpoint")
+	}
+	s := &FailoverMessageSender{
+		logger: logger,
+		config: config,
+		now:    time.Now,
+	}
+	endpointMetrics := make(map[string]*expvar.Map)
+	for _, endpoint := range endpoints {
+		if endpoint.Name == "" || endpointMetrics[endpoint.Name] != nil {
+			return nil, fmt.Errorf("failover endpoint name must be unique and not empty: %q", endpoint.Name)
+		}
+		if endpoint.Weight < 0 {
+			return nil, fmt.Errorf("failover endpoint %s weight must not be negative: %d", endpoint.Name, endpoint.Weight)
+		}
+		if endpoint.Weight == 0 {
+			endpoint.Weight = 1
+		}
+		e := &failoverEndpoint{
+			FailoverEndpoint: endpoint,
+			requestsMetric:   new(expvar.Int),
+			failuresMetric:   new(expvar.Int),
+			ejectionsMetric:  new(expvar.Int),
+		}
+		metrics := new(expvar.Map).Init()
+		metrics.Set("requests", e.requestsMetric)
+		metrics.Set("failures", e.failuresMetric)
+		metrics.Set("ejections", e.ejectionsMetric)
+		endpointMetrics[endpoint.Name] = metrics
+		s.endpoints = append(s.endpoints, e)
+	}
+	err = metricsRegistry.registerFailoverEndpoints(endpointMetrics)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortStableFunc(s.endpoints, func(a, b *failoverEndpoint) int {
+		return cmp.Compare(a.Priority, b.Priority)
+	})
+	return s, nil
+}
+
+// SendMessage sends message to the first endpoint that accepts it, the endpoints
+// that failed before are returned in FailedEndpoints
+func (s *FailoverMessageSender) SendMessage(ctx context.Context, message models.Message) (models.MessageSenderResponse, error) {
+	var lastErr *models.EndpointError
+	var failedEndpoints []*models.EndpointError
+	for _, endpoint := range s.candidates() {
+		if lastErr != nil {
+			s.logger.Warn("failing over to the next endpoint", "message_id", message.MessageID, "endpoint", endpoint.Name, "error", lastErr)
+			if !notSent(lastErr.Err) {
+				failedEndpoints = append(failedEndpoints, lastErr)
+			}
+		}
+		startedAt := time.Now()
+		response, err := endpoint.Sender.SendMessage(ctx, message)
+		latency := time.Since(startedAt)
+		s.record(ctx, endpoint, err)
+		if err == nil {
+			response.Provider = cmp.Or(response.Provider, endpoint.Provider)
+			response.Endpoint = endpoint.Name
+			response.FailedEndpoints = failedEndpoints
+			return response, nil
+		}
+		lastErr = &models.EndpointError{Provider: endpoint.Provider, Endpoint: endpoint.Name, Latency: latency, Err: err}
+		if ctx.Err() != nil || !canFailOver(err) {
+			break
+		}
+	}
+	lastErr.FailedEndpoints = failedEndpoints
+	return models.MessageSenderResponse{}, lastErr
+}
+
+// Status returns the health of every endpoint, ordered by priority
+func (s *FailoverMessageSender) Status() []models.FailoverEndpointStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	statuses := make([]models.FailoverEndpointStatus, 0, len(s.endpoints))
+	for _, endpoint := range s.endpoints {
+		requests, failures := s.counts(endpoint, now)
+		status := models.FailoverEndpointStatus{
+			Name:     endpoint.Name,
+			Priority: endpoint.Priority,
+			Weight:   endpoint.Weight,
+			Healthy:  !now.Before(endpoint.ejectedUntil),
+			Requests: requests,
+			Failures: failures,
+		}
+		if !status.Healthy {
+			ejectedUntil := endpoint.ejectedUntil
+			status.EjectedUntil = &ejectedUntil
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// canFailOver reports whether the endpoint certainly did not process the message
+func canFailOver(err error) bool {
+	if notSent(err) {
+		return true
+	}
+	var sendError *models.SendError
+	return errors.As(err, &sendError) && sendError.Retryable && sendError.Unprocessed
+}
+
+// notSent reports whether err was returned before a request was made, e.g. by
+// a rate limiter or an open circuit breaker
+func notSent(err error) bool {
+	var deferredSendError *models.DeferredSendError
+	return errors.As(err, &deferredSendError) || errors.Is(err, models.ErrRateLimited)
+}
+
+// candidates returns the endpoints to try in order, unhealthy ones only when no
+// endpoint is healthy
+func (s *FailoverMessageSender) candidates() []*failoverEndpoint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	var healthy, unhealthy []*failoverEndpoint
+	for start := 0; start < len(s.endpoints); {
+		end := start
+		var group []*failoverEndpoint
+		for ; end < len(s.endpoints) && s.endpoints[end].Priority == s.endpoints[start].Priority; end++ {
+			if now.Before(s.endpoints[end].ejectedUntil) {
+				unhealthy = append(unhealthy, s.endpoints[end])
+				continue
+			}
+			group = append(group, s.endpoints[end])
+		}
+		healthy = append(healthy, pickWeighted(group)...)
+		start = end
+	}
+	if len(healthy) == 0 {
+		return unhealthy
+	}
+	return healthy
+}
+
+// pickWeighted moves the endpoint chosen by smooth weighted round-robin to the
+// front of group, equal weights take turns
+func pickWeighted(group []*failoverEndpoint) []*failoverEndpoint {
+	if len(group) < 2 {
+		return group
+	}
+	total := 0
+	picked := 0
+	for i, endpoint := range group {
+		endpoint.currentWeight += endpoint.Weight
+		total += endpoint.Weight
+		if endpoint.currentWeight > group[picked].currentWeight {
+			picked = i
+		}
+	}
+	first := group[picked]
+	first.currentWeight -= total
+	return append([]*failoverEndpoint{first}, slices.Delete(group, picked, picked+1)...)
+}
+
+// record updates the health of endpoint, it counts sends like the circuit breaker
+func (s *FailoverMessageSender) record(ctx context.Context, endpoint *failoverEndpoint, sendErr error) {
+	if sendErr != nil && (ctx.Err() != nil || notSent(sendErr)) {
+		return
+	}
+	var sendError *models.SendError
+	failed := sendErr != nil && (!errors.As(sendErr, &sendError) || sendError.Retryable)
+	endpoint.requestsMetric.Add(1)
+	if failed {
+		endpoint.failuresMetric.Add(1)
+	}
+	if s.config.ErrorRateThreshold == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	bucket := s.bucket(endpoint, now)
+	bucket.requests++
+	if !failed {
+		return
+	}
+	bucket.failures++
+	requests, failures := s.counts(endpoint, now)
+	if requests < s.config.MinRequests || float64(failures)/float64(requests) < s.config.ErrorRateThreshold {
+		return
+	}
+	endpoint.ejectedUntil = now.Add(s.config.EjectionTime)
+	// a returning endpoint starts with a clean window
+	endpoint.buckets = [healthBuckets]healthBucket{}
+	endpoint.ejectionsMetric.Add(1)
+	s.logger.Warn("failover endpoint taken out of rotation", "endpoint", endpoint.Name, "requests", requests, "failures", failures, "until", endpoint.ejectedUntil)
+}
+
+// bucket returns the bucket of now, mu must be held
+func (s *FailoverMessageSender) bucket(endpoint *failoverEndpoint, now time.Time) *healthBucket {
+	size := s.config.Window / healthBuckets
+	start := now.Truncate(size)
+	bucket := &endpoint.buckets[(start.UnixNano()/int64(size))%healthBuckets]
+	if !bucket.start.Equal(start) {
+		*bucket = healthBucket{start: start}
+	}
+	return bucket
+}
+
+// counts sums the buckets inside the window, mu must be held
+func (s *FailoverMessageSender) counts(endpoint *failoverEndpoint, now time.Time) (int, int) {
+	requests, failures := 0, 0
+	for _, bucket := range endpoint.buckets {
+		if now.Sub(bucket.start) < s.config.Window {
+			requests += bucket.requests
+			failures += bucket.failures
+		}
+	}
+	return requests, failures
+}
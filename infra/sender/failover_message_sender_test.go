@@ -0,0 +1,200 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"auto-message-sender/internal/models"
+)
+
+func newTestFailoverMessageSender(t *testing.T, endpoints []FailoverEndpoint, config FailoverConfig) *FailoverMessageSender {
+	t.Helper()
+	s, err := NewFailoverMessageSender(slog.New(slog.NewTextHandler(io.Discard, nil)), NewMetricsRegistry(), endpoints, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestFailoverMessageSenderWeights(t *testing.T) {
+	heavy, light, backup := &failingMessageSender{}, &failingMessageSender{}, &failingMessageSender{}
+	s := newTestFailoverMessageSender(t, []FailoverEndpoint{
+		{Name: "backup", Priority: 1, Sender: backup},
+		{Name: "heavy", Weight: 3, Sender: heavy},
+		{Name: "light", Weight: 1, Sender: light},
+	}, FailoverConfig{})
+
+	var order []int
+	for range 8 {
+		_, err := s.SendMessage(context.Background(), models.Message{MessageID: "1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		order = append(order, heavy.sent)
+	}
+	if heavy.sent != 6 || light.sent != 2 || backup.sent != 0 {
+		t.Fatalf("expected 6/2/0 messages, got heavy %d, light %d, backup %d", heavy.sent, light.sent, backup.sent)
+	}
+	// smooth weighted round-robin does not send the light share in a burst
+	if order[3] == order[4] && order[4] == order[5] {
+		t.Fatalf("unexpected order %v", order)
+	}
+}
+
+func TestFailoverMessageSenderFailover(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          error
+		wantFailover bool
+	}{
+		{name: "unprocessed", err: &models.SendError{StatusCode: 503, Retryable: true, Unprocessed: true, Err: errors.New("unavailable")}, wantFailover: true},
+		{name: "deferred", err: &models.DeferredSendError{Err: models.ErrRateLimited, Delay: time.Second}, wantFailover: true},
+		{name: "rate limited", err: fmt.Errorf("%w: provider rate limit reached", models.ErrRateLimited), wantFailover: true},
+		{name: "timeout", err: &models.SendError{Retryable: true, Err: context.DeadlineExceeded}},
+		{name: "permanent", err: &models.SendError{StatusCode: 400, Err: errors.New("invalid phone number")}},
+		{name: "unreadable response", err: errors.New("adapter.DecodeResponse error")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary, backup := &failingMessageSender{err: tt.err}, &failingMessageSender{}
+			s := newTestFailoverMessageSender(t, []FailoverEndpoint{
+				{Name: "primary", Provider: "webhook", Sender: primary},
+				{Name: "backup", Provider: "webhook", Priority: 1, Sender: backup},
+			}, FailoverConfig{})
+
+			response, err := s.SendMessage(context.Background(), models.Message{MessageID: "1"})
+			if tt.wantFailover {
+				if err != nil || response.MessageID != "provider-1" || backup.sent != 1 {
+					t.Fatalf("expected the backup to send the message, got %v", err)
+				}
+				if response.Provider != "webhook" || response.Endpoint != "backup" {
+					t.Fatalf("expected the response of the backup, got %+v", response)
+				}
+				return
+			}
+			var endpointError *models.EndpointError
+			if !errors.Is(err, tt.err) || !errors.As(err, &endpointError) || endpointError.Endpoint != "primary" || backup.sent != 0 {
+				t.Fatalf("expected %v of primary without failover, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestFailoverMessageSenderFailedEndpoints(t *testing.T) {
+	unavailable := &models.SendError{StatusCode: 503, Retryable: true, Unprocessed: true, Err: errors.New("unavailable")}
+	primary := &failingMessageSender{err: unavailable}
+	limited := &failingMessageSender{err: &models.DeferredSendError{Err: models.ErrRateLimited, Delay: time.Second}}
+	backup := &failingMessageSender{}
+	s := newTestFailoverMessageSender(t, []FailoverEndpoint{
+		{Name: "primary", Provider: "webhook", Sender: primary},
+		{Name: "limited", Provider: "webhook", Priority: 1, Sender: limited},
+		{Name: "backup", Provider: "webhook", Priority: 2, Sender: backup},
+	}, FailoverConfig{})
+
+	// a single call returns every endpoint that failed, except the one that was not sent to
+	response, err := s.SendMessage(context.Background(), models.Message{MessageID: "1"})
+	if err != nil || response.Endpoint != "backup" {
+		t.Fatalf("expected the backup to send the message, got %+v, %v", response, err)
+	}
+	if len(response.FailedEndpoints) != 1 || response.FailedEndpoints[0].Endpoint != "primary" || !errors.Is(response.FailedEndpoints[0], unavailable) {
+		t.Fatalf("expected the primary to be returned as failed, got %+v", response.FailedEndpoints)
+	}
+
+	backup.err = &models.SendError{StatusCode: 502, Retryable: true, Unprocessed: true, Err: errors.New("bad gateway")}
+	_, err = s.SendMessage(context.Background(), models.Message{MessageID: "2"})
+	var endpointError *models.EndpointError
+	if !errors.As(err, &endpointError) || endpointError.Endpoint != "backup" {
+		t.Fatalf("expected the error of the backup, got %v", err)
+	}
+	if len(endpointError.FailedEndpoints) != 1 || endpointError.FailedEndpoints[0].Endpoint != "primary" {
+		t.Fatalf("expected the primary to be returned as failed, got %+v", endpointError.FailedEndpoints)
+	}
+}
+
+func TestFailoverMessageSenderEjection(t *testing.T) {
+	now := time.Date(2025, 11, 13, 10, 0, 0, 0, time.UTC)
+	primary := &failingMessageSender{err: &models.SendError{StatusCode: 502, Retryable: true, Unprocessed: true, Err: errors.New("bad gateway")}}
+	backup := &failingMessageSender{}
+	s := newTestFailoverMessageSender(t, []FailoverEndpoint{
+		{Name: "primary", Sender: primary},
+		{Name: "backup", Priority: 1, Sender: backup},
+	}, FailoverConfig{
+		ErrorRateThreshold: 0.5,
+		MinRequests:        4,
+		Window:             time.Minute,
+		EjectionTime:       30 * time.Second,
+	})
+	s.now = func() time.Time { return now }
+	ctx := context.Background()
+	message := models.Message{MessageID: "1"}
+
+	for range 4 {
+		_, err := s.SendMessage(ctx, message)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	status := s.Status()
+	if status[0].Name != "primary" || status[0].Healthy || status[0].EjectedUntil == nil || !status[1].Healthy {
+		t.Fatalf("expected primary to be out of rotation, got %+v", status)
+	}
+
+	_, err := s.SendMessage(ctx, message)
+	if err != nil || primary.sent != 4 || backup.sent != 5 {
+		t.Fatalf("expected only the backup to be used, primary %d, backup %d, err %v", primary.sent, backup.sent, err)
+	}
+
+	// with every endpoint out of rotation the unhealthy ones are still tried
+	backup.err = primary.err
+	for range 5 {
+		_, _ = s.SendMessage(ctx, message)
+	}
+	if status = s.Status(); status[1].Healthy {
+		t.Fatalf("expected backup to be out of rotation, got %+v", status)
+	}
+	primary.err, backup.err = nil, nil
+	_, err = s.SendMessage(ctx, message)
+	if err != nil || primary.sent != 5 || backup.sent != 10 {
+		t.Fatalf("expected the unhealthy primary to be tried, got %v", err)
+	}
+
+	now = now.Add(30 * time.Second)
+	if status = s.Status(); !status[0].Healthy || status[0].Failures != 0 {
+		t.Fatalf("expected primary back in rotation without the old failures, got %+v", status)
+	}
+	_, err = s.SendMessage(ctx, message)
+	if err != nil || primary.sent != 6 {
+		t.Fatalf("expected primary to be used again, got %v", err)
+	}
+}
+
+func TestFailoverMessageSenderRejectsDuplicateNames(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	metricsRegistry := NewMetricsRegistry()
+	_, err := NewFailoverMessageSender(logger, metricsRegistry, []FailoverEndpoint{
+		{Name: "primary", Sender: &failingMessageSender{}},
+		{Name: "primary", Sender: &failingMessageSender{}},
+	}, FailoverConfig{})
+	if err == nil {
+		t.Fatal("expected two endpoints with the same name to be rejected")
+	}
+	_, err = NewFailoverMessageSender(logger, metricsRegistry, []FailoverEndpoint{{Name: "primary", Sender: &failingMessageSender{}}}, FailoverConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewFailoverMessageSender(logger, metricsRegistry, []FailoverEndpoint{
+		{Name: "backup", Sender: &failingMessageSender{}},
+		{Name: "primary", Sender: &failingMessageSender{}},
+	}, FailoverConfig{})
+	if err == nil {
+		t.Fatal("expected an endpoint name of another failover sender to be rejected")
+	}
+	if metricsRegistry.failoverEndpoints.Get("backup") != nil {
+		t.Fatal("expected no metrics of a rejected failover sender")
+	}
+}
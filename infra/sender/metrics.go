@@ -0,0 +1,55 @@
+package sender
+
+import (
+	"expvar"
+	"fmt"
+	"sync"
+)
+
+// MetricsRegistry holds the metrics of the circuit breakers and the failover
+// endpoints keyed by name, a name can be registered once
+type MetricsRegistry struct {
+	mu                sync.Mutex
+	circuitBreakers   *expvar.Map
+	failoverEndpoints *expvar.Map
+}
+
+func NewMetricsRegistry() *MetricsRegistry {
+	return &MetricsRegistry{
+		circuitBreakers:   new(expvar.Map).Init(),
+		failoverEndpoints: new(expvar.Map).Init(),
+	}
+}
+
+// Publish publishes the metrics on /debug/vars, it can be called once per process
+func (r *MetricsRegistry) Publish() {
+	expvar.Publish("circuit_breakers", r.circuitBreakers)
+	expvar.Publish("failover_endpoints", r.failoverEndpoints)
+}
+
+// registerCircuitBreaker adds the metrics of a breaker, an empty or taken name is rejected
+func (r *MetricsRegistry) registerCircuitBreaker(name string, metrics *expvar.Map) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if name == "" || r.circuitBreakers.Get(name) != nil {
+		return fmt.Errorf("circuit breaker name must be unique and not empty: %q", name)
+	}
+	r.circuitBreakers.Set(name, metrics)
+	return nil
+}
+
+// registerFailoverEndpoints adds the metrics of the endpoints of a failover
+// sender, none is added if a name is already taken
+func (r *MetricsRegistry) registerFailoverEndpoints(metrics map[string]*expvar.Map) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for name := range metrics {
+		if r.failoverEndpoints.Get(name) != nil {
+			return fmt.Errorf("failover endpoint name is already registered: %q", name)
+		}
+	}
+	for name, endpointMetrics := range metrics {
+		r.failoverEndpoints.Set(name, endpointMetrics)
+	}
+	return nil
+}
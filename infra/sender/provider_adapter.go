@@ -0,0 +1,126 @@
+package sender
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"auto-message-sender/internal/models"
+)
+
+// ProviderAdapter translates messages to the request format of a provider and
+// its responses back. WebhookMessageSender handles the transport, status codes
+// and authentication.
+type ProviderAdapter interface {
+	// EncodeRequest returns the request body and its content type
+	EncodeRequest(message models.Message) (body []byte, contentType string, err error)
+	// DecodeResponse reads a successful response, the returned SentAt is not used
+	DecodeResponse(body io.Reader) (models.MessageSenderResponse, error)
+}
+
+// requestPreparer is implemented by adapters that change the method or the
+// headers of the request
+type requestPreparer interface {
+	PrepareRequest(req *http.Request)
+}
+
+// ProviderAdapterConfig is passed to adapter factories, adapters ignore the
+// fields they do not need
+type ProviderAdapterConfig struct {
+	// Sender is the sender id or number shown to the recipient
+	Sender string
+	// Template configures the "template" adapter
+	Template TemplateAdapterConfig
+}
+
+type ProviderAdapterFactory func(config ProviderAdapterConfig) (ProviderAdapter, error)
+
+const DefaultProviderAdapter = "webhook"
+
+var (
+	providerAdaptersMu sync.RWMutex
+	providerAdapters   = map[string]ProviderAdapterFactory{
+		DefaultProviderAdapter: newWebhookAdapter,
+		"form":                 newFormAdapter,
+		"batch_json":           newBatchJSONAdapter,
+		"template":             newTemplateAdapter,
+	}
+)
+
+// RegisterProviderAdapter makes an adapter selectable by name, registering a
+// name twice replaces the earlier factory
+func RegisterProviderAdapter(name string, factory ProviderAdapterFactory) {
+	providerAdaptersMu.Lock()
+	defer providerAdaptersMu.Unlock()
+	providerAdapters[name] = factory
+}
+
+// ProviderAdapterNames returns the registered adapter names, sorted
+func ProviderAdapterNames() []string {
+	providerAdaptersMu.RLock()
+	defer providerAdaptersMu.RUnlock()
+	return slices.Sorted(maps.Keys(providerAdapters))
+}
+
+// NewProviderAdapter creates the adapter registered as name
+func NewProviderAdapter(name string, config ProviderAdapterConfig) (ProviderAdapter, error) {
+	providerAdaptersMu.RLock()
+	factory, ok := providerAdapters[name]
+	providerAdaptersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown provider adapter %q, available adapters: %s", name, strings.Join(ProviderAdapterNames(), ", "))
+	}
+	adapter, err := factory(config)
+	if err != nil {
+		return nil, fmt.Errorf("provider adapter %q error: %w", name, err)
+	}
+	return adapter, nil
+}
+
+// webhookAdapter is the generic webhook format,
+// {"to","content"} answered with {"message","messageId"}
+type webhookAdapter struct{}
+
+func newWebhookAdapter(ProviderAdapterConfig) (ProviderAdapter, error) {
+	return webhookAdapter{}, nil
+}
+
+type webhookMessage struct {
+	To      string `json:"to"`
+	Content string `json:"content"`
+}
+
+type webhookMessageResponse struct {
+	Message   string `json:"message"`
+	MessageID string `json:"messageId"`
+}
+
+func (webhookAdapter) EncodeRequest(message models.Message) ([]byte, string, error) {
+	body := bytes.Buffer{}
+	err := json.NewEncoder(&body).Encode(webhookMessage{
+		To:      message.PhoneNumber,
+		Content: message.MessageContent,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("json.NewEncoder error: %w", err)
+	}
+	return body.Bytes(), "application/json", nil
+}
+
+func (webhookAdapter) DecodeResponse(body io.Reader) (models.MessageSenderResponse, error) {
+	var webhookMessageResponseData webhookMessageResponse
+	err := json.NewDecoder(body).Decode(&webhookMessageResponseData)
+	if err != nil {
+		return models.MessageSenderResponse{}, fmt.Errorf("json.NewDecoder error: %w", err)
+	}
+	return models.MessageSenderResponse{
+		Message:   webhookMessageResponseData.Message,
+		MessageID: webhookMessageResponseData.MessageID,
+	}, nil
+}
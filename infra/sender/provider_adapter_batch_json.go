@@ -0,0 +1,73 @@
+package sender
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"auto-message-sender/internal/models"
+)
+
+// batchJSONAdapter is the format of gateways that accept a list of recipients,
+// {"originator","recipients","body"} answered with
+// {"id","recipients":{"items":[{"recipient","status"}]}}
+type batchJSONAdapter struct {
+	originator string
+}
+
+func newBatchJSONAdapter(config ProviderAdapterConfig) (ProviderAdapter, error) {
+	if config.Sender == "" {
+		return nil, errors.New("sender is required")
+	}
+	return batchJSONAdapter{originator: config.Sender}, nil
+}
+
+type batchJSONMessage struct {
+	Originator string   `json:"originator"`
+	Recipients []string `json:"recipients"`
+	Body       string   `json:"body"`
+}
+
+type batchJSONMessageResponse struct {
+	ID         string `json:"id"`
+	Recipients struct {
+		Items []struct {
+			Recipient string `json:"recipient"`
+			Status    string `json:"status"`
+		} `json:"items"`
+	} `json:"recipients"`
+}
+
+func (a batchJSONAdapter) EncodeRequest(message models.Message) ([]byte, string, error) {
+	body := bytes.Buffer{}
+	err := json.NewEncoder(&body).Encode(batchJSONMessage{
+		Originator: a.originator,
+		Recipients: []string{message.PhoneNumber},
+		Body:       message.MessageContent,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("json.NewEncoder error: %w", err)
+	}
+	return body.Bytes(), "application/json", nil
+}
+
+func (batchJSONAdapter) DecodeResponse(body io.Reader) (models.MessageSenderResponse, error) {
+	var response batchJSONMessageResponse
+	err := json.NewDecoder(body).Decode(&response)
+	if err != nil {
+		return models.MessageSenderResponse{}, fmt.Errorf("json.NewDecoder error: %w", err)
+	}
+	if response.ID == "" {
+		return models.MessageSenderResponse{}, errors.New("batch json provider response has no id")
+	}
+	status := ""
+	if len(response.Recipients.Items) > 0 {
+		status = response.Recipients.Items[0].Status
+	}
+	return models.MessageSenderResponse{
+		Message:   status,
+		MessageID: response.ID,
+	}, nil
+}
@@ -0,0 +1,52 @@
+package sender
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+
+	"auto-message-sender/internal/models"
+)
+
+// formAdapter is the format of form-encoded SMS gateways, From, To and Body
+// fields answered with {"sid","status"}
+type formAdapter struct {
+	sender string
+}
+
+func newFormAdapter(config ProviderAdapterConfig) (ProviderAdapter, error) {
+	if config.Sender == "" {
+		return nil, errors.New("sender is required")
+	}
+	return formAdapter{sender: config.Sender}, nil
+}
+
+type formMessageResponse struct {
+	SID    string `json:"sid"`
+	Status string `json:"status"`
+}
+
+func (a formAdapter) EncodeRequest(message models.Message) ([]byte, string, error) {
+	values := url.Values{}
+	values.Set("From", a.sender)
+	values.Set("To", message.PhoneNumber)
+	values.Set("Body", message.MessageContent)
+	return []byte(values.Encode()), "application/x-www-form-urlencoded", nil
+}
+
+func (formAdapter) DecodeResponse(body io.Reader) (models.MessageSenderResponse, error) {
+	var response formMessageResponse
+	err := json.NewDecoder(body).Decode(&response)
+	if err != nil {
+		return models.MessageSenderResponse{}, fmt.Errorf("json.NewDecoder error: %w", err)
+	}
+	if response.SID == "" {
+		return models.MessageSenderResponse{}, errors.New("form provider response has no sid")
+	}
+	return models.MessageSenderResponse{
+		Message:   response.Status,
+		MessageID: response.SID,
+	}, nil
+}
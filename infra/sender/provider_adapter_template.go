@@ -0,0 +1,253 @@
+package sender
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"auto-message-sender/internal/models"
+)
+
+// TemplateAdapterConfig describes a provider format without writing an adapter.
+// Body is a text/template executed with the models.Message to send, e.g.
+// {"phone":{{json .PhoneNumber}},"text":{{json .MessageContent}}}. The json
+// function encodes a value as JSON.
+type TemplateAdapterConfig struct {
+	// Method is POST, PUT or PATCH, POST if empty
+	Method string
+	// ContentType is application/json if empty, JSON bodies are validated
+	ContentType string
+	Headers     map[string]string
+	Body        string
+	// MessageIDPath and StatusPath select the provider message id and the status
+	// in a JSON response, e.g. "$.data.messages[0].id". StatusPath is optional.
+	MessageIDPath string
+	StatusPath    string
+}
+
+var templateAdapterFuncs = template.FuncMap{
+	"json": func(value any) (string, error) {
+		data, err := json.Marshal(value)
+		return string(data), err
+	},
+}
+
+// templateAdapterSample is used to validate the template at startup
+var templateAdapterSample = models.Message{
+	MessageID:      "00000000-0000-0000-0000-000000000000",
+	PhoneNumber:    "+905551234567",
+	MessageContent: "sample \"message\" content",
+	SendingStatus:  "pending",
+	AttemptCount:   1,
+	CreatedAt:      time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+	UpdatedAt:      time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+}
+
+type templateAdapter struct {
+	method        string
+	contentType   string
+	headers       map[string]string
+	body          *template.Template
+	messageIDPath jsonPath
+	statusPath    jsonPath
+}
+
+func newTemplateAdapter(config ProviderAdapterConfig) (ProviderAdapter, error) {
+	return NewTemplateAdapter(config.Template)
+}
+
+// NewTemplateAdapter validates config and creates the adapter, errors name the
+// invalid setting
+func NewTemplateAdapter(config TemplateAdapterConfig) (ProviderAdapter, error) {
+	a := &templateAdapter{
+		method:      cmp.Or(strings.ToUpper(config.Method), http.MethodPost),
+		contentType: cmp.Or(config.ContentType, "application/json"),
+		headers:     config.Headers,
+	}
+	if a.method != http.MethodPost && a.method != http.MethodPut && a.method != http.MethodPatch {
+		return nil, fmt.Errorf("template method must be POST, PUT or PATCH: %q", config.Method)
+	}
+	mediaType, _, err := mime.ParseMediaType(a.contentType)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template content type %q: %w", a.contentType, err)
+	}
+	if strings.TrimSpace(config.Body) == "" {
+		return nil, errors.New("template body is required")
+	}
+	a.body, err = template.New("body").Option("missingkey=error").Funcs(templateAdapterFuncs).Parse(config.Body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template body: %w", err)
+	}
+	sample, _, err := a.EncodeRequest(templateAdapterSample)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template body: %w", err)
+	}
+	if (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")) && !json.Valid(sample) {
+		return nil, fmt.Errorf("template body is not valid JSON for a sample message, use the json function to quote values: %s", sample)
+	}
+	if config.MessageIDPath == "" {
+		return nil, errors.New("template message id path is required")
+	}
+	a.messageIDPath, err = parseJSONPath(config.MessageIDPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template message id path: %w", err)
+	}
+	if config.StatusPath != "" {
+		a.statusPath, err = parseJSONPath(config.StatusPath)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template status path: %w", err)
+		}
+	}
+	return a, nil
+}
+
+func (a *templateAdapter) EncodeRequest(message models.Message) ([]byte, string, error) {
+	body := bytes.Buffer{}
+	err := a.body.Execute(&body, message)
+	if err != nil {
+		return nil, "", fmt.Errorf("template.Execute error: %w", err)
+	}
+	return body.Bytes(), a.contentType, nil
+}
+
+// PrepareRequest sets the method and the headers of the template
+func (a *templateAdapter) PrepareRequest(req *http.Request) {
+	req.Method = a.method
+	for name, value := range a.headers {
+		req.Header.Set(name, value)
+	}
+}
+
+func (a *templateAdapter) DecodeResponse(body io.Reader) (models.MessageSenderResponse, error) {
+	decoder := json.NewDecoder(body)
+	decoder.UseNumber()
+	var document any
+	err := decoder.Decode(&document)
+	if err != nil {
+		return models.MessageSenderResponse{}, fmt.Errorf("json.NewDecoder error: %w", err)
+	}
+	messageID, err := a.messageIDPath.lookup(document)
+	if err != nil {
+		return models.MessageSenderResponse{}, fmt.Errorf("response message id error: %w", err)
+	}
+	if messageID == "" {
+		return models.MessageSenderResponse{}, fmt.Errorf("response message id %s is empty", a.messageIDPath)
+	}
+	var status string
+	if a.statusPath != nil {
+		status, err = a.statusPath.lookup(document)
+		if err != nil {
+			return models.MessageSenderResponse{}, fmt.Errorf("response status error: %w", err)
+		}
+	}
+	return models.MessageSenderResponse{
+		Message:   status,
+		MessageID: messageID,
+	}, nil
+}
+
+// jsonPath is a parsed path of object keys and array indexes, a string step
+// is a key and an int step is an index
+type jsonPath []any
+
+// parseJSONPath parses a JSONPath subset, dotted keys and [n] indexes with an
+// optional leading "$", e.g. "$.messages[0].id"
+func parseJSONPath(path string) (jsonPath, error) {
+	rest := strings.TrimPrefix(strings.TrimSpace(path), "$")
+	var steps jsonPath
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end == -1 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("empty key in %q", path)
+			}
+			steps = append(steps, rest[:end])
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end == -1 {
+				return nil, fmt.Errorf("unclosed [ in %q", path)
+			}
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("invalid index %q in %q", rest[1:end], path)
+			}
+			steps = append(steps, index)
+			rest = rest[end+1:]
+		default:
+			if steps != nil {
+				return nil, fmt.Errorf("unexpected %q in %q", rest[0], path)
+			}
+			// a path may start with a key without the leading "$."
+			rest = "." + rest
+		}
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("empty path %q", path)
+	}
+	return steps, nil
+}
+
+// lookup returns the value at the path formatted as a string
+func (p jsonPath) lookup(document any) (string, error) {
+	value := document
+	for _, step := range p {
+		switch step := step.(type) {
+		case string:
+			object, ok := value.(map[string]any)
+			if !ok {
+				return "", fmt.Errorf("%s: %q is not in an object", p, step)
+			}
+			value, ok = object[step]
+			if !ok {
+				return "", fmt.Errorf("%s: key %q not found", p, step)
+			}
+		case int:
+			array, ok := value.([]any)
+			if !ok || step >= len(array) {
+				return "", fmt.Errorf("%s: index %d not found", p, step)
+			}
+			value = array[step]
+		}
+	}
+	switch value := value.(type) {
+	case string:
+		return value, nil
+	case json.Number:
+		return value.String(), nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	case nil:
+		return "", nil
+	default:
+		return "", fmt.Errorf("%s is not a string or a number", p)
+	}
+}
+
+func (p jsonPath) String() string {
+	var path strings.Builder
+	path.WriteString("$")
+	for _, step := range p {
+		switch step := step.(type) {
+		case string:
+			path.WriteString("." + step)
+		case int:
+			path.WriteString("[" + strconv.Itoa(step) + "]")
+		}
+	}
+	return path.String()
+}
@@ -0,0 +1,123 @@
+package sender
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"auto-message-sender/internal/models"
+)
+
+func TestNewTemplateAdapterValidation(t *testing.T) {
+	valid := TemplateAdapterConfig{
+		Body:          `{"to":{{json .PhoneNumber}},"text":{{json .MessageContent}}}`,
+		MessageIDPath: "$.id",
+	}
+	tests := []struct {
+		name    string
+		change  func(config *TemplateAdapterConfig)
+		wantErr string
+	}{
+		{name: "valid", change: func(*TemplateAdapterConfig) {}},
+		{name: "method", change: func(c *TemplateAdapterConfig) { c.Method = "GET" }, wantErr: "method"},
+		{name: "empty body", change: func(c *TemplateAdapterConfig) { c.Body = " " }, wantErr: "body is required"},
+		{name: "syntax", change: func(c *TemplateAdapterConfig) { c.Body = `{"to":{{json .PhoneNumber}` }, wantErr: "invalid template body"},
+		{name: "unknown field", change: func(c *TemplateAdapterConfig) { c.Body = `{"to":{{json .Phone}}}` }, wantErr: "invalid template body"},
+		{name: "unquoted value", change: func(c *TemplateAdapterConfig) { c.Body = `{"text":"{{.MessageContent}}"}` }, wantErr: "not valid JSON"},
+		{name: "form body", change: func(c *TemplateAdapterConfig) {
+			c.ContentType = "application/x-www-form-urlencoded"
+			c.Body = `to={{urlquery .PhoneNumber}}&text={{urlquery .MessageContent}}`
+		}},
+		{name: "missing id path", change: func(c *TemplateAdapterConfig) { c.MessageIDPath = "" }, wantErr: "message id path is required"},
+		{name: "invalid id path", change: func(c *TemplateAdapterConfig) { c.MessageIDPath = "$.items[x]" }, wantErr: "invalid template message id path"},
+		{name: "invalid status path", change: func(c *TemplateAdapterConfig) { c.StatusPath = "$..status" }, wantErr: "invalid template status path"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := valid
+			tt.change(&config)
+			_, err := NewTemplateAdapter(config)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestTemplateAdapterRequest(t *testing.T) {
+	var request *http.Request
+	var requestBody []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request = r
+		requestBody, _ = io.ReadAll(r.Body)
+		_, _ = w.Write([]byte(`{"data":{"messages":[{"id":12345,"state":"queued"}]}}`))
+	}))
+	defer server.Close()
+	adapter, err := NewTemplateAdapter(TemplateAdapterConfig{
+		Method:        "put",
+		Headers:       map[string]string{"X-Account": "acme"},
+		Body:          `{"to":{{json .PhoneNumber}},"text":{{json .MessageContent}},"ref":{{json .IdempotencyKey}}}`,
+		MessageIDPath: "data.messages[0].id",
+		StatusPath:    "$.data.messages[0].state",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := NewWebhookMessageSender(server.URL, WithProviderAdapter(adapter)).SendMessage(context.Background(), models.Message{
+		MessageID:      "1",
+		PhoneNumber:    "+905551234567",
+		MessageContent: `say "hi"`,
+		ReplayCount:    3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request.Method != http.MethodPut || request.Header.Get("X-Account") != "acme" || request.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected request %s %v", request.Method, request.Header)
+	}
+	if want := `{"to":"+905551234567","text":"say \"hi\"","ref":"1-3"}`; string(requestBody) != want {
+		t.Fatalf("unexpected body %s, want %s", requestBody, want)
+	}
+	if response.MessageID != "12345" || response.Message != "queued" {
+		t.Fatalf("unexpected response %+v", response)
+	}
+}
+
+func TestJSONPathLookup(t *testing.T) {
+	document := map[string]any{
+		"id":    "abc",
+		"items": []any{map[string]any{"status": "sent"}},
+	}
+	tests := []struct {
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{path: "$.id", want: "abc"},
+		{path: "id", want: "abc"},
+		{path: "$.items[0].status", want: "sent"},
+		{path: "$.items[1].status", wantErr: true},
+		{path: "$.missing", wantErr: true},
+		{path: "$.items", wantErr: true},
+	}
+	for _, tt := range tests {
+		path, err := parseJSONPath(tt.path)
+		if err != nil {
+			t.Fatalf("parseJSONPath(%q): %v", tt.path, err)
+		}
+		got, err := path.lookup(document)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("lookup(%q) = %q, %v", tt.path, got, err)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"auto-message-sender/infra/sender/sendertest"
+	"auto-message-sender/internal/models"
+)
+
+// providerFakes has a local stand-in and a config for every registered adapter,
+// the contract test fails for an adapter without one
+var providerFakes = map[string]struct {
+	newFakeProvider func() *sendertest.FakeProvider
+	config          ProviderAdapterConfig
+}{
+	"webhook":    {newFakeProvider: sendertest.NewWebhookProvider},
+	"form":       {newFakeProvider: sendertest.NewFormProvider, config: ProviderAdapterConfig{Sender: "ACME"}},
+	"batch_json": {newFakeProvider: sendertest.NewBatchJSONProvider, config: ProviderAdapterConfig{Sender: "ACME"}},
+	"template": {
+		newFakeProvider: sendertest.NewBatchJSONProvider,
+		config: ProviderAdapterConfig{Template: TemplateAdapterConfig{
+			Body:          `{"originator":"ACME","recipients":[{{json .PhoneNumber}}],"body":{{json .MessageContent}}}`,
+			MessageIDPath: "$.id",
+			StatusPath:    "$.recipients.items[0].status",
+		}},
+	},
+}
+
+func TestProviderAdapterContract(t *testing.T) {
+	for _, name := range ProviderAdapterNames() {
+		t.Run(name, func(t *testing.T) {
+			providerFake, ok := providerFakes[name]
+			if !ok {
+				t.Fatalf("provider adapter %q has no fake provider", name)
+			}
+			provider := providerFake.newFakeProvider()
+			defer provider.Close()
+			adapter, err := NewProviderAdapter(name, providerFake.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			webhookMessageSender := NewWebhookMessageSender(provider.URL, WithProviderAdapter(adapter))
+			message := models.Message{MessageID: "1", PhoneNumber: "+905551234567", MessageContent: "hello & welcome", AttemptCount: 1}
+			ctx := context.Background()
+
+			response, err := webhookMessageSender.SendMessage(ctx, message)
+			if err != nil {
+				t.Fatalf("accepted message: %v", err)
+			}
+			if response.MessageID != "provider-1" || response.SentAt.IsZero() {
+				t.Fatalf("accepted message: unexpected response %+v", response)
+			}
+			received := provider.Messages()
+			if len(received) != 1 || received[0].To != message.PhoneNumber || received[0].Content != message.MessageContent || received[0].IdempotencyKey != "1-0" {
+				t.Fatalf("accepted message: provider received %+v", received)
+			}
+
+			provider.FailWith(http.StatusBadRequest, nil)
+			_, err = webhookMessageSender.SendMessage(ctx, message)
+			var sendError *models.SendError
+			if !errors.As(err, &sendError) || sendError.Retryable || sendError.StatusCode != http.StatusBadRequest {
+				t.Fatalf("rejected message: expected permanent error, got %v", err)
+			}
+
+			provider.FailWith(http.StatusTooManyRequests, http.Header{"Retry-After": {"60"}})
+			_, err = webhookMessageSender.SendMessage(ctx, message)
+			if !errors.As(err, &sendError) || !sendError.Retryable || sendError.RetryAfter != time.Minute {
+				t.Fatalf("throttled message: expected retryable error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestNewProviderAdapter(t *testing.T) {
+	_, err := NewProviderAdapter("unknown", ProviderAdapterConfig{})
+	if err == nil {
+		t.Fatal("expected unknown adapter error")
+	}
+	_, err = NewProviderAdapter("form", ProviderAdapterConfig{})
+	if err == nil {
+		t.Fatal("expected missing sender error")
+	}
+}
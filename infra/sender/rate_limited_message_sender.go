@@ -0,0 +1,268 @@
+package sender
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"auto-message-sender/internal/models"
+)
+
+const (
+	RateLimitModeDefer = "defer"
+	RateLimitModeFail  = "fail"
+)
+
+// RateLimitConfig limits the outbound requests of a provider and the messages
+// sent to a single recipient. Limits are kept in memory, every instance of
+// the application enforces them on its own.
+type RateLimitConfig struct {
+	// RequestsPerSecond is the rate of the global token bucket, 0 disables it
+	RequestsPerSecond float64
+	// Burst is the capacity of the global token bucket
+	Burst int
+	// MaxWait is how long a send may wait for a token before the limit is hit
+	MaxWait time.Duration
+	// RecipientLimit is the number of messages a recipient may receive in
+	// RecipientWindow, 0 disables it
+	RecipientLimit  int
+	RecipientWindow time.Duration
+	// Mode decides what happens to a message when a limit is hit. It is deferred
+	// without counting the attempt, or it fails like any other send error.
+	Mode string
+}
+
+func (c RateLimitConfig) Validate() error {
+	if c.RequestsPerSecond < 0 || math.IsNaN(c.RequestsPerSecond) || math.IsInf(c.RequestsPerSecond, 0) {
+		return fmt.Errorf("rate limit requests per second must not be negative: %v", c.RequestsPerSecond)
+	}
+	if c.RequestsPerSecond > 0 && c.Burst < 1 {
+		return fmt.Errorf("rate limit burst must be at least 1: %d", c.Burst)
+	}
+	if c.MaxWait < 0 {
+		return fmt.Errorf("rate limit max wait must not be negative: %s", c.MaxWait)
+	}
+	if c.RecipientLimit < 0 {
+		return fmt.Errorf("rate limit recipient limit must not be negative: %d", c.RecipientLimit)
+	}
+	if c.RecipientLimit > 0 && c.RecipientWindow <= 0 {
+		return fmt.Errorf("rate limit recipient window must be positive: %s", c.RecipientWindow)
+	}
+	if c.Mode != RateLimitModeDefer && c.Mode != RateLimitModeFail {
+		return fmt.Errorf("rate limit mode must be %q or %q: %q", RateLimitModeDefer, RateLimitModeFail, c.Mode)
+	}
+	return nil
+}
+
+var _ messageSender = (*RateLimitedMessageSender)(nil)
+
+// RateLimitedMessageSender is a messageSender decorator that enforces a
+// RateLimitConfig before calling the base sender. The recipient slot is given
+// back when the base sender did not send the message either.
+type RateLimitedMessageSender struct {
+	baseService messageSender
+	config      RateLimitConfig
+	bucket      *tokenBucket
+	recipients  *recipientLimiter
+	now         func() time.Time
+}
+
+func NewRateLimitedMessageSender(baseService messageSender, config RateLimitConfig) *RateLimitedMessageSender {
+	s := &RateLimitedMessageSender{
+		baseService: baseService,
+		config:      config,
+		now:         time.Now,
+	}
+	if config.RequestsPerSecond > 0 {
+		s.bucket = newTokenBucket(config.RequestsPerSecond, config.Burst)
+	}
+	if config.RecipientLimit > 0 {
+		s.recipients = newRecipientLimiter(config.RecipientLimit, config.RecipientWindow)
+	}
+	return s
+}
+
+func (s *RateLimitedMessageSender) SendMessage(ctx context.Context, message models.Message) (models.MessageSenderResponse, error) {
+	now := s.now()
+	var recipient string
+	if s.recipients != nil {
+		recipient = recipientKey(message)
+		retryAfter, ok := s.recipients.allow(recipient, now)
+		if !ok {
+			return models.MessageSenderResponse{}, s.limitError("recipient limit reached", retryAfter)
+		}
+	}
+	if s.bucket != nil {
+		wait, ok := s.bucket.reserve(now, s.config.MaxWait)
+		if !ok {
+			if s.recipients != nil {
+				s.recipients.release(recipient, now)
+			}
+			return models.MessageSenderResponse{}, s.limitError("provider rate limit reached", wait)
+		}
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				// the message is not sent, its token and recipient slot are given back
+				timer.Stop()
+				s.bucket.cancel()
+				if s.recipients != nil {
+					s.recipients.release(recipient, now)
+				}
+				return models.MessageSenderResponse{}, ctx.Err()
+			case <-timer.C:
+			}
+		}
+	}
+	response, err := s.baseService.SendMessage(ctx, message)
+	if err != nil && s.recipients != nil && notSent(err) {
+		// e.g. the limits of every endpoint behind a failover were hit
+		s.recipients.release(recipient, now)
+	}
+	return response, err
+}
+
+func (s *RateLimitedMessageSender) limitError(reason string, retryAfter time.Duration) error {
+	err := fmt.Errorf("%w: %s, retry after %s", models.ErrRateLimited, reason, retryAfter)
+	if s.config.Mode == RateLimitModeDefer {
+		return &models.DeferredSendError{Err: err, Delay: retryAfter}
+	}
+	return err
+}
+
+// tokenBucket hands out tokens at rate per second up to burst. Tokens may be
+// reserved in advance, the bucket goes negative then.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket creates a full bucket, it starts refilling at the first reserve
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+	}
+}
+
+// reserve takes a token and returns how long the caller has to wait before
+// using it. If the wait would exceed maxWait no token is taken, the returned
+// duration is the wait then.
+func (b *tokenBucket) reserve(now time.Time, maxWait time.Duration) (time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.last.IsZero() {
+		b.last = now
+	}
+	if now.After(b.last) {
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+	}
+	tokens := b.tokens - 1
+	var wait time.Duration
+	if tokens < 0 {
+		wait = time.Duration(-tokens / b.rate * float64(time.Second))
+	}
+	if wait > maxWait {
+		return wait, false
+	}
+	b.tokens = tokens
+	return wait, true
+}
+
+// cancel gives back a token taken by reserve that was not used
+func (b *tokenBucket) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = min(b.burst, b.tokens+1)
+}
+
+// recipientLimiter allows at most limit messages per recipient in a sliding window
+type recipientLimiter struct {
+	mu        sync.Mutex
+	limit     int
+	window    time.Duration
+	sent      map[string][]time.Time
+	lastSweep time.Time
+}
+
+// recipientKey is the recipient of message in the limiter, recipients of
+// different channels are limited apart
+func recipientKey(message models.Message) string {
+	channel := message.MessageChannel()
+	if channel == models.ChannelSMS {
+		return channel + ":" + message.PhoneNumber
+	}
+	return channel + ":" + message.Recipient
+}
+
+func newRecipientLimiter(limit int, window time.Duration) *recipientLimiter {
+	return &recipientLimiter{
+		limit:  limit,
+		window: window,
+		sent:   make(map[string][]time.Time),
+	}
+}
+
+// allow records a message to recipient at now if the limit allows it,
+// otherwise it returns how long until the oldest message leaves the window
+func (l *recipientLimiter) allow(recipient string, now time.Time) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+	sent := l.prune(l.sent[recipient], now)
+	if len(sent) >= l.limit {
+		l.sent[recipient] = sent
+		return sent[0].Add(l.window).Sub(now), false
+	}
+	l.sent[recipient] = append(sent, now)
+	return 0, true
+}
+
+// release removes a message recorded at sentAt, it is used when the message
+// was not sent after all
+func (l *recipientLimiter) release(recipient string, sentAt time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	sent := l.sent[recipient]
+	for i := len(sent) - 1; i >= 0; i-- {
+		if sent[i].Equal(sentAt) {
+			l.sent[recipient] = append(sent[:i], sent[i+1:]...)
+			break
+		}
+	}
+	if len(l.sent[recipient]) == 0 {
+		delete(l.sent, recipient)
+	}
+}
+
+func (l *recipientLimiter) prune(sent []time.Time, now time.Time) []time.Time {
+	i := 0
+	for i < len(sent) && !sent[i].Add(l.window).After(now) {
+		i++
+	}
+	return sent[i:]
+}
+
+// sweep drops recipients without messages in the window, once per window
+func (l *recipientLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.window {
+		return
+	}
+	l.lastSweep = now
+	for recipient, sent := range l.sent {
+		sent = l.prune(sent, now)
+		if len(sent) == 0 {
+			delete(l.sent, recipient)
+			continue
+		}
+		l.sent[recipient] = sent
+	}
+}
@@ -0,0 +1,212 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"auto-message-sender/internal/models"
+)
+
+type countingMessageSender struct {
+	sent int
+}
+
+func (s *countingMessageSender) SendMessage(_ context.Context, message models.Message) (models.MessageSenderResponse, error) {
+	s.sent++
+	return models.MessageSenderResponse{Message: "Accepted", MessageID: "provider-" + message.MessageID}, nil
+}
+
+func newTestRateLimitedMessageSender(config RateLimitConfig, now *time.Time) (*RateLimitedMessageSender, *countingMessageSender) {
+	baseService := &countingMessageSender{}
+	s := NewRateLimitedMessageSender(baseService, config)
+	s.now = func() time.Time { return *now }
+	return s, baseService
+}
+
+func TestRateLimitedMessageSenderRecipientLimit(t *testing.T) {
+	now := time.Date(2025, 11, 13, 10, 0, 0, 0, time.UTC)
+	s, baseService := newTestRateLimitedMessageSender(RateLimitConfig{
+		RecipientLimit:  2,
+		RecipientWindow: time.Hour,
+		Mode:            RateLimitModeDefer,
+	}, &now)
+	message := models.Message{MessageID: "1", PhoneNumber: "+905551234567"}
+
+	for range 2 {
+		_, err := s.SendMessage(context.Background(), message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		now = now.Add(10 * time.Minute)
+	}
+	_, err := s.SendMessage(context.Background(), message)
+	var deferredSendError *models.DeferredSendError
+	if !errors.As(err, &deferredSendError) || !errors.Is(err, models.ErrRateLimited) {
+		t.Fatalf("expected deferred rate limit error, got %v", err)
+	}
+	if deferredSendError.Delay != 40*time.Minute {
+		t.Fatalf("expected 40m delay, got %s", deferredSendError.Delay)
+	}
+	_, err = s.SendMessage(context.Background(), models.Message{MessageID: "2", PhoneNumber: "+905559876543"})
+	if err != nil {
+		t.Fatalf("other recipient: %v", err)
+	}
+	now = now.Add(40 * time.Minute)
+	_, err = s.SendMessage(context.Background(), message)
+	if err != nil {
+		t.Fatalf("after the window: %v", err)
+	}
+	if baseService.sent != 4 {
+		t.Fatalf("expected 4 sent messages, got %d", baseService.sent)
+	}
+}
+
+func TestRateLimitedMessageSenderRecipientChannels(t *testing.T) {
+	now := time.Date(2025, 11, 13, 10, 0, 0, 0, time.UTC)
+	s, baseService := newTestRateLimitedMessageSender(RateLimitConfig{
+		RecipientLimit:  1,
+		RecipientWindow: time.Hour,
+		Mode:            RateLimitModeFail,
+	}, &now)
+
+	for _, message := range []models.Message{
+		{MessageID: "1", PhoneNumber: "+905551234567"},
+		{MessageID: "2", Channel: models.ChannelEmail, Recipient: "first@example.com"},
+		{MessageID: "3", Channel: models.ChannelEmail, Recipient: "second@example.com"},
+		{MessageID: "4", Channel: models.ChannelChat, Recipient: "first@example.com"},
+	} {
+		_, err := s.SendMessage(context.Background(), message)
+		if err != nil {
+			t.Fatalf("message %s: %v", message.MessageID, err)
+		}
+	}
+	_, err := s.SendMessage(context.Background(), models.Message{MessageID: "5", Channel: models.ChannelEmail, Recipient: "first@example.com"})
+	if !errors.Is(err, models.ErrRateLimited) {
+		t.Fatalf("expected the email recipient to be rate limited, got %v", err)
+	}
+	if baseService.sent != 4 {
+		t.Fatalf("expected 4 sent messages, got %d", baseService.sent)
+	}
+}
+
+func TestRateLimitedMessageSenderProviderLimit(t *testing.T) {
+	now := time.Date(2025, 11, 13, 10, 0, 0, 0, time.UTC)
+	s, baseService := newTestRateLimitedMessageSender(RateLimitConfig{
+		RequestsPerSecond: 2,
+		Burst:             2,
+		RecipientLimit:    10,
+		RecipientWindow:   time.Hour,
+		Mode:              RateLimitModeFail,
+	}, &now)
+	message := models.Message{MessageID: "1", PhoneNumber: "+905551234567"}
+
+	for range 2 {
+		_, err := s.SendMessage(context.Background(), message)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := s.SendMessage(context.Background(), message)
+	var deferredSendError *models.DeferredSendError
+	if !errors.Is(err, models.ErrRateLimited) || errors.As(err, &deferredSendError) {
+		t.Fatalf("expected failed rate limit error, got %v", err)
+	}
+	// the rejected message must not count against the recipient limit
+	if sent := len(s.recipients.sent[recipientKey(message)]); sent != 2 {
+		t.Fatalf("expected 2 recorded messages, got %d", sent)
+	}
+	now = now.Add(time.Second)
+	for range 2 {
+		_, err = s.SendMessage(context.Background(), message)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if baseService.sent != 4 {
+		t.Fatalf("expected 4 sent messages, got %d", baseService.sent)
+	}
+}
+
+func TestRateLimitedMessageSenderCancelledWait(t *testing.T) {
+	now := time.Date(2025, 11, 13, 10, 0, 0, 0, time.UTC)
+	s, baseService := newTestRateLimitedMessageSender(RateLimitConfig{
+		RequestsPerSecond: 1,
+		Burst:             1,
+		MaxWait:           time.Hour,
+		RecipientLimit:    10,
+		RecipientWindow:   time.Hour,
+		Mode:              RateLimitModeDefer,
+	}, &now)
+	message := models.Message{MessageID: "1", PhoneNumber: "+905551234567"}
+
+	_, err := s.SendMessage(context.Background(), message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = s.SendMessage(ctx, message)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the cancelled wait to return context.Canceled, got %v", err)
+	}
+	if sent := len(s.recipients.sent[recipientKey(message)]); sent != 1 || baseService.sent != 1 {
+		t.Fatalf("expected only the first message to be recorded, got %d recorded and %d sent", sent, baseService.sent)
+	}
+	// the token of the cancelled send is given back, the next one waits a single interval
+	if wait, ok := s.bucket.reserve(now, time.Hour); !ok || wait != time.Second {
+		t.Fatalf("expected to wait 1s, got %s %v", wait, ok)
+	}
+}
+
+func TestRateLimitedMessageSenderPerEndpointLimits(t *testing.T) {
+	now := time.Date(2025, 11, 13, 10, 0, 0, 0, time.UTC)
+	endpointConfig := RateLimitConfig{RequestsPerSecond: 1, Burst: 1, Mode: RateLimitModeDefer}
+	primary, primaryService := newTestRateLimitedMessageSender(endpointConfig, &now)
+	backup, backupService := newTestRateLimitedMessageSender(endpointConfig, &now)
+	failoverMessageSender := newTestFailoverMessageSender(t, []FailoverEndpoint{
+		{Name: "primary", Sender: primary},
+		{Name: "backup", Priority: 1, Sender: backup},
+	}, FailoverConfig{})
+	s := NewRateLimitedMessageSender(failoverMessageSender, RateLimitConfig{
+		RecipientLimit:  1,
+		RecipientWindow: time.Hour,
+		Mode:            RateLimitModeDefer,
+	})
+	s.now = func() time.Time { return now }
+
+	// the limit of the primary does not hold back the backup
+	for _, phoneNumber := range []string{"+905551234567", "+905559876543"} {
+		_, err := s.SendMessage(context.Background(), models.Message{MessageID: "1", PhoneNumber: phoneNumber})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if primaryService.sent != 1 || backupService.sent != 1 {
+		t.Fatalf("expected a message per endpoint, got primary %d, backup %d", primaryService.sent, backupService.sent)
+	}
+	// the recipient slot of a message no endpoint could send is given back
+	message := models.Message{MessageID: "2", PhoneNumber: "+905550001122"}
+	_, err := s.SendMessage(context.Background(), message)
+	if !errors.Is(err, models.ErrRateLimited) {
+		t.Fatalf("expected every endpoint to be rate limited, got %v", err)
+	}
+	if _, ok := s.recipients.sent[recipientKey(message)]; ok {
+		t.Fatal("expected the recipient slot to be given back")
+	}
+}
+
+func TestTokenBucketReserve(t *testing.T) {
+	now := time.Date(2025, 11, 13, 10, 0, 0, 0, time.UTC)
+	bucket := newTokenBucket(10, 1)
+	if wait, ok := bucket.reserve(now, time.Second); !ok || wait != 0 {
+		t.Fatalf("expected an immediate token, got %s %v", wait, ok)
+	}
+	if wait, ok := bucket.reserve(now, time.Second); !ok || wait != 100*time.Millisecond {
+		t.Fatalf("expected to wait 100ms, got %s %v", wait, ok)
+	}
+	if wait, ok := bucket.reserve(now, 150*time.Millisecond); ok || wait != 200*time.Millisecond {
+		t.Fatalf("expected the limit to be hit with a 200ms wait, got %s %v", wait, ok)
+	}
+}
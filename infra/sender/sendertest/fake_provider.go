@@ -0,0 +1,184 @@
+// Package sendertest provides local stand-ins of the providers and servers the
+// sender package sends to, for tests and benchmarks
+package sendertest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+)
+
+// ReceivedMessage is a message accepted by a FakeProvider
+type ReceivedMessage struct {
+	Sender         string
+	To             string
+	Content        string
+	IdempotencyKey string
+	Header         http.Header
+}
+
+// FakeProvider is an httptest server that accepts messages in the format of a
+// provider. A request that does not match the format is answered with 400.
+type FakeProvider struct {
+	*httptest.Server
+	decode  func(r *http.Request) (ReceivedMessage, error)
+	respond func(w http.ResponseWriter, providerMessageID string, message ReceivedMessage)
+
+	mu         sync.Mutex
+	messages   []ReceivedMessage
+	failStatus int
+	failHeader http.Header
+}
+
+func newFakeProvider(
+	decode func(r *http.Request) (ReceivedMessage, error),
+	respond func(w http.ResponseWriter, providerMessageID string, message ReceivedMessage),
+) *FakeProvider {
+	p := &FakeProvider{
+		decode:  decode,
+		respond: respond,
+	}
+	p.Server = httptest.NewServer(http.HandlerFunc(p.serveHTTP))
+	return p
+}
+
+// FailWith makes the provider answer every request with statusCode and header,
+// 0 accepts requests again
+func (p *FakeProvider) FailWith(statusCode int, header http.Header) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failStatus = statusCode
+	p.failHeader = header
+}
+
+// Messages returns the accepted messages in the order they were received
+func (p *FakeProvider) Messages() []ReceivedMessage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]ReceivedMessage(nil), p.messages...)
+}
+
+func (p *FakeProvider) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	failStatus, failHeader := p.failStatus, p.failHeader
+	p.mu.Unlock()
+	if failStatus != 0 {
+		for key, values := range failHeader {
+			w.Header()[key] = values
+		}
+		http.Error(w, http.StatusText(failStatus), failStatus)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	message, err := p.decode(r)
+	if err == nil && (message.To == "" || message.Content == "") {
+		err = errors.New("recipient and content are required")
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	message.IdempotencyKey = r.Header.Get("Idempotency-Key")
+	message.Header = r.Header.Clone()
+	p.mu.Lock()
+	p.messages = append(p.messages, message)
+	providerMessageID := "provider-" + strconv.Itoa(len(p.messages))
+	p.mu.Unlock()
+	p.respond(w, providerMessageID, message)
+}
+
+func requireContentType(r *http.Request, contentType string) error {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != contentType {
+		return fmt.Errorf("content type must be %s", contentType)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// NewWebhookProvider accepts the generic webhook format, {"to","content"} answered
+// with 202 {"message","messageId"}
+func NewWebhookProvider() *FakeProvider {
+	return newFakeProvider(func(r *http.Request) (ReceivedMessage, error) {
+		err := requireContentType(r, "application/json")
+		if err != nil {
+			return ReceivedMessage{}, err
+		}
+		var request struct {
+			To      string `json:"to"`
+			Content string `json:"content"`
+		}
+		err = json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			return ReceivedMessage{}, err
+		}
+		return ReceivedMessage{To: request.To, Content: request.Content}, nil
+	}, func(w http.ResponseWriter, providerMessageID string, _ ReceivedMessage) {
+		writeJSON(w, http.StatusAccepted, map[string]string{"message": "Accepted", "messageId": providerMessageID})
+	})
+}
+
+// NewFormProvider accepts form-encoded From, To and Body fields answered with
+// 201 {"sid","status"}
+func NewFormProvider() *FakeProvider {
+	return newFakeProvider(func(r *http.Request) (ReceivedMessage, error) {
+		err := requireContentType(r, "application/x-www-form-urlencoded")
+		if err != nil {
+			return ReceivedMessage{}, err
+		}
+		err = r.ParseForm()
+		if err != nil {
+			return ReceivedMessage{}, err
+		}
+		if r.PostForm.Get("From") == "" {
+			return ReceivedMessage{}, errors.New("From is required")
+		}
+		return ReceivedMessage{Sender: r.PostForm.Get("From"), To: r.PostForm.Get("To"), Content: r.PostForm.Get("Body")}, nil
+	}, func(w http.ResponseWriter, providerMessageID string, _ ReceivedMessage) {
+		writeJSON(w, http.StatusCreated, map[string]string{"sid": providerMessageID, "status": "queued"})
+	})
+}
+
+// NewBatchJSONProvider accepts {"originator","recipients","body"} with a single
+// recipient answered with 200 {"id","recipients":{"items":[{"recipient","status"}]}}
+func NewBatchJSONProvider() *FakeProvider {
+	return newFakeProvider(func(r *http.Request) (ReceivedMessage, error) {
+		err := requireContentType(r, "application/json")
+		if err != nil {
+			return ReceivedMessage{}, err
+		}
+		var request struct {
+			Originator string   `json:"originator"`
+			Recipients []string `json:"recipients"`
+			Body       string   `json:"body"`
+		}
+		err = json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			return ReceivedMessage{}, err
+		}
+		if request.Originator == "" || len(request.Recipients) != 1 {
+			return ReceivedMessage{}, errors.New("originator and one recipient are required")
+		}
+		return ReceivedMessage{Sender: request.Originator, To: request.Recipients[0], Content: request.Body}, nil
+	}, func(w http.ResponseWriter, providerMessageID string, message ReceivedMessage) {
+		writeJSON(w, http.StatusOK, map[string]any{
+			"id": providerMessageID,
+			"recipients": map[string]any{
+				"items": []map[string]string{{"recipient": message.To, "status": "sent"}},
+			},
+		})
+	})
+}
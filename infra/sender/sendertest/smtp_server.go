@@ -0,0 +1,221 @@
+package sendertest
+
+import (
+	"bufio"
+	"encoding/base64"
+	"io"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+)
+
+// SMTPServer is a minimal local SMTP server that accepts every message, it
+// supports EHLO, AUTH PLAIN, MAIL, RCPT, DATA, RSET, NOOP and QUIT
+type SMTPServer struct {
+	// Addr is the host:port the server listens on
+	Addr     string
+	listener net.Listener
+	username string
+	password string
+	wg       sync.WaitGroup
+
+	mu          sync.Mutex
+	conns       map[net.Conn]struct{}
+	messages    []ReceivedMessage
+	failCode    int
+	failMessage string
+}
+
+// NewSMTPServer starts an SMTP server on a local port. If username is not empty
+// clients must authenticate with username and password before sending.
+func NewSMTPServer(username, password string) *SMTPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic("sendertest: failed to listen on a port: " + err.Error())
+	}
+	s := &SMTPServer{
+		Addr:     listener.Addr().String(),
+		listener: listener,
+		username: username,
+		password: password,
+		conns:    make(map[net.Conn]struct{}),
+	}
+	s.wg.Go(s.serve)
+	return s
+}
+
+// Close stops the server and closes the open connections
+func (s *SMTPServer) Close() {
+	_ = s.listener.Close()
+	s.mu.Lock()
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// FailWith makes the server answer every RCPT command with code and message,
+// 0 accepts recipients again
+func (s *SMTPServer) FailWith(code int, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failCode = code
+	s.failMessage = message
+}
+
+// Messages returns the accepted messages in the order they were received, the
+// content is the decoded body
+func (s *SMTPServer) Messages() []ReceivedMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]ReceivedMessage(nil), s.messages...)
+}
+
+func (s *SMTPServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		s.wg.Go(func() {
+			defer func() {
+				s.mu.Lock()
+				delete(s.conns, conn)
+				s.mu.Unlock()
+				_ = conn.Close()
+			}()
+			s.converse(textproto.NewConn(conn))
+		})
+	}
+}
+
+func (s *SMTPServer) converse(conn *textproto.Conn) {
+	reply := func(code int, message string) bool {
+		return conn.PrintfLine("%d %s", code, message) == nil
+	}
+	if !reply(220, "sendertest ESMTP") {
+		return
+	}
+	authenticated := s.username == ""
+	var from string
+	var to []string
+	for {
+		line, err := conn.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, argument, _ := strings.Cut(line, " ")
+		ok := true
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			ok = conn.PrintfLine("250-sendertest") == nil && reply(250, "AUTH PLAIN")
+		case "AUTH":
+			mechanism, response, _ := strings.Cut(argument, " ")
+			if strings.ToUpper(mechanism) != "PLAIN" {
+				ok = reply(504, "unrecognized authentication type")
+				break
+			}
+			credentials, err2 := base64.StdEncoding.DecodeString(response)
+			if err2 != nil || string(credentials) != "\x00"+s.username+"\x00"+s.password {
+				ok = reply(535, "authentication credentials invalid")
+				break
+			}
+			authenticated = true
+			ok = reply(235, "authentication successful")
+		case "MAIL":
+			if !authenticated {
+				ok = reply(530, "authentication required")
+				break
+			}
+			from = addressArgument(argument, "FROM:")
+			to = nil
+			ok = reply(250, "ok")
+		case "RCPT":
+			s.mu.Lock()
+			failCode, failMessage := s.failCode, s.failMessage
+			s.mu.Unlock()
+			if from == "" {
+				ok = reply(503, "need MAIL command")
+				break
+			}
+			if failCode != 0 {
+				ok = reply(failCode, failMessage)
+				break
+			}
+			to = append(to, addressArgument(argument, "TO:"))
+			ok = reply(250, "ok")
+		case "DATA":
+			if len(to) == 0 {
+				ok = reply(503, "need RCPT command")
+				break
+			}
+			if !reply(354, "end data with <CR><LF>.<CR><LF>") {
+				return
+			}
+			dotReader := conn.DotReader()
+			message, err2 := readMessage(dotReader)
+			// the rest of an invalid message must not be read as commands
+			_, _ = io.Copy(io.Discard, dotReader)
+			if err2 != nil {
+				ok = reply(554, "invalid message: "+err2.Error())
+				break
+			}
+			message.Sender = from
+			message.To = strings.Join(to, ",")
+			s.mu.Lock()
+			s.messages = append(s.messages, message)
+			s.mu.Unlock()
+			from, to = "", nil
+			ok = reply(250, "queued")
+		case "RSET":
+			from, to = "", nil
+			ok = reply(250, "ok")
+		case "NOOP":
+			ok = reply(250, "ok")
+		case "QUIT":
+			reply(221, "bye")
+			return
+		default:
+			ok = reply(502, "command not implemented")
+		}
+		if !ok {
+			return
+		}
+	}
+}
+
+// addressArgument reads the address of "FROM:<address>" or "TO:<address>"
+func addressArgument(argument, prefix string) string {
+	if len(argument) < len(prefix) || !strings.EqualFold(argument[:len(prefix)], prefix) {
+		return ""
+	}
+	address, _, _ := strings.Cut(argument[len(prefix):], " ")
+	return strings.Trim(address, "<>")
+}
+
+func readMessage(data io.Reader) (ReceivedMessage, error) {
+	message, err := mail.ReadMessage(bufio.NewReader(data))
+	if err != nil {
+		return ReceivedMessage{}, err
+	}
+	body := message.Body
+	if strings.EqualFold(message.Header.Get("Content-Transfer-Encoding"), "quoted-printable") {
+		body = quotedprintable.NewReader(body)
+	}
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return ReceivedMessage{}, err
+	}
+	return ReceivedMessage{
+		Content: strings.TrimRight(string(content), "\r\n"),
+		Header:  http.Header(message.Header),
+	}, nil
+}
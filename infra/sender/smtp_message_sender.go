@@ -0,0 +1,218 @@
+package sender
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"auto-message-sender/internal/models"
+)
+
+var _ messageSender = (*SMTPMessageSender)(nil)
+
+// SMTPProvider is the provider of the messages sent by SMTPMessageSender
+const SMTPProvider = "smtp"
+
+type SMTPConfig struct {
+	// Addr is the host:port of the SMTP server
+	Addr string
+	// Username and Password authenticate with PLAIN auth, no auth if Username is empty
+	Username string
+	Password string
+	// From is the sender address of every email
+	From    string
+	Subject string
+	// TLSConfig is used for STARTTLS when the server supports it, the server name
+	// is the host of Addr if nil
+	TLSConfig *tls.Config
+}
+
+func (c SMTPConfig) Validate() error {
+	_, _, err := net.SplitHostPort(c.Addr)
+	if err != nil {
+		return fmt.Errorf("invalid smtp address %q: %w", c.Addr, err)
+	}
+	address, err := mail.ParseAddress(c.From)
+	if err != nil || address.Address != c.From {
+		return fmt.Errorf("invalid smtp from address %q", c.From)
+	}
+	if strings.ContainsAny(c.Subject, "\r\n") {
+		return errors.New("smtp subject can not contain line breaks")
+	}
+	return nil
+}
+
+// SMTPMessageSender sends email messages to message.Recipient, one SMTP
+// transaction per message
+type SMTPMessageSender struct {
+	config SMTPConfig
+	dialer net.Dialer
+	now    func() time.Time
+}
+
+func NewSMTPMessageSender(config SMTPConfig) (*SMTPMessageSender, error) {
+	err := config.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return &SMTPMessageSender{
+		config: config,
+		dialer: net.Dialer{
+			Timeout: 10 * time.Second,
+		},
+		now: time.Now,
+	}, nil
+}
+
+// SendMessage delivers the message to the SMTP server. The Message-ID header is
+// derived from the idempotency key and returned as the provider message id.
+// 4xx replies and network errors are retryable, 5xx replies are permanent.
+func (s *SMTPMessageSender) SendMessage(ctx context.Context, message models.Message) (models.MessageSenderResponse, error) {
+	messageID := s.messageID(message)
+	data, err := s.buildMessage(message, messageID)
+	if err != nil {
+		// the message can never be built, it is not retried
+		return models.MessageSenderResponse{}, &models.EndpointError{
+			Provider: SMTPProvider,
+			Err:      &models.SendError{Err: fmt.Errorf("buildMessage error: %w", err)},
+		}
+	}
+	err = s.send(ctx, message.Recipient, data)
+	if err != nil {
+		return models.MessageSenderResponse{}, &models.EndpointError{Provider: SMTPProvider, Err: newSMTPError(err)}
+	}
+	return models.MessageSenderResponse{
+		Message:   "Accepted",
+		MessageID: messageID,
+		SentAt:    s.now().UTC(),
+		Provider:  SMTPProvider,
+	}, nil
+}
+
+func (s *SMTPMessageSender) send(ctx context.Context, recipient string, data []byte) error {
+	host, _, _ := net.SplitHostPort(s.config.Addr)
+	conn, err := s.dialer.DialContext(ctx, "tcp", s.config.Addr)
+	if err != nil {
+		return fmt.Errorf("dialer.DialContext error: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	// closing the connection interrupts a conversation that outlives ctx
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.Close()
+	})
+	defer stop()
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return fmt.Errorf("smtp.NewClient error: %w", err)
+	}
+	defer client.Close()
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		tlsConfig := s.config.TLSConfig
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{ServerName: host}
+		}
+		err = client.StartTLS(tlsConfig)
+		if err != nil {
+			return fmt.Errorf("client.StartTLS error: %w", err)
+		}
+	}
+	if s.config.Username != "" {
+		err = client.Auth(smtp.PlainAuth("", s.config.Username, s.config.Password, host))
+		if err != nil {
+			return fmt.Errorf("client.Auth error: %w", err)
+		}
+	}
+	err = client.Mail(s.config.From)
+	if err != nil {
+		return fmt.Errorf("client.Mail error: %w", err)
+	}
+	err = client.Rcpt(recipient)
+	if err != nil {
+		return fmt.Errorf("client.Rcpt error: %w", err)
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("client.Data error: %w", err)
+	}
+	_, err = writer.Write(data)
+	if err != nil {
+		return fmt.Errorf("writer.Write error: %w", err)
+	}
+	err = writer.Close()
+	if err != nil {
+		return fmt.Errorf("writer.Close error: %w", err)
+	}
+	// the message is accepted once DATA is answered, a failed QUIT does not matter
+	_ = client.Quit()
+	return nil
+}
+
+// messageID is the same for every send with the same idempotency key so a
+// receiving server can drop duplicates
+func (s *SMTPMessageSender) messageID(message models.Message) string {
+	domain := "localhost"
+	if at := strings.LastIndexByte(s.config.From, '@'); at >= 0 {
+		domain = s.config.From[at+1:]
+	}
+	return message.IdempotencyKey() + "@" + domain
+}
+
+func (s *SMTPMessageSender) buildMessage(message models.Message, messageID string) ([]byte, error) {
+	if strings.ContainsAny(message.Recipient, "\r\n") {
+		return nil, errors.New("recipient can not contain line breaks")
+	}
+	var data bytes.Buffer
+	header := [][2]string{
+		{"From", s.config.From},
+		{"To", message.Recipient},
+		{"Subject", mime.QEncoding.Encode("utf-8", s.config.Subject)},
+		{"Date", s.now().Format(time.RFC1123Z)},
+		{"Message-ID", "<" + messageID + ">"},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "text/plain; charset=utf-8"},
+		{"Content-Transfer-Encoding", "quoted-printable"},
+	}
+	for _, field := range header {
+		data.WriteString(field[0] + ": " + field[1] + "\r\n")
+	}
+	data.WriteString("\r\n")
+	writer := quotedprintable.NewWriter(&data)
+	_, err := writer.Write([]byte(message.MessageContent))
+	if err != nil {
+		return nil, fmt.Errorf("quotedprintable.Write error: %w", err)
+	}
+	err = writer.Close()
+	if err != nil {
+		return nil, fmt.Errorf("quotedprintable.Close error: %w", err)
+	}
+	data.WriteString("\r\n")
+	return data.Bytes(), nil
+}
+
+// newSMTPError classifies a failed SMTP conversation. Transient 4xx replies and
+// network errors are retryable, a 5xx reply means the server will never accept
+// the message.
+func newSMTPError(err error) *models.SendError {
+	sendError := &models.SendError{
+		Retryable: true,
+		Err:       fmt.Errorf("smtp message sender error: %w", err),
+	}
+	var protocolError *textproto.Error
+	if errors.As(err, &protocolError) {
+		sendError.StatusCode = protocolError.Code
+		sendError.Retryable = protocolError.Code < 500
+	}
+	return sendError
+}
@@ -0,0 +1,139 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"auto-message-sender/infra/sender/sendertest"
+	"auto-message-sender/internal/models"
+)
+
+func TestSMTPMessageSender(t *testing.T) {
+	server := sendertest.NewSMTPServer("user", "secret")
+	defer server.Close()
+
+	smtpMessageSender, err := NewSMTPMessageSender(SMTPConfig{
+		Addr:     server.Addr,
+		Username: "user",
+		Password: "secret",
+		From:     "noreply@example.com",
+		Subject:  "Bildirim",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a line starting with a dot and a line longer than 76 characters must
+	// survive the SMTP encoding
+	content := "Merhaba, siparişiniz yolda.\n.\n" + strings.Repeat("uzun ", 40)
+	response, err := smtpMessageSender.SendMessage(context.Background(), models.Message{
+		MessageID:      "1",
+		Channel:        models.ChannelEmail,
+		Recipient:      "user@example.com",
+		MessageContent: content,
+		ReplayCount:    2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.MessageID != "1-2@example.com" || response.SentAt.IsZero() {
+		t.Fatalf("unexpected response %+v", response)
+	}
+	messages := server.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(messages))
+	}
+	message := messages[0]
+	if message.Sender != "noreply@example.com" || message.To != "user@example.com" {
+		t.Fatalf("unexpected envelope %q -> %q", message.Sender, message.To)
+	}
+	if message.Content != content {
+		t.Fatalf("unexpected content %q", message.Content)
+	}
+	if got := message.Header.Get("Message-Id"); got != "<1-2@example.com>" {
+		t.Fatalf("unexpected Message-ID %q", got)
+	}
+}
+
+func TestSMTPMessageSenderErrors(t *testing.T) {
+	tests := []struct {
+		name           string
+		password       string
+		failCode       int
+		wantStatusCode int
+		wantRetryable  bool
+	}{
+		{name: "mailbox busy", password: "secret", failCode: 450, wantStatusCode: 450, wantRetryable: true},
+		{name: "mailbox unavailable", password: "secret", failCode: 550, wantStatusCode: 550},
+		{name: "invalid credentials", password: "wrong", wantStatusCode: 535},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := sendertest.NewSMTPServer("user", "secret")
+			defer server.Close()
+			if tt.failCode != 0 {
+				server.FailWith(tt.failCode, "mailbox error")
+			}
+
+			smtpMessageSender, err := NewSMTPMessageSender(SMTPConfig{
+				Addr:     server.Addr,
+				Username: "user",
+				Password: tt.password,
+				From:     "noreply@example.com",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = smtpMessageSender.SendMessage(context.Background(), models.Message{
+				MessageID:      "1",
+				Channel:        models.ChannelEmail,
+				Recipient:      "user@example.com",
+				MessageContent: "hello",
+			})
+			var sendError *models.SendError
+			if !errors.As(err, &sendError) {
+				t.Fatalf("expected SendError, got %v", err)
+			}
+			if sendError.StatusCode != tt.wantStatusCode || sendError.Retryable != tt.wantRetryable {
+				t.Fatalf("unexpected send error %+v", sendError)
+			}
+			if len(server.Messages()) != 0 {
+				t.Fatal("expected no accepted message")
+			}
+		})
+	}
+
+	t.Run("connection refused", func(t *testing.T) {
+		server := sendertest.NewSMTPServer("", "")
+		addr := server.Addr
+		server.Close()
+
+		smtpMessageSender, err := NewSMTPMessageSender(SMTPConfig{Addr: addr, From: "noreply@example.com"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = smtpMessageSender.SendMessage(context.Background(), models.Message{MessageID: "1", Recipient: "user@example.com", MessageContent: "hello"})
+		var sendError *models.SendError
+		if !errors.As(err, &sendError) || !sendError.Retryable {
+			t.Fatalf("expected retryable SendError, got %v", err)
+		}
+	})
+	t.Run("invalid recipient", func(t *testing.T) {
+		server := sendertest.NewSMTPServer("", "")
+		defer server.Close()
+
+		smtpMessageSender, err := NewSMTPMessageSender(SMTPConfig{Addr: server.Addr, From: "noreply@example.com"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = smtpMessageSender.SendMessage(context.Background(), models.Message{MessageID: "1", Recipient: "user@example.com\r\nBcc: other@example.com", MessageContent: "hello"})
+		var sendError *models.SendError
+		if !errors.As(err, &sendError) || sendError.Retryable {
+			t.Fatalf("expected permanent SendError, got %v", err)
+		}
+		if len(server.Messages()) != 0 {
+			t.Fatal("expected no accepted message")
+		}
+	})
+}
@@ -3,9 +3,14 @@ package sender
 import (
 	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"auto-message-sender/internal/models"
@@ -17,61 +22,158 @@ type messageSender interface {
 
 var _ messageSender = (*WebhookMessageSender)(nil)
 
+// IdempotencyKeyHeader carries models.Message.IdempotencyKey, the provider must not
+// deliver two requests with the same key
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// maxErrorBodySize is how much of an error response body is kept in a SendError
+const maxErrorBodySize = 512
+
 type WebhookMessageSender struct {
 	client         *http.Client
 	webhookSiteURL string
+	// successStatusCodes are the accepted response codes, any 2xx code if empty
+	successStatusCodes []int
+	authenticators     []RequestAuthenticator
+	adapter            ProviderAdapter
 }
 
-func NewWebhookMessageSender(webhookSiteURL string) *WebhookMessageSender {
-	return &WebhookMessageSender{
-		client: &http.Client{
-			Timeout: 10 * time.Second,
-		},
-		webhookSiteURL: webhookSiteURL,
+type WebhookMessageSenderOption func(s *WebhookMessageSender)
+
+// WithSuccessStatusCodes sets the response codes that mean the message was
+// accepted, by default any 2xx code is accepted
+func WithSuccessStatusCodes(statusCodes ...int) WebhookMessageSenderOption {
+	return func(s *WebhookMessageSender) {
+		s.successStatusCodes = statusCodes
 	}
 }
 
-type webhookMessage struct {
-	To      string `json:"to"`
-	Content string `json:"content"`
+// WithAuthenticators adds authentication to every request, authenticators are
+// applied in order
+func WithAuthenticators(authenticators ...RequestAuthenticator) WebhookMessageSenderOption {
+	return func(s *WebhookMessageSender) {
+		s.authenticators = append(s.authenticators, authenticators...)
+	}
 }
 
-type webhookMessageResponse struct {
-	Message   string `json:"message"`
-	MessageID string `json:"messageId"`
+// WithProviderAdapter sets the request and response format of the provider, the
+// generic webhook format is used by default
+func WithProviderAdapter(adapter ProviderAdapter) WebhookMessageSenderOption {
+	return func(s *WebhookMessageSender) {
+		s.adapter = adapter
+	}
 }
 
-func (s *WebhookMessageSender) SendMessage(ctx context.Context, message models.Message) (models.MessageSenderResponse, error) {
-	newWebhookMessage := webhookMessage{
-		To:      message.PhoneNumber,
-		Content: message.MessageContent,
+// RequestTimeout is the timeout of a single webhook request
+const RequestTimeout = 10 * time.Second
+
+func NewWebhookMessageSender(webhookSiteURL string, options ...WebhookMessageSenderOption) *WebhookMessageSender {
+	s := &WebhookMessageSender{
+		client: &http.Client{
+			Timeout: RequestTimeout,
+		},
+		webhookSiteURL: webhookSiteURL,
+		adapter:        webhookAdapter{},
+	}
+	for _, option := range options {
+		option(s)
 	}
-	body := bytes.Buffer{}
-	err := json.NewEncoder(&body).Encode(newWebhookMessage)
+	return s
+}
+
+func (s *WebhookMessageSender) SendMessage(ctx context.Context, message models.Message) (models.MessageSenderResponse, error) {
+	body, contentType, err := s.adapter.EncodeRequest(message)
 	if err != nil {
-		return models.MessageSenderResponse{}, fmt.Errorf("json.NewEncoder error: %w", err)
+		return models.MessageSenderResponse{}, fmt.Errorf("adapter.EncodeRequest error: %w", err)
 	}
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.webhookSiteURL, &body)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.webhookSiteURL, bytes.NewReader(body))
 	if err != nil {
 		return models.MessageSenderResponse{}, fmt.Errorf("http.NewRequestWithContext error: %w", err)
 	}
-	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set(IdempotencyKeyHeader, message.IdempotencyKey())
+	if preparer, ok := s.adapter.(requestPreparer); ok {
+		preparer.PrepareRequest(req)
+	}
+	for _, authenticator := range s.authenticators {
+		err = authenticator.Authenticate(req, body)
+		if err != nil {
+			return models.MessageSenderResponse{}, fmt.Errorf("authenticator.Authenticate error: %w", err)
+		}
+	}
 	resp, err := s.client.Do(req)
 	if err != nil {
-		return models.MessageSenderResponse{}, fmt.Errorf("s.client.Do error: %w", err)
+		return models.MessageSenderResponse{}, newRequestError(err)
 	}
 	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusAccepted {
-		return models.MessageSenderResponse{}, fmt.Errorf("webhook message sender unexpected response code error: %d", resp.StatusCode)
+	if !s.isSuccess(resp.StatusCode) {
+		return models.MessageSenderResponse{}, newResponseError(resp)
+	}
+	sendMessageResponse, err := s.adapter.DecodeResponse(resp.Body)
+	if err != nil {
+		// the provider accepted the message, sending it again would duplicate it
+		return models.MessageSenderResponse{}, &models.SendError{
+			StatusCode: resp.StatusCode,
+			Err:        fmt.Errorf("adapter.DecodeResponse error: %w", err),
+		}
+	}
+	sendMessageResponse.SentAt = time.Now().UTC()
+	sendMessageResponse.StatusCode = resp.StatusCode
+	return sendMessageResponse, nil
+}
+
+func (s *WebhookMessageSender) isSuccess(statusCode int) bool {
+	if len(s.successStatusCodes) == 0 {
+		return statusCode >= 200 && statusCode < 300
+	}
+	return slices.Contains(s.successStatusCodes, statusCode)
+}
+
+// newRequestError classifies a request that got no response, only a refused
+// connection is unprocessed
+func newRequestError(err error) *models.SendError {
+	var opError *net.OpError
+	return &models.SendError{
+		Retryable:   true,
+		Unprocessed: errors.As(err, &opError) && opError.Op == "dial",
+		Err:         fmt.Errorf("s.client.Do error: %w", err),
+	}
+}
+
+// newResponseError classifies an unexpected response. Server errors, 408 and
+// 429 are retryable, other codes mean the request will never be accepted.
+func newResponseError(resp *http.Response) *models.SendError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	sendError := &models.SendError{
+		StatusCode: resp.StatusCode,
+		Body:       strings.TrimSpace(strings.ToValidUTF8(string(body), "")),
+		Retryable: resp.StatusCode >= 500 ||
+			resp.StatusCode == http.StatusRequestTimeout ||
+			resp.StatusCode == http.StatusTooManyRequests,
+		// a gateway timeout may hide a request the provider accepted
+		Unprocessed: (resp.StatusCode >= 500 && resp.StatusCode != http.StatusGatewayTimeout) ||
+			resp.StatusCode == http.StatusTooManyRequests,
+		Err: fmt.Errorf("webhook message sender unexpected response code error: %d", resp.StatusCode),
+	}
+	if sendError.Retryable {
+		sendError.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	}
+	return sendError
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date,
+// 0 is returned if the header is missing or invalid
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	seconds, err := strconv.Atoi(value)
+	if err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second
 	}
-	var webhookMessageResponseData webhookMessageResponse
-	err = json.NewDecoder(resp.Body).Decode(&webhookMessageResponseData)
+	date, err := http.ParseTime(value)
 	if err != nil {
-		return models.MessageSenderResponse{}, fmt.Errorf("json.NewDecoder error: %w", err)
+		return 0
 	}
-	return models.MessageSenderResponse{
-		Message:   webhookMessageResponseData.Message,
-		MessageID: webhookMessageResponseData.MessageID,
-		SentAt:    time.Now().UTC(),
-	}, nil
+	return max(date.Sub(now), 0)
 }
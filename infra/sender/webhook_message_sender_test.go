@@ -2,6 +2,15 @@ package sender
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -23,3 +32,186 @@ func TestWebhookMessageSender(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestWebhookMessageSenderResponseCodes(t *testing.T) {
+	tests := []struct {
+		name               string
+		statusCode         int
+		header             http.Header
+		body               string
+		successStatusCodes []int
+		wantErr            bool
+		wantRetryable      bool
+		wantUnprocessed    bool
+		wantRetryAfter     time.Duration
+	}{
+		{name: "200 accepted", statusCode: http.StatusOK, body: `{"message":"Accepted","messageId":"1"}`},
+		{name: "201 accepted", statusCode: http.StatusCreated, body: `{"message":"Accepted","messageId":"1"}`},
+		{name: "200 invalid body", statusCode: http.StatusOK, body: "accepted", wantErr: true},
+		{name: "200 not configured", statusCode: http.StatusOK, body: `{}`, successStatusCodes: []int{http.StatusAccepted}, wantErr: true},
+		{name: "400 permanent", statusCode: http.StatusBadRequest, body: `{"error":"invalid phone number"}`, wantErr: true},
+		{name: "500 retryable", statusCode: http.StatusInternalServerError, body: "internal error", wantErr: true, wantRetryable: true, wantUnprocessed: true},
+		{name: "429 retry after", statusCode: http.StatusTooManyRequests, header: http.Header{"Retry-After": {"120"}}, wantErr: true, wantRetryable: true, wantUnprocessed: true, wantRetryAfter: 2 * time.Minute},
+		{name: "408 may be processed", statusCode: http.StatusRequestTimeout, wantErr: true, wantRetryable: true},
+		{name: "504 may be processed", statusCode: http.StatusGatewayTimeout, wantErr: true, wantRetryable: true},
+		{name: "long body", statusCode: http.StatusServiceUnavailable, body: strings.Repeat("x", 4096), wantErr: true, wantRetryable: true, wantUnprocessed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				for key, values := range tt.header {
+					w.Header()[key] = values
+				}
+				w.WriteHeader(tt.statusCode)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			webhookMessageSender := NewWebhookMessageSender(server.URL, WithSuccessStatusCodes(tt.successStatusCodes...))
+			_, err := webhookMessageSender.SendMessage(context.Background(), models.Message{MessageID: "1"})
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			var sendError *models.SendError
+			if !errors.As(err, &sendError) {
+				t.Fatalf("expected SendError, got %v", err)
+			}
+			if sendError.StatusCode != tt.statusCode || sendError.Retryable != tt.wantRetryable || sendError.Unprocessed != tt.wantUnprocessed || sendError.RetryAfter != tt.wantRetryAfter {
+				t.Fatalf("unexpected send error %+v", sendError)
+			}
+			if len(sendError.Body) > maxErrorBodySize || !strings.HasPrefix(tt.body, sendError.Body) {
+				t.Fatalf("unexpected body %q", sendError.Body)
+			}
+		})
+	}
+}
+
+func TestWebhookMessageSenderNetworkError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	_, err := NewWebhookMessageSender(server.URL).SendMessage(context.Background(), models.Message{MessageID: "1"})
+	var sendError *models.SendError
+	if !errors.As(err, &sendError) || !sendError.Retryable || !sendError.Unprocessed || sendError.StatusCode != 0 {
+		t.Fatalf("expected retryable unprocessed SendError, got %v", err)
+	}
+
+	// the provider may have accepted a request that timed out
+	release := make(chan struct{})
+	server = httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = NewWebhookMessageSender(server.URL).SendMessage(ctx, models.Message{MessageID: "1"})
+	if !errors.As(err, &sendError) || !sendError.Retryable || sendError.Unprocessed {
+		t.Fatalf("expected retryable SendError that may be processed, got %v", err)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 11, 13, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{value: "", want: 0},
+		{value: "30", want: 30 * time.Second},
+		{value: "-5", want: 0},
+		{value: "Thu, 13 Nov 2025 10:01:00 GMT", want: time.Minute},
+		{value: "Thu, 13 Nov 2025 09:00:00 GMT", want: 0},
+		{value: "soon", want: 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestWebhookMessageSenderAuthentication(t *testing.T) {
+	now := time.Date(2025, 11, 13, 10, 0, 0, 0, time.UTC)
+	secret := []byte("shared-secret")
+	var request *http.Request
+	var requestBody []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request = r
+		requestBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte(`{"message":"Accepted","messageId":"1"}`))
+	}))
+	defer server.Close()
+
+	hmacAuthenticator := NewHMACAuthenticator(secret)
+	hmacAuthenticator.now = func() time.Time { return now }
+	webhookMessageSender := NewWebhookMessageSender(server.URL, WithAuthenticators(
+		BearerTokenAuthenticator{Token: "token"},
+		HeaderAuthenticator{Headers: map[string]string{"X-Api-Key": "key"}},
+		hmacAuthenticator,
+	))
+	_, err := webhookMessageSender.SendMessage(context.Background(), models.Message{MessageID: "1", PhoneNumber: "+905551234567", MessageContent: "hello", AttemptCount: 3, ReplayCount: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := request.Header.Get(IdempotencyKeyHeader); got != "1-2" {
+		t.Fatalf("unexpected Idempotency-Key header %q", got)
+	}
+	if got := request.Header.Get("Authorization"); got != "Bearer token" {
+		t.Fatalf("unexpected Authorization header %q", got)
+	}
+	if got := request.Header.Get("X-Api-Key"); got != "key" {
+		t.Fatalf("unexpected X-Api-Key header %q", got)
+	}
+	timestamp := request.Header.Get(DefaultSignatureTimestampHeader)
+	if timestamp != "1763028000" {
+		t.Fatalf("unexpected timestamp %q", timestamp)
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp + "." + string(requestBody)))
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if got := request.Header.Get(DefaultSignatureHeader); got != want {
+		t.Fatalf("unexpected signature %q, want %q", got, want)
+	}
+}
+
+func TestHMACVerifier(t *testing.T) {
+	now := time.Date(2025, 11, 13, 10, 0, 0, 0, time.UTC)
+	secret := []byte("shared-secret")
+	body := []byte(`{"messageId":"provider-1","status":"delivered","timestamp":"2025-11-13T09:59:58Z"}`)
+	verifier := NewHMACVerifier(secret, 5*time.Minute)
+	verifier.now = func() time.Time { return now }
+	signedHeader := func(timestamp time.Time, secret []byte, body []byte) http.Header {
+		unix := strconv.FormatInt(timestamp.Unix(), 10)
+		return http.Header{
+			DefaultSignatureTimestampHeader: {unix},
+			DefaultSignatureHeader:          {Signature(secret, unix, body)},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		header  http.Header
+		wantErr bool
+	}{
+		{name: "valid", header: signedHeader(now.Add(-time.Minute), secret, body)},
+		{name: "other secret", header: signedHeader(now, []byte("other-secret"), body), wantErr: true},
+		{name: "other body", header: signedHeader(now, secret, []byte(`{}`)), wantErr: true},
+		{name: "replayed", header: signedHeader(now.Add(-10*time.Minute), secret, body), wantErr: true},
+		{name: "missing headers", header: http.Header{}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifier.Verify(tt.header, body)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if err != nil && !errors.Is(err, models.ErrInvalidSignature) {
+				t.Fatalf("expected ErrInvalidSignature, got %v", err)
+			}
+		})
+	}
+}
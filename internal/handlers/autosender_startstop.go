@@ -1,13 +1,15 @@
 package handlers
 
 import (
-	"fmt"
+	"errors"
 	"net/http"
+
+	"auto-message-sender/internal/models"
 )
 
 type autoSenderStartStopService interface {
-	Start()
-	Stop()
+	Start() (models.SenderStatus, error)
+	Stop() (models.SenderStatus, error)
 }
 
 type AutoSenderStartStopHandler struct {
@@ -20,14 +22,30 @@ func NewAutoSenderStartStopHandler(autoMessageSender autoSenderStartStopService)
 	}
 }
 
+// Start handles POST /start
 func (h *AutoSenderStartStopHandler) Start(w http.ResponseWriter, _ *http.Request) {
-	h.autoMessageSender.Start()
-	w.WriteHeader(http.StatusOK)
-	_, _ = fmt.Fprint(w, "OK")
+	status, err := h.autoMessageSender.Start()
+	if err != nil {
+		if errors.Is(err, models.ErrSenderStopped) {
+			writeJSON(w, http.StatusConflict, status)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
 }
 
+// Stop handles POST /stop, 202 Accepted is returned while the current batch is drained
 func (h *AutoSenderStartStopHandler) Stop(w http.ResponseWriter, _ *http.Request) {
-	h.autoMessageSender.Stop()
-	w.WriteHeader(http.StatusOK)
-	_, _ = fmt.Fprint(w, "OK")
+	status, err := h.autoMessageSender.Stop()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if status.State == models.SenderStateDraining {
+		writeJSON(w, http.StatusAccepted, status)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
 }
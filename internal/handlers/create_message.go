@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"auto-message-sender/internal/models"
+)
+
+// maxCreateMessageBodySize limits the request body, an email can not be longer
+// than 10000 characters which is at most 40KB in UTF-8
+const maxCreateMessageBodySize = 64 << 10
+
+type createMessageService interface {
+	CreateMessage(ctx context.Context, message models.Message) (string, error)
+}
+
+type CreateMessageHandler struct {
+	createMessageService createMessageService
+}
+
+func NewCreateMessageHandler(createMessageService createMessageService) *CreateMessageHandler {
+	return &CreateMessageHandler{
+		createMessageService: createMessageService,
+	}
+}
+
+type createMessageRequest struct {
+	// Channel is sms, email or chat, sms if empty
+	Channel        string `json:"channel"`
+	PhoneNumber    string `json:"phone_number"`
+	Recipient      string `json:"recipient"`
+	MessageContent string `json:"message_content"`
+	// SendAt optionally schedules the message, RFC3339 time
+	SendAt *time.Time `json:"send_at"`
+}
+
+type createMessageResponse struct {
+	MessageID string `json:"message_id"`
+}
+
+func (h *CreateMessageHandler) CreateMessage(w http.ResponseWriter, r *http.Request) {
+	var request createMessageRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxCreateMessageBodySize))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&request)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	messageID, err := h.createMessageService.CreateMessage(r.Context(), models.Message{
+		Channel:        request.Channel,
+		PhoneNumber:    request.PhoneNumber,
+		Recipient:      request.Recipient,
+		MessageContent: request.MessageContent,
+		SendAt:         request.SendAt,
+	})
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidChannel) ||
+			errors.Is(err, models.ErrInvalidPhoneNumber) ||
+			errors.Is(err, models.ErrInvalidRecipient) ||
+			errors.Is(err, models.ErrInvalidMessageContent) {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, createMessageResponse{
+		MessageID: messageID,
+	})
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"auto-message-sender/internal/models"
+)
+
+// maxDeliveryReportBodySize limits the request body of a delivery report
+const maxDeliveryReportBodySize = 64 << 10
+
+type deliveryReportService interface {
+	RecordDeliveryReport(ctx context.Context, report models.DeliveryReport) (string, error)
+}
+
+// signatureVerifier authenticates the caller of the callback by the signature
+// of the request body
+type signatureVerifier interface {
+	Verify(header http.Header, body []byte) error
+}
+
+type DeliveryReportsHandler struct {
+	deliveryReportService deliveryReportService
+	signatureVerifier     signatureVerifier
+}
+
+func NewDeliveryReportsHandler(deliveryReportService deliveryReportService, signatureVerifier signatureVerifier) *DeliveryReportsHandler {
+	return &DeliveryReportsHandler{
+		deliveryReportService: deliveryReportService,
+		signatureVerifier:     signatureVerifier,
+	}
+}
+
+type deliveryReportResponse struct {
+	MessageID string `json:"message_id"`
+}
+
+// RecordDeliveryReport handles POST /callbacks/delivery-reports
+func (h *DeliveryReportsHandler) RecordDeliveryReport(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxDeliveryReportBodySize))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	err = h.signatureVerifier.Verify(r.Header, body)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+	var report models.DeliveryReport
+	err = json.Unmarshal(body, &report)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	messageID, err := h.deliveryReportService.RecordDeliveryReport(r.Context(), report)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrInvalidDeliveryReport):
+			writeError(w, http.StatusBadRequest, err)
+		case errors.Is(err, models.ErrMessageNotFound):
+			writeError(w, http.StatusNotFound, err)
+		default:
+			writeError(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, deliveryReportResponse{
+		MessageID: messageID,
+	})
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"auto-message-sender/internal/models"
+)
+
+type failedMessagesService interface {
+	ListFailedMessages(ctx context.Context, filter models.FailedMessageFilter) ([]models.Message, error)
+	RetryFailedMessage(ctx context.Context, messageID string) error
+	RetryFailedMessages(ctx context.Context, filter models.FailedMessageFilter) (int64, error)
+}
+
+type FailedMessagesHandler struct {
+	failedMessagesService failedMessagesService
+}
+
+func NewFailedMessagesHandler(failedMessagesService failedMessagesService) *FailedMessagesHandler {
+	return &FailedMessagesHandler{
+		failedMessagesService: failedMessagesService,
+	}
+}
+
+type retryFailedMessagesResponse struct {
+	Retried int64 `json:"retried"`
+}
+
+// ListFailedMessages handles GET /messages/failed
+func (h *FailedMessagesHandler) ListFailedMessages(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseFailedMessageFilter(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	messages, err := h.failedMessagesService.ListFailedMessages(r.Context(), filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, messages)
+}
+
+// RetryFailedMessage handles POST /messages/{id}/retry
+func (h *FailedMessagesHandler) RetryFailedMessage(w http.ResponseWriter, r *http.Request) {
+	err := h.failedMessagesService.RetryFailedMessage(r.Context(), r.PathValue("id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrMessageNotFound):
+			writeError(w, http.StatusNotFound, err)
+		case errors.Is(err, models.ErrMessageNotFailed):
+			writeError(w, http.StatusConflict, err)
+		default:
+			writeError(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, retryFailedMessagesResponse{
+		Retried: 1,
+	})
+}
+
+// RetryFailedMessages handles POST /messages/failed/retry, it accepts the same
+// filters as ListFailedMessages except limit and offset
+func (h *FailedMessagesHandler) RetryFailedMessages(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseFailedMessageFilter(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	count, err := h.failedMessagesService.RetryFailedMessages(r.Context(), filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, retryFailedMessagesResponse{
+		Retried: count,
+	})
+}
+
+func parseFailedMessageFilter(query url.Values) (models.FailedMessageFilter, error) {
+	var err error
+	filter := models.FailedMessageFilter{
+		PhoneNumber:   query.Get("phone_number"),
+		ErrorContains: query.Get("error_contains"),
+	}
+	filter.Since, err = parseTimeQuery(query, "since")
+	if err != nil {
+		return models.FailedMessageFilter{}, err
+	}
+	filter.Until, err = parseTimeQuery(query, "until")
+	if err != nil {
+		return models.FailedMessageFilter{}, err
+	}
+	filter.Limit, err = parseIntQuery(query, "limit")
+	if err != nil {
+		return models.FailedMessageFilter{}, err
+	}
+	filter.Offset, err = parseIntQuery(query, "offset")
+	if err != nil {
+		return models.FailedMessageFilter{}, err
+	}
+	return filter, nil
+}
+
+func parseTimeQuery(query url.Values, name string) (time.Time, error) {
+	value := query.Get(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s query parameter, RFC3339 time expected: %w", name, err)
+	}
+	return t, nil
+}
+
+func parseIntQuery(query url.Values, name string) (int, error) {
+	value := query.Get(name)
+	if value == "" {
+		return 0, nil
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid %s query parameter, non-negative integer expected: %q", name, value)
+	}
+	return number, nil
+}
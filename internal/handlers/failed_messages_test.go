@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"auto-message-sender/internal/models"
+)
+
+type fakeFailedMessagesService struct {
+	retryErrs map[string]error
+	filter    models.FailedMessageFilter
+	err       error
+}
+
+func (s *fakeFailedMessagesService) ListFailedMessages(_ context.Context, filter models.FailedMessageFilter) ([]models.Message, error) {
+	s.filter = filter
+	if s.err != nil {
+		return nil, s.err
+	}
+	return []models.Message{{MessageID: "3f846a61-2e99-42f9-a9ab-1e6cf1703476", SendingStatus: "failed"}}, nil
+}
+
+func (s *fakeFailedMessagesService) RetryFailedMessage(_ context.Context, messageID string) error {
+	return s.retryErrs[messageID]
+}
+
+func (s *fakeFailedMessagesService) RetryFailedMessages(_ context.Context, filter models.FailedMessageFilter) (int64, error) {
+	s.filter = filter
+	if s.err != nil {
+		return 0, s.err
+	}
+	return 2, nil
+}
+
+func newFailedMessagesMux(service failedMessagesService) *http.ServeMux {
+	handler := NewFailedMessagesHandler(service)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /messages/failed", handler.ListFailedMessages)
+	mux.HandleFunc("POST /messages/failed/retry", handler.RetryFailedMessages)
+	mux.HandleFunc("POST /messages/{id}/retry", handler.RetryFailedMessage)
+	return mux
+}
+
+func TestFailedMessagesHandlerList(t *testing.T) {
+	service := &fakeFailedMessagesService{}
+	mux := newFailedMessagesMux(service)
+
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/messages/failed?phone_number=%2B905558889922&error_contains=timeout&since=2025-11-12T00:00:00Z&limit=10&offset=20", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", recorder.Code, recorder.Body)
+	}
+	want := models.FailedMessageFilter{
+		PhoneNumber:   "+905558889922",
+		ErrorContains: "timeout",
+		Since:         time.Date(2025, 11, 12, 0, 0, 0, 0, time.UTC),
+		Limit:         10,
+		Offset:        20,
+	}
+	if !service.filter.Since.Equal(want.Since) {
+		t.Fatalf("expected since %s, got %s", want.Since, service.filter.Since)
+	}
+	service.filter.Since = want.Since
+	if service.filter != want {
+		t.Fatalf("expected filter %+v, got %+v", want, service.filter)
+	}
+	var messages []models.Message
+	err := json.NewDecoder(recorder.Body).Decode(&messages)
+	if err != nil || len(messages) != 1 || messages[0].SendingStatus != "failed" {
+		t.Fatalf("unexpected response %+v, %v", messages, err)
+	}
+
+	for _, query := range []string{"since=yesterday", "limit=-1", "offset=x"} {
+		recorder = httptest.NewRecorder()
+		mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/messages/failed?"+query, nil))
+		if recorder.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", query, recorder.Code)
+		}
+	}
+
+	service.err = errors.New("database unavailable")
+	recorder = httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/messages/failed", nil))
+	if recorder.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", recorder.Code)
+	}
+}
+
+func TestFailedMessagesHandlerRetry(t *testing.T) {
+	service := &fakeFailedMessagesService{
+		retryErrs: map[string]error{
+			"unknown": models.ErrMessageNotFound,
+			"sent":    models.ErrMessageNotFailed,
+			"broken":  errors.New("database unavailable"),
+		},
+	}
+	mux := newFailedMessagesMux(service)
+	tests := []struct {
+		path        string
+		wantCode    int
+		wantRetried int64
+	}{
+		{path: "/messages/3f846a61-2e99-42f9-a9ab-1e6cf1703476/retry", wantCode: http.StatusOK, wantRetried: 1},
+		{path: "/messages/unknown/retry", wantCode: http.StatusNotFound},
+		{path: "/messages/sent/retry", wantCode: http.StatusConflict},
+		{path: "/messages/broken/retry", wantCode: http.StatusInternalServerError},
+		{path: "/messages/failed/retry?error_contains=timeout", wantCode: http.StatusOK, wantRetried: 2},
+		{path: "/messages/failed/retry?until=tomorrow", wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, tt.path, nil))
+		if recorder.Code != tt.wantCode {
+			t.Fatalf("%s: expected %d, got %d: %s", tt.path, tt.wantCode, recorder.Code, recorder.Body)
+		}
+		if tt.wantCode != http.StatusOK {
+			continue
+		}
+		var response retryFailedMessagesResponse
+		err := json.NewDecoder(recorder.Body).Decode(&response)
+		if err != nil || response.Retried != tt.wantRetried {
+			t.Fatalf("%s: expected %d retried, got %+v, %v", tt.path, tt.wantRetried, response, err)
+		}
+	}
+	if service.filter.ErrorContains != "timeout" {
+		t.Fatalf("expected the filter to be passed on, got %+v", service.filter)
+	}
+}
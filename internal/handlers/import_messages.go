@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	"auto-message-sender/internal/models"
+)
+
+const (
+	maxImportMessagesBodySize = 32 << 20
+	// importMessagesReadTimeout overrides the server wide read timeout, large
+	// uploads can not be read in a few seconds
+	importMessagesReadTimeout = 2 * time.Minute
+)
+
+type importMessagesService interface {
+	ImportMessages(ctx context.Context, format string, body io.Reader) (models.ImportReport, error)
+}
+
+type ImportMessagesHandler struct {
+	importMessagesService importMessagesService
+}
+
+func NewImportMessagesHandler(importMessagesService importMessagesService) *ImportMessagesHandler {
+	return &ImportMessagesHandler{
+		importMessagesService: importMessagesService,
+	}
+}
+
+func (h *ImportMessagesHandler) ImportMessages(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		writeError(w, http.StatusUnsupportedMediaType, fmt.Errorf("invalid content type: %w", err))
+		return
+	}
+	var format string
+	switch mediaType {
+	case "text/csv":
+		format = models.ImportFormatCSV
+	case "application/x-ndjson", "application/ndjson", "application/jsonl":
+		format = models.ImportFormatNDJSON
+	default:
+		writeError(w, http.StatusUnsupportedMediaType, fmt.Errorf("unsupported content type %q, use text/csv or application/x-ndjson", mediaType))
+		return
+	}
+	_ = http.NewResponseController(w).SetReadDeadline(time.Now().Add(importMessagesReadTimeout))
+	body := http.MaxBytesReader(w, r.Body, maxImportMessagesBodySize)
+	report, err := h.importMessagesService.ImportMessages(r.Context(), format, body)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesErr):
+			writeError(w, http.StatusRequestEntityTooLarge, err)
+		case errors.Is(err, models.ErrMalformedImport):
+			writeError(w, http.StatusBadRequest, err)
+		default:
+			writeError(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
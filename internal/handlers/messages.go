@@ -2,35 +2,90 @@ package handlers
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"net/http"
+	"net/url"
 
 	"auto-message-sender/internal/models"
 )
 
 type retrieveSentMessagesService interface {
-	RetrieveSentMessages(ctx context.Context) ([]models.MessageSenderResponse, error)
+	RetrieveSentMessages(ctx context.Context, filter models.SentMessageFilter) (models.SentMessagePage, error)
 }
+
+// NextCursorHeader carries the cursor of the next page of GET /messages, it is
+// not set on the last page
+const NextCursorHeader = "X-Next-Cursor"
+
+type messageLookupService interface {
+	GetMessage(ctx context.Context, messageID string) (models.MessageDetails, error)
+}
+
 type MessagesHandler struct {
 	retrieveSentMessagesService retrieveSentMessagesService
+	messageLookupService        messageLookupService
 }
 
-func NewMessagesHandler(retrieveSentMessagesService retrieveSentMessagesService) *MessagesHandler {
+func NewMessagesHandler(retrieveSentMessagesService retrieveSentMessagesService, messageLookupService messageLookupService) *MessagesHandler {
 	return &MessagesHandler{
 		retrieveSentMessagesService: retrieveSentMessagesService,
+		messageLookupService:        messageLookupService,
 	}
 }
 
+// RetrieveSentMessagesHandler handles GET /messages, the sent messages are
+// returned newest first a page at a time
 func (h *MessagesHandler) RetrieveSentMessagesHandler(w http.ResponseWriter, r *http.Request) {
-	messages, err := h.retrieveSentMessagesService.RetrieveSentMessages(r.Context())
+	filter, err := parseSentMessageFilter(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	page, err := h.retrieveSentMessagesService.RetrieveSentMessages(r.Context(), filter)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCursor) {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if page.NextCursor != "" {
+		w.Header().Set(NextCursorHeader, page.NextCursor)
+	}
+	writeJSON(w, http.StatusOK, page.Messages)
+}
+
+// GetMessage handles GET /messages/{id}
+func (h *MessagesHandler) GetMessage(w http.ResponseWriter, r *http.Request) {
+	details, err := h.messageLookupService.GetMessage(r.Context(), r.PathValue("id"))
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		_ = json.NewEncoder(w).Encode(map[string]interface{}{
-			"error": err.Error(),
-		})
+		if errors.Is(err, models.ErrMessageNotFound) {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	_ = json.NewEncoder(w).Encode(messages)
+	writeJSON(w, http.StatusOK, details)
+}
+
+func parseSentMessageFilter(query url.Values) (models.SentMessageFilter, error) {
+	var err error
+	filter := models.SentMessageFilter{
+		Cursor: query.Get("cursor"),
+	}
+	filter.Since, err = parseTimeQuery(query, "since")
+	if err != nil {
+		return models.SentMessageFilter{}, err
+	}
+	filter.Until, err = parseTimeQuery(query, "until")
+	if err != nil {
+		return models.SentMessageFilter{}, err
+	}
+	filter.Limit, err = parseIntQuery(query, "limit")
+	if err != nil {
+		return models.SentMessageFilter{}, err
+	}
+	return filter, nil
 }
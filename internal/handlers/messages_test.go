@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"auto-message-sender/internal/models"
+)
+
+type fakeMessageLookupService struct {
+	messages map[string]models.MessageDetails
+	err      error
+}
+
+func (s *fakeMessageLookupService) GetMessage(_ context.Context, messageID string) (models.MessageDetails, error) {
+	if s.err != nil {
+		return models.MessageDetails{}, s.err
+	}
+	if !models.ValidMessageID(messageID) {
+		return models.MessageDetails{}, models.ErrMessageNotFound
+	}
+	details, ok := s.messages[messageID]
+	if !ok {
+		return models.MessageDetails{}, fmt.Errorf("messageRepository.GetMessage error: %w", models.ErrMessageNotFound)
+	}
+	return details, nil
+}
+
+func TestMessagesHandlerGetMessage(t *testing.T) {
+	const messageID = "3f846a61-2e99-42f9-a9ab-1e6cf1703476"
+	service := &fakeMessageLookupService{messages: map[string]models.MessageDetails{
+		messageID: {
+			Message:  models.Message{MessageID: messageID, SendingStatus: "sent"},
+			Attempts: []models.MessageAttempt{{MessageID: messageID, AttemptNumber: 1, StatusCode: 202}},
+		},
+	}}
+	handler := NewMessagesHandler(nil, service)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /messages/{id}", handler.GetMessage)
+
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/messages/"+messageID, nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", recorder.Code, recorder.Body)
+	}
+	var details models.MessageDetails
+	err := json.NewDecoder(recorder.Body).Decode(&details)
+	if err != nil || details.MessageID != messageID || details.SendingStatus != "sent" || len(details.Attempts) != 1 {
+		t.Fatalf("unexpected response %+v, %v", details, err)
+	}
+
+	for _, path := range []string{"/messages/00000000-0000-0000-0000-000000000000", "/messages/not-a-uuid"} {
+		recorder = httptest.NewRecorder()
+		mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		if recorder.Code != http.StatusNotFound {
+			t.Fatalf("%s: expected 404, got %d", path, recorder.Code)
+		}
+	}
+
+	service.err = errors.New("database unavailable")
+	recorder = httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/messages/"+messageID, nil))
+	if recorder.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", recorder.Code)
+	}
+}
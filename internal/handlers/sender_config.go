@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"auto-message-sender/internal/models"
+)
+
+const maxSenderConfigBodySize = 4 << 10
+
+type senderConfigService interface {
+	Config() models.SenderConfig
+	UpdateConfig(update func(config *models.SenderConfig)) (models.SenderConfig, error)
+}
+
+type SenderConfigHandler struct {
+	senderConfigService senderConfigService
+}
+
+func NewSenderConfigHandler(senderConfigService senderConfigService) *SenderConfigHandler {
+	return &SenderConfigHandler{
+		senderConfigService: senderConfigService,
+	}
+}
+
+type senderConfigResponse struct {
+	InitialDelay string `json:"initial_delay"`
+	Interval     string `json:"interval"`
+	BatchSize    int    `json:"batch_size"`
+	Concurrency  int    `json:"concurrency"`
+}
+
+// updateSenderConfigRequest fields are optional, missing fields keep their current value
+type updateSenderConfigRequest struct {
+	Interval    *string `json:"interval"`
+	BatchSize   *int    `json:"batch_size"`
+	Concurrency *int    `json:"concurrency"`
+}
+
+func newSenderConfigResponse(config models.SenderConfig) senderConfigResponse {
+	return senderConfigResponse{
+		InitialDelay: config.InitialDelay.String(),
+		Interval:     config.Interval.String(),
+		BatchSize:    config.BatchSize,
+		Concurrency:  config.Concurrency,
+	}
+}
+
+// GetConfig handles GET /sender/config
+func (h *SenderConfigHandler) GetConfig(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, newSenderConfigResponse(h.senderConfigService.Config()))
+}
+
+// UpdateConfig handles PUT /sender/config
+func (h *SenderConfigHandler) UpdateConfig(w http.ResponseWriter, r *http.Request) {
+	var request updateSenderConfigRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSenderConfigBodySize))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&request)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	var interval time.Duration
+	if request.Interval != nil {
+		interval, err = time.ParseDuration(*request.Interval)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("%w: invalid interval: %w", models.ErrInvalidSenderConfig, err))
+			return
+		}
+	}
+	config, err := h.senderConfigService.UpdateConfig(func(config *models.SenderConfig) {
+		if request.Interval != nil {
+			config.Interval = interval
+		}
+		if request.BatchSize != nil {
+			config.BatchSize = *request.BatchSize
+		}
+		if request.Concurrency != nil {
+			config.Concurrency = *request.Concurrency
+		}
+	})
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidSenderConfig) {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newSenderConfigResponse(config))
+}
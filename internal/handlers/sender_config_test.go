@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"auto-message-sender/internal/models"
+)
+
+type fakeSenderConfigService struct {
+	config models.SenderConfig
+}
+
+func (s *fakeSenderConfigService) Config() models.SenderConfig {
+	return s.config
+}
+
+func (s *fakeSenderConfigService) UpdateConfig(update func(config *models.SenderConfig)) (models.SenderConfig, error) {
+	config := s.config
+	update(&config)
+	err := config.Validate()
+	if err != nil {
+		return models.SenderConfig{}, err
+	}
+	s.config = config
+	return config, nil
+}
+
+func TestSenderConfigHandlerUpdateConfig(t *testing.T) {
+	initial := models.SenderConfig{InitialDelay: time.Second, Interval: 2 * time.Minute, BatchSize: 2, Concurrency: 1}
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+		want     models.SenderConfig
+	}{
+		{
+			name:     "partial update",
+			body:     `{"batch_size": 50}`,
+			wantCode: http.StatusOK,
+			want:     models.SenderConfig{InitialDelay: time.Second, Interval: 2 * time.Minute, BatchSize: 50, Concurrency: 1},
+		},
+		{
+			name:     "full update",
+			body:     `{"interval": "30s", "batch_size": 10, "concurrency": 5}`,
+			wantCode: http.StatusOK,
+			want:     models.SenderConfig{InitialDelay: time.Second, Interval: 30 * time.Second, BatchSize: 10, Concurrency: 5},
+		},
+		{name: "invalid interval", body: `{"interval": "soon"}`, wantCode: http.StatusBadRequest, want: initial},
+		{name: "invalid config", body: `{"batch_size": 50, "concurrency": 0}`, wantCode: http.StatusBadRequest, want: initial},
+		{name: "unknown field", body: `{"initial_delay": "1s"}`, wantCode: http.StatusBadRequest, want: initial},
+		{name: "malformed body", body: `{`, wantCode: http.StatusBadRequest, want: initial},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &fakeSenderConfigService{config: initial}
+			handler := NewSenderConfigHandler(service)
+
+			recorder := httptest.NewRecorder()
+			handler.UpdateConfig(recorder, httptest.NewRequest(http.MethodPut, "/sender/config", strings.NewReader(tt.body)))
+			if recorder.Code != tt.wantCode {
+				t.Fatalf("expected %d, got %d: %s", tt.wantCode, recorder.Code, recorder.Body)
+			}
+			if service.config != tt.want {
+				t.Fatalf("expected config %+v, got %+v", tt.want, service.config)
+			}
+			if recorder.Code != http.StatusOK {
+				return
+			}
+			var response senderConfigResponse
+			err := json.NewDecoder(recorder.Body).Decode(&response)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if response != newSenderConfigResponse(tt.want) {
+				t.Fatalf("expected response %+v, got %+v", newSenderConfigResponse(tt.want), response)
+			}
+		})
+	}
+}
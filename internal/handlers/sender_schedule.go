@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"net/http"
+
+	"auto-message-sender/internal/models"
+)
+
+type senderScheduleService interface {
+	ScheduleState() models.ScheduleState
+}
+
+type SenderScheduleHandler struct {
+	senderScheduleService senderScheduleService
+}
+
+func NewSenderScheduleHandler(senderScheduleService senderScheduleService) *SenderScheduleHandler {
+	return &SenderScheduleHandler{
+		senderScheduleService: senderScheduleService,
+	}
+}
+
+// GetSchedule handles GET /sender/schedule
+func (h *SenderScheduleHandler) GetSchedule(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, h.senderScheduleService.ScheduleState())
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"net/http"
+
+	"auto-message-sender/internal/models"
+)
+
+type senderStatusService interface {
+	Status() models.SenderStatus
+}
+
+type circuitBreakerStatusService interface {
+	Status() []models.CircuitBreakerStatus
+}
+
+type failoverStatusService interface {
+	Status() []models.FailoverEndpointStatus
+}
+
+type cacheRetentionStatusService interface {
+	Status() models.CacheRetentionStatus
+}
+
+type SenderStatusHandler struct {
+	senderStatusService         senderStatusService
+	circuitBreakerStatusService circuitBreakerStatusService
+	failoverStatusService       failoverStatusService
+	cacheRetentionStatusService cacheRetentionStatusService
+}
+
+func NewSenderStatusHandler(
+	senderStatusService senderStatusService,
+	circuitBreakerStatusService circuitBreakerStatusService,
+	failoverStatusService failoverStatusService,
+	cacheRetentionStatusService cacheRetentionStatusService,
+) *SenderStatusHandler {
+	return &SenderStatusHandler{
+		senderStatusService:         senderStatusService,
+		circuitBreakerStatusService: circuitBreakerStatusService,
+		failoverStatusService:       failoverStatusService,
+		cacheRetentionStatusService: cacheRetentionStatusService,
+	}
+}
+
+type senderStatusResponse struct {
+	models.SenderStatus
+	// CircuitBreakers are the breakers of the upstream webhook endpoints
+	CircuitBreakers []models.CircuitBreakerStatus `json:"circuit_breakers"`
+	// Endpoints are the upstream webhook endpoints, ordered by priority
+	Endpoints      []models.FailoverEndpointStatus `json:"endpoints"`
+	CacheRetention models.CacheRetentionStatus     `json:"cache_retention"`
+}
+
+// GetStatus handles GET /sender/status
+func (h *SenderStatusHandler) GetStatus(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, senderStatusResponse{
+		SenderStatus:    h.senderStatusService.Status(),
+		CircuitBreakers: h.circuitBreakerStatusService.Status(),
+		Endpoints:       h.failoverStatusService.Status(),
+		CacheRetention:  h.cacheRetentionStatusService.Status(),
+	})
+}
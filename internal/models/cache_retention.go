@@ -0,0 +1,44 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// CacheRetentionPolicy bounds the sent message cache. Zero TTL or MaxEntries
+// disable that limit.
+type CacheRetentionPolicy struct {
+	// TTL is how long a sent message is kept after it was cached
+	TTL time.Duration
+	// MaxEntries is the number of most recent sent messages kept
+	MaxEntries int
+	// TrimInterval is how often the index is trimmed to the policy
+	TrimInterval time.Duration
+}
+
+func (p CacheRetentionPolicy) Validate() error {
+	if p.TTL < 0 {
+		return fmt.Errorf("cache ttl must not be negative: %s", p.TTL)
+	}
+	if p.MaxEntries < 0 {
+		return fmt.Errorf("cache max entries must not be negative: %d", p.MaxEntries)
+	}
+	if p.TrimInterval <= 0 {
+		return fmt.Errorf("cache trim interval must be positive: %s", p.TrimInterval)
+	}
+	return nil
+}
+
+// CacheRetentionStatus is the retention policy of the sent message cache and
+// the evictions of this instance
+type CacheRetentionStatus struct {
+	// TTL is "0s" and MaxEntries is 0 when the limit is disabled
+	TTL          string     `json:"ttl"`
+	MaxEntries   int        `json:"max_entries"`
+	TrimInterval string     `json:"trim_interval"`
+	LastTrimAt   *time.Time `json:"last_trim_at,omitempty"`
+	// EvictedExpired and EvictedOverflow count the messages removed because they
+	// were older than TTL or beyond MaxEntries
+	EvictedExpired  int64 `json:"evicted_expired"`
+	EvictedOverflow int64 `json:"evicted_overflow"`
+}
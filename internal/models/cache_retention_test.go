@@ -0,0 +1,28 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCacheRetentionPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  CacheRetentionPolicy
+		wantErr bool
+	}{
+		{name: "ttl and max entries", policy: CacheRetentionPolicy{TTL: time.Hour, MaxEntries: 100, TrimInterval: time.Minute}},
+		{name: "limits disabled", policy: CacheRetentionPolicy{TrimInterval: time.Minute}},
+		{name: "negative ttl", policy: CacheRetentionPolicy{TTL: -time.Hour, TrimInterval: time.Minute}, wantErr: true},
+		{name: "negative max entries", policy: CacheRetentionPolicy{MaxEntries: -1, TrimInterval: time.Minute}, wantErr: true},
+		{name: "no trim interval", policy: CacheRetentionPolicy{TTL: time.Hour}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// Circuit breaker states, a half-open circuit lets one probe request through at a time
+const (
+	CircuitStateClosed   = "closed"
+	CircuitStateOpen     = "open"
+	CircuitStateHalfOpen = "half-open"
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type CircuitBreakerStatus struct {
+	Name                string     `json:"name"`
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+	// RetryAt is when an open circuit lets the next probe request through
+	RetryAt *time.Time `json:"retry_at,omitempty"`
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Delivery states reported by the provider after it accepted a message
+const (
+	DeliveryStatusDelivered   = "delivered"
+	DeliveryStatusUndelivered = "undelivered"
+	DeliveryStatusExpired     = "expired"
+)
+
+var (
+	ErrInvalidDeliveryReport = errors.New("invalid delivery report")
+	ErrInvalidSignature      = errors.New("invalid signature")
+	// ErrStaleDeliveryReport is returned for a report older than the recorded state
+	ErrStaleDeliveryReport = errors.New("stale delivery report")
+)
+
+// DeliveryReport is a delivery receipt (DLR) posted by the provider
+type DeliveryReport struct {
+	// Provider is the provider that accepted the message, e.g. "webhook"
+	Provider string `json:"provider"`
+	// ProviderMessageID is the id the provider returned when it accepted the message
+	ProviderMessageID string `json:"messageId"`
+	Status            string `json:"status"`
+	// Timestamp is when the provider observed the status
+	Timestamp time.Time `json:"timestamp"`
+	// ErrorCode is the provider error of an undelivered or expired message
+	ErrorCode string `json:"errorCode,omitempty"`
+}
+
+func (r DeliveryReport) Validate() error {
+	if r.Provider == "" {
+		return fmt.Errorf("%w: provider is required", ErrInvalidDeliveryReport)
+	}
+	if r.ProviderMessageID == "" {
+		return fmt.Errorf("%w: messageId is required", ErrInvalidDeliveryReport)
+	}
+	switch r.Status {
+	case DeliveryStatusDelivered, DeliveryStatusUndelivered, DeliveryStatusExpired:
+	default:
+		return fmt.Errorf("%w: status %q must be %s, %s or %s", ErrInvalidDeliveryReport, r.Status, DeliveryStatusDelivered, DeliveryStatusUndelivered, DeliveryStatusExpired)
+	}
+	if r.Timestamp.IsZero() {
+		return fmt.Errorf("%w: timestamp is required", ErrInvalidDeliveryReport)
+	}
+	return nil
+}
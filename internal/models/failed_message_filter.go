@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	DefaultFailedMessageLimit = 100
+	MaxFailedMessageLimit     = 1000
+)
+
+// FailedMessageFilter selects failed messages, zero value fields are not applied
+type FailedMessageFilter struct {
+	PhoneNumber string
+	// ErrorContains matches messages whose last error contains the text, case insensitive
+	ErrorContains string
+	// Since and Until are compared with the time the message was marked as failed
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+	Offset int
+}
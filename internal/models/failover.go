@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// FailoverEndpointStatus is the health of an upstream endpoint of the failover sender
+type FailoverEndpointStatus struct {
+	Name     string `json:"name"`
+	Priority int    `json:"priority"`
+	Weight   int    `json:"weight"`
+	Healthy  bool   `json:"healthy"`
+	// Requests and Failures are counted in the health window
+	Requests     int        `json:"requests"`
+	Failures     int        `json:"failures"`
+	EjectedUntil *time.Time `json:"ejected_until,omitempty"`
+}
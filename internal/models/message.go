@@ -1,14 +1,147 @@
 package models
 
 import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
+// Delivery channels of a message. SMS messages are sent to PhoneNumber, email
+// and chat messages to Recipient.
+const (
+	ChannelSMS   = "sms"
+	ChannelEmail = "email"
+	ChannelChat  = "chat"
+)
+
+const (
+	// MaxMessageContentLength is the maximum number of characters a single SMS
+	// can carry
+	MaxMessageContentLength = 160
+	// MaxEmailContentLength matches the messages.message_content column size
+	MaxEmailContentLength = 10000
+	// MaxChatContentLength is the text limit of a Slack message
+	MaxChatContentLength = 4000
+	// MaxRecipientLength matches the messages.recipient column size
+	MaxRecipientLength = 320
+)
+
+var (
+	ErrInvalidChannel        = errors.New("invalid channel")
+	ErrInvalidPhoneNumber    = errors.New("invalid phone number")
+	ErrInvalidRecipient      = errors.New("invalid recipient")
+	ErrInvalidMessageContent = errors.New("invalid message content")
+	ErrMessageNotFound       = errors.New("message not found")
+	ErrMessageNotFailed      = errors.New("message is not failed")
+	ErrLeaseLost             = errors.New("message lease lost")
+)
+
+// phoneNumberPattern accepts E.164 formatted phone numbers like "+905558889900"
+var phoneNumberPattern = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+
+var messageIDPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
 type Message struct {
-	MessageID      string
-	PhoneNumber    string
-	MessageContent string
-	SendingStatus  string
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
+	MessageID string `json:"message_id"`
+	// Channel is sms if empty
+	Channel     string `json:"channel"`
+	PhoneNumber string `json:"phone_number"`
+	// Recipient is the email address of an email, chat messages may name a
+	// chat channel to post to
+	Recipient      string `json:"recipient,omitempty"`
+	MessageContent string `json:"message_content"`
+	SendingStatus  string `json:"sending_status"`
+	// SendAt schedules the message, it is not sent before this time when set
+	SendAt *time.Time `json:"send_at,omitempty"`
+	// AttemptCount is the number of times the message has been claimed for sending,
+	// a replay does not reset it
+	AttemptCount int `json:"attempt_count"`
+	// ReplayCount is the number of times the failed message was moved back to the queue
+	ReplayCount int `json:"replay_count"`
+	// ReplayedAttempts is AttemptCount at the last replay, the attempts before
+	// it do not count against the retry limit
+	ReplayedAttempts int `json:"replayed_attempts"`
+	// LastError is the error of the latest failed attempt
+	LastError     string     `json:"last_error,omitempty"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+	// ClaimedBy is the instance that holds the lease of a pending message
+	ClaimedBy string     `json:"claimed_by,omitempty"`
+	ClaimedAt *time.Time `json:"claimed_at,omitempty"`
+	// ProviderMessageID is recorded when the provider accepts the message, it is
+	// unique per Provider
+	ProviderMessageID  string     `json:"provider_message_id,omitempty"`
+	Provider           string     `json:"provider,omitempty"`
+	ProviderAcceptedAt *time.Time `json:"provider_accepted_at,omitempty"`
+	// DeliveryStatus is the latest delivery report of the provider, see DeliveryStatusDelivered
+	DeliveryStatus    string     `json:"delivery_status,omitempty"`
+	DeliveryStatusAt  *time.Time `json:"delivery_status_at,omitempty"`
+	DeliveryErrorCode string     `json:"delivery_error_code,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+// IdempotencyKey identifies a logical send of the message, a provider that
+// receives the same key twice must not deliver the message twice. It stays the
+// same when the message is claimed again, e.g. after a crash or a retryable
+// error, and changes only when the failed message is replayed.
+func (m Message) IdempotencyKey() string {
+	return m.MessageID + "-" + strconv.Itoa(m.ReplayCount)
+}
+
+// AttemptsSinceReplay is the number of claims since the message was last replayed,
+// the retry limit applies to it
+func (m Message) AttemptsSinceReplay() int {
+	return m.AttemptCount - m.ReplayedAttempts
+}
+
+// ValidMessageID reports whether messageID is a well formed message id (UUID)
+func ValidMessageID(messageID string) bool {
+	return messageIDPattern.MatchString(messageID)
+}
+
+// MessageChannel returns the channel of the message, sms if it is not set
+func (m Message) MessageChannel() string {
+	if m.Channel == "" {
+		return ChannelSMS
+	}
+	return m.Channel
+}
+
+// Validate checks the fields that are required for a new message to be enqueued
+func (m Message) Validate() error {
+	maxContentLength := MaxMessageContentLength
+	switch m.MessageChannel() {
+	case ChannelSMS:
+		if !phoneNumberPattern.MatchString(m.PhoneNumber) {
+			return fmt.Errorf("%w: %q must be in E.164 format", ErrInvalidPhoneNumber, m.PhoneNumber)
+		}
+	case ChannelEmail:
+		address, err := mail.ParseAddress(m.Recipient)
+		if err != nil || address.Address != m.Recipient || len(m.Recipient) > MaxRecipientLength {
+			return fmt.Errorf("%w: %q is not an email address", ErrInvalidRecipient, m.Recipient)
+		}
+		maxContentLength = MaxEmailContentLength
+	case ChannelChat:
+		if len(m.Recipient) > MaxRecipientLength {
+			return fmt.Errorf("%w: longer than %d characters", ErrInvalidRecipient, MaxRecipientLength)
+		}
+		maxContentLength = MaxChatContentLength
+	default:
+		return fmt.Errorf("%w: %q must be %s, %s or %s", ErrInvalidChannel, m.Channel, ChannelSMS, ChannelEmail, ChannelChat)
+	}
+	if m.PhoneNumber != "" && !phoneNumberPattern.MatchString(m.PhoneNumber) {
+		return fmt.Errorf("%w: %q must be in E.164 format", ErrInvalidPhoneNumber, m.PhoneNumber)
+	}
+	if strings.TrimSpace(m.MessageContent) == "" {
+		return fmt.Errorf("%w: content is empty", ErrInvalidMessageContent)
+	}
+	if length := utf8.RuneCountInString(m.MessageContent); length > maxContentLength {
+		return fmt.Errorf("%w: content length %d exceeds %d characters", ErrInvalidMessageContent, length, maxContentLength)
+	}
+	return nil
 }
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+)
+
+// MessageAttempt is a single send attempt of a message, it is recorded together
+// with the status change the attempt led to
+type MessageAttempt struct {
+	AttemptID int64  `json:"attempt_id"`
+	MessageID string `json:"message_id"`
+	// AttemptNumber is the claim count of the message when it was sent
+	AttemptNumber int `json:"attempt_number"`
+	// Provider is the provider adapter or transport, e.g. "webhook" or "smtp"
+	Provider string `json:"provider,omitempty"`
+	// Endpoint is the failover endpoint the message was sent to
+	Endpoint string `json:"endpoint,omitempty"`
+	// StatusCode is the HTTP status of the provider response, 0 if no response
+	// was received or the transport is not HTTP
+	StatusCode        int    `json:"status_code,omitempty"`
+	LatencyMS         int64  `json:"latency_ms"`
+	ProviderMessageID string `json:"provider_message_id,omitempty"`
+	// Error is empty for an accepted message
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// MessageDetails is a message with its send attempts and the response of the
+// provider that accepted it
+type MessageDetails struct {
+	Message
+	Attempts []MessageAttempt `json:"attempts"`
+	// ProviderResponse is nil until a provider returns a message id for the message
+	ProviderResponse *MessageSenderResponse `json:"provider_response,omitempty"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"errors"
+)
+
+var ErrMalformedImport = errors.New("malformed import")
+
+const (
+	ImportFormatCSV    = "csv"
+	ImportFormatNDJSON = "ndjson"
+)
+
+const (
+	ImportRowAccepted = "accepted"
+	ImportRowRejected = "rejected"
+)
+
+type ImportRowResult struct {
+	Line   int    `json:"line"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type ImportReport struct {
+	Accepted int               `json:"accepted"`
+	Rejected int               `json:"rejected"`
+	Rows     []ImportRowResult `json:"rows"`
+}
+
+func (r *ImportReport) Accept(line int) {
+	r.Accepted++
+	r.Rows = append(r.Rows, ImportRowResult{
+		Line:   line,
+		Status: ImportRowAccepted,
+	})
+}
+
+func (r *ImportReport) Reject(line int, err error) {
+	r.Rejected++
+	r.Rows = append(r.Rows, ImportRowResult{
+		Line:   line,
+		Status: ImportRowRejected,
+		Error:  err.Error(),
+	})
+}
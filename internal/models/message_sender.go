@@ -8,4 +8,15 @@ type MessageSenderResponse struct {
 	Message   string    `json:"message"`
 	MessageID string    `json:"message_id"`
 	SentAt    time.Time `json:"sent_at"`
+	// DeliveryStatus is set once the provider reports the delivery of the message
+	DeliveryStatus   string     `json:"delivery_status,omitempty"`
+	DeliveryStatusAt *time.Time `json:"delivery_status_at,omitempty"`
+	// Provider, Endpoint and StatusCode describe the upstream request, they are
+	// recorded with the send attempt
+	Provider   string `json:"-"`
+	Endpoint   string `json:"-"`
+	StatusCode int    `json:"-"`
+	// FailedEndpoints are the failover endpoints that failed before the one
+	// that accepted the message, each is recorded as a send attempt
+	FailedEndpoints []*EndpointError `json:"-"`
 }
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMessageValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		message Message
+		wantErr error
+	}{
+		{
+			name:    "valid message",
+			message: Message{PhoneNumber: "+905558889900", MessageContent: "example message content"},
+		},
+		{
+			name:    "max length content",
+			message: Message{PhoneNumber: "+905558889900", MessageContent: strings.Repeat("ç", MaxMessageContentLength)},
+		},
+		{
+			name:    "missing plus prefix",
+			message: Message{PhoneNumber: "905558889900", MessageContent: "example"},
+			wantErr: ErrInvalidPhoneNumber,
+		},
+		{
+			name:    "phone number with letters",
+			message: Message{PhoneNumber: "+90555abc9900", MessageContent: "example"},
+			wantErr: ErrInvalidPhoneNumber,
+		},
+		{
+			name:    "empty content",
+			message: Message{PhoneNumber: "+905558889900", MessageContent: "   "},
+			wantErr: ErrInvalidMessageContent,
+		},
+		{
+			name:    "too long content",
+			message: Message{PhoneNumber: "+905558889900", MessageContent: strings.Repeat("a", MaxMessageContentLength+1)},
+			wantErr: ErrInvalidMessageContent,
+		},
+		{
+			name:    "valid email",
+			message: Message{Channel: ChannelEmail, Recipient: "jane@example.com", MessageContent: strings.Repeat("a", MaxMessageContentLength+1)},
+		},
+		{
+			name:    "email with display name",
+			message: Message{Channel: ChannelEmail, Recipient: "Jane <jane@example.com>", MessageContent: "example"},
+			wantErr: ErrInvalidRecipient,
+		},
+		{
+			name:    "email without recipient",
+			message: Message{Channel: ChannelEmail, PhoneNumber: "+905558889900", MessageContent: "example"},
+			wantErr: ErrInvalidRecipient,
+		},
+		{
+			name:    "valid chat without recipient",
+			message: Message{Channel: ChannelChat, MessageContent: "example"},
+		},
+		{
+			name:    "too long chat content",
+			message: Message{Channel: ChannelChat, MessageContent: strings.Repeat("a", MaxChatContentLength+1)},
+			wantErr: ErrInvalidMessageContent,
+		},
+		{
+			name:    "unknown channel",
+			message: Message{Channel: "fax", PhoneNumber: "+905558889900", MessageContent: "example"},
+			wantErr: ErrInvalidChannel,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.message.Validate()
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestMessageIdempotencyKey(t *testing.T) {
+	claimed := Message{MessageID: "1", AttemptCount: 1}
+	// the instance crashes after the provider accepted the message, it is claimed again
+	reclaimed := Message{MessageID: "1", AttemptCount: 2}
+	if claimed.IdempotencyKey() != reclaimed.IdempotencyKey() {
+		t.Fatalf("expected the same key for every claim, got %q and %q", claimed.IdempotencyKey(), reclaimed.IdempotencyKey())
+	}
+	replayed := Message{MessageID: "1", AttemptCount: 3, ReplayCount: 1, ReplayedAttempts: 2}
+	if replayed.IdempotencyKey() == claimed.IdempotencyKey() {
+		t.Fatalf("expected a new key after a replay, got %q", replayed.IdempotencyKey())
+	}
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+)
+
+// ScheduleState describes when the auto sender is allowed to send
+type ScheduleState struct {
+	Timezone  string   `json:"timezone"`
+	Cron      string   `json:"cron,omitempty"`
+	Windows   []string `json:"windows"`
+	Blackouts []string `json:"blackouts"`
+	// Allowed reports whether sending is allowed right now
+	Allowed bool `json:"allowed"`
+	// NextAllowedAt is set when sending is not allowed right now
+	NextAllowedAt *time.Time `json:"next_allowed_at,omitempty"`
+	// NextRunAt is not set when the sender is stopped
+	NextRunAt *time.Time `json:"next_run_at,omitempty"`
+}
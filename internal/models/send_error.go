@@ -0,0 +1,75 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrRateLimited = errors.New("rate limited")
+
+// DeferredSendError is returned by a message sender that did not attempt to send
+// the message, e.g. because a rate limit was hit. The message is returned to the
+// queue for Delay and the claim is not counted as an attempt.
+type DeferredSendError struct {
+	Err   error
+	Delay time.Duration
+}
+
+func (e *DeferredSendError) Error() string {
+	return "send deferred for " + e.Delay.String() + ": " + e.Err.Error()
+}
+
+func (e *DeferredSendError) Unwrap() error {
+	return e.Err
+}
+
+// SendError is a failed send classified by the message sender. Retryable errors
+// (network errors, 5xx, 429) are retried by the retry policy no sooner than
+// RetryAfter, permanent ones (e.g. a 4xx validation error) fail the message at once.
+type SendError struct {
+	// StatusCode is 0 if no response was received
+	StatusCode int
+	// Body is the response body, truncated
+	Body      string
+	Retryable bool
+	// Unprocessed is set when the provider certainly did not process the request
+	Unprocessed bool
+	RetryAfter  time.Duration
+	Err         error
+}
+
+func (e *SendError) Error() string {
+	message := e.Err.Error()
+	if e.Body != "" {
+		message += ": " + e.Body
+	}
+	return message
+}
+
+func (e *SendError) Unwrap() error {
+	return e.Err
+}
+
+// EndpointError tags a send error with the provider and the endpoint that
+// returned it, so the send attempt can be attributed
+type EndpointError struct {
+	Provider string
+	// Endpoint is empty if the sender has a single endpoint
+	Endpoint string
+	// Latency is how long the endpoint took to fail, 0 if not measured
+	Latency time.Duration
+	// FailedEndpoints are the failover endpoints that failed before this one
+	FailedEndpoints []*EndpointError
+	Err             error
+}
+
+func (e *EndpointError) Error() string {
+	if e.Endpoint == "" {
+		return e.Err.Error()
+	}
+	return "endpoint " + e.Endpoint + ": " + e.Err.Error()
+}
+
+func (e *EndpointError) Unwrap() error {
+	return e.Err
+}
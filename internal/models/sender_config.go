@@ -0,0 +1,43 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	MinSenderInterval    = time.Second
+	MaxSenderBatchSize   = 1000
+	MaxSenderConcurrency = 100
+)
+
+var ErrInvalidSenderConfig = errors.New("invalid sender config")
+
+// SenderConfig controls how often and how many messages the auto sender dispatches
+type SenderConfig struct {
+	// InitialDelay is the delay before the first batch after the application starts
+	InitialDelay time.Duration
+	// Interval is the delay between two batches
+	Interval time.Duration
+	// BatchSize is the maximum number of messages claimed in a batch
+	BatchSize int
+	// Concurrency is the maximum number of messages of a batch sent in parallel
+	Concurrency int
+}
+
+func (c SenderConfig) Validate() error {
+	if c.InitialDelay <= 0 {
+		return fmt.Errorf("%w: initial delay must be positive: %s", ErrInvalidSenderConfig, c.InitialDelay)
+	}
+	if c.Interval < MinSenderInterval {
+		return fmt.Errorf("%w: interval must be at least %s: %s", ErrInvalidSenderConfig, MinSenderInterval, c.Interval)
+	}
+	if c.BatchSize < 1 || c.BatchSize > MaxSenderBatchSize {
+		return fmt.Errorf("%w: batch size must be between 1 and %d: %d", ErrInvalidSenderConfig, MaxSenderBatchSize, c.BatchSize)
+	}
+	if c.Concurrency < 1 || c.Concurrency > MaxSenderConcurrency {
+		return fmt.Errorf("%w: concurrency must be between 1 and %d: %d", ErrInvalidSenderConfig, MaxSenderConcurrency, c.Concurrency)
+	}
+	return nil
+}
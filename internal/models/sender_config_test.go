@@ -0,0 +1,42 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSenderConfigValidate(t *testing.T) {
+	valid := SenderConfig{InitialDelay: time.Second, Interval: 2 * time.Minute, BatchSize: 2, Concurrency: 1}
+	tests := []struct {
+		name    string
+		update  func(config *SenderConfig)
+		wantErr bool
+	}{
+		{name: "valid", update: func(*SenderConfig) {}},
+		{name: "limits", update: func(config *SenderConfig) {
+			config.Interval = MinSenderInterval
+			config.BatchSize = MaxSenderBatchSize
+			config.Concurrency = MaxSenderConcurrency
+		}},
+		{name: "no initial delay", update: func(config *SenderConfig) { config.InitialDelay = 0 }, wantErr: true},
+		{name: "short interval", update: func(config *SenderConfig) { config.Interval = MinSenderInterval - 1 }, wantErr: true},
+		{name: "zero batch size", update: func(config *SenderConfig) { config.BatchSize = 0 }, wantErr: true},
+		{name: "large batch size", update: func(config *SenderConfig) { config.BatchSize = MaxSenderBatchSize + 1 }, wantErr: true},
+		{name: "zero concurrency", update: func(config *SenderConfig) { config.Concurrency = 0 }, wantErr: true},
+		{name: "large concurrency", update: func(config *SenderConfig) { config.Concurrency = MaxSenderConcurrency + 1 }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := valid
+			tt.update(&config)
+			err := config.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidSenderConfig) {
+				t.Fatalf("expected ErrInvalidSenderConfig, got %v", err)
+			}
+		})
+	}
+}
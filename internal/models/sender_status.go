@@ -0,0 +1,42 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// Auto sender states. A running sender dispatches batches on its schedule, a
+// paused one does not. A sender that is asked to stop while a batch is in
+// progress is draining until the batch finishes and becomes paused. A stopped
+// sender has exited and can not be started again.
+const (
+	SenderStateRunning  = "running"
+	SenderStatePaused   = "paused"
+	SenderStateDraining = "draining"
+	SenderStateStopped  = "stopped"
+)
+
+var ErrSenderStopped = errors.New("auto message sender is stopped")
+
+// BatchResult is the outcome of a single dispatch batch
+type BatchResult struct {
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Claimed    int       `json:"claimed"`
+	Sent       int       `json:"sent"`
+	Retried    int       `json:"retried"`
+	Failed     int       `json:"failed"`
+	// Deferred messages were returned to the queue without an attempt
+	Deferred int `json:"deferred"`
+	// LeaseLost messages were claimed again after their lease expired
+	LeaseLost int `json:"lease_lost"`
+	// Error is set when the batch was aborted
+	Error string `json:"error,omitempty"`
+}
+
+type SenderStatus struct {
+	State     string       `json:"state"`
+	LastRunAt *time.Time   `json:"last_run_at,omitempty"`
+	NextRunAt *time.Time   `json:"next_run_at,omitempty"`
+	LastBatch *BatchResult `json:"last_batch,omitempty"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	DefaultSentMessageLimit = 100
+	MaxSentMessageLimit     = 1000
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// SentMessageFilter selects a page of sent messages, newest first. Zero value
+// fields are not applied.
+type SentMessageFilter struct {
+	// Since and Until are compared with the time the message was sent, Since is
+	// inclusive and Until is exclusive
+	Since time.Time
+	Until time.Time
+	Limit int
+	// Cursor is the NextCursor of the previous page
+	Cursor string
+}
+
+// SentMessagePage is a page of sent messages, NextCursor is empty on the last page
+type SentMessagePage struct {
+	Messages   []MessageSenderResponse
+	NextCursor string
+}
@@ -0,0 +1,219 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cron is a standard five field cron expression (minute hour day-of-month month
+// day-of-week). Fields support "*", lists "1,2", ranges "1-5", steps "*/15" or
+// "1-30/5" and month and weekday names. Predefined schedules like "@hourly" and
+// "@daily" are accepted as well.
+type Cron struct {
+	expression string
+	minute     uint64
+	hour       uint64
+	dayOfMonth uint64
+	month      uint64
+	dayOfWeek  uint64
+	// dayOfMonthAny and dayOfWeekAny are set when the field starts with "*", like
+	// cron does a day matches if either field matches when both are restricted
+	dayOfMonthAny bool
+	dayOfWeekAny  bool
+}
+
+type cronField struct {
+	name  string
+	min   int
+	max   int
+	names map[string]int
+}
+
+var (
+	minuteField     = cronField{name: "minute", min: 0, max: 59}
+	hourField       = cronField{name: "hour", min: 0, max: 23}
+	dayOfMonthField = cronField{name: "day of month", min: 1, max: 31}
+	monthField      = cronField{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// day of week 7 is accepted as sunday and folded into 0
+	dayOfWeekField = cronField{name: "day of week", min: 0, max: 7, names: weekdayNames}
+)
+
+var weekdayNames = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+var predefinedCrons = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+func ParseCron(expression string) (*Cron, error) {
+	spec := strings.TrimSpace(expression)
+	if predefined, ok := predefinedCrons[strings.ToLower(spec)]; ok {
+		spec = predefined
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression %q: expected 5 fields, got %d", expression, len(fields))
+	}
+	cron := &Cron{
+		expression:    expression,
+		dayOfMonthAny: strings.HasPrefix(fields[2], "*"),
+		dayOfWeekAny:  strings.HasPrefix(fields[4], "*"),
+	}
+	var err error
+	for i, target := range []struct {
+		field cronField
+		bits  *uint64
+	}{
+		{minuteField, &cron.minute},
+		{hourField, &cron.hour},
+		{dayOfMonthField, &cron.dayOfMonth},
+		{monthField, &cron.month},
+		{dayOfWeekField, &cron.dayOfWeek},
+	} {
+		*target.bits, err = target.field.parse(fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %w", expression, err)
+		}
+	}
+	if cron.dayOfWeek&(1<<7) != 0 {
+		cron.dayOfWeek = cron.dayOfWeek&^(1<<7) | 1
+	}
+	return cron, nil
+}
+
+func (f cronField) parse(field string) (uint64, error) {
+	var bits uint64
+	for part := range strings.SplitSeq(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid %s step %q", f.name, stepPart)
+			}
+		}
+		var start, end int
+		switch {
+		case rangePart == "*":
+			start, end = f.min, f.max
+		case strings.Contains(rangePart, "-"):
+			startPart, endPart, _ := strings.Cut(rangePart, "-")
+			var err error
+			start, err = f.value(startPart)
+			if err != nil {
+				return 0, err
+			}
+			end, err = f.value(endPart)
+			if err != nil {
+				return 0, err
+			}
+		default:
+			var err error
+			start, err = f.value(rangePart)
+			if err != nil {
+				return 0, err
+			}
+			end = start
+			if hasStep {
+				end = f.max
+			}
+		}
+		if start > end {
+			return 0, fmt.Errorf("invalid %s range %q", f.name, rangePart)
+		}
+		for value := start; value <= end; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+	return bits, nil
+}
+
+func (f cronField) value(text string) (int, error) {
+	if value, ok := f.names[strings.ToLower(text)]; ok {
+		return value, nil
+	}
+	value, err := strconv.Atoi(text)
+	if err != nil || value < f.min || value > f.max {
+		return 0, fmt.Errorf("invalid %s value %q, must be between %d and %d", f.name, text, f.min, f.max)
+	}
+	return value, nil
+}
+
+func (c *Cron) String() string {
+	return c.expression
+}
+
+// Next returns the first time after t that matches the expression, in the
+// location of t. The zero time is returned if nothing matches in five years,
+// e.g. for "0 0 30 2 *".
+func (c *Cron) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	yearLimit := t.Year() + 5
+	// added is set when a field was moved forward, the smaller fields are reset then
+	added := false
+wrap:
+	if t.Year() > yearLimit {
+		return time.Time{}
+	}
+	for c.month&(1<<uint(t.Month())) == 0 {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+		}
+		t = t.AddDate(0, 1, 0)
+		if t.Month() == time.January {
+			goto wrap
+		}
+	}
+	for !c.dayMatches(t) {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		}
+		t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		if t.Day() == 1 {
+			goto wrap
+		}
+	}
+	for c.hour&(1<<uint(t.Hour())) == 0 {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc)
+		}
+		t = t.Add(time.Hour)
+		if t.Hour() == 0 {
+			goto wrap
+		}
+	}
+	for c.minute&(1<<uint(t.Minute())) == 0 {
+		added = true
+		t = t.Add(time.Minute)
+		if t.Minute() == 0 {
+			goto wrap
+		}
+	}
+	return t
+}
+
+func (c *Cron) dayMatches(t time.Time) bool {
+	dayOfMonth := c.dayOfMonth&(1<<uint(t.Day())) != 0
+	dayOfWeek := c.dayOfWeek&(1<<uint(t.Weekday())) != 0
+	if c.dayOfMonthAny || c.dayOfWeekAny {
+		return dayOfMonth && dayOfWeek
+	}
+	return dayOfMonth || dayOfWeek
+}
@@ -0,0 +1,152 @@
+// Package schedule decides when the auto sender is allowed to dispatch messages.
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maxWindowSearch bounds the search for the next allowed time, windows repeat
+// every week so anything further away is never allowed
+const maxWindowSearch = 8 * 24 * time.Hour
+
+// maxCronSearch bounds the number of cron times skipped while looking for one in
+// an allowed window
+const maxCronSearch = 10000
+
+// Schedule combines an optional cron expression with allowed time windows and
+// blackout windows evaluated in Location. Sending is allowed when the time is in
+// any of the windows (or no window is configured) and in none of the blackouts.
+// The zero value allows sending at any time.
+type Schedule struct {
+	Cron      *Cron
+	Windows   []Window
+	Blackouts []Window
+	Location  *time.Location
+}
+
+// New parses a schedule, empty cron, windows and blackouts are not applied.
+// Multiple windows or blackouts are separated by ";".
+func New(cron, windows, blackouts string, location *time.Location) (*Schedule, error) {
+	schedule := &Schedule{
+		Location: location,
+	}
+	var err error
+	if strings.TrimSpace(cron) != "" {
+		schedule.Cron, err = ParseCron(cron)
+		if err != nil {
+			return nil, err
+		}
+	}
+	schedule.Windows, err = parseWindows(windows)
+	if err != nil {
+		return nil, err
+	}
+	schedule.Blackouts, err = parseWindows(blackouts)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := schedule.Next(time.Now(), 0); !ok {
+		return nil, fmt.Errorf("schedule never allows sending, check cron, windows and blackouts")
+	}
+	return schedule, nil
+}
+
+func parseWindows(text string) ([]Window, error) {
+	var windows []Window
+	for part := range strings.SplitSeq(text, ";") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		window, err := ParseWindow(part)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, window)
+	}
+	return windows, nil
+}
+
+func (s *Schedule) location() *time.Location {
+	if s.Location == nil {
+		return time.UTC
+	}
+	return s.Location
+}
+
+// Allowed reports whether sending is allowed at t
+func (s *Schedule) Allowed(t time.Time) bool {
+	t = t.In(s.location())
+	for _, blackout := range s.Blackouts {
+		if blackout.Contains(t) {
+			return false
+		}
+	}
+	if len(s.Windows) == 0 {
+		return true
+	}
+	for _, window := range s.Windows {
+		if window.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// NextAllowed returns t if sending is allowed at t, otherwise the start of the
+// next minute sending is allowed. It returns false if sending is never allowed.
+func (s *Schedule) NextAllowed(t time.Time) (time.Time, bool) {
+	if s.Allowed(t) {
+		return t, true
+	}
+	limit := t.Add(maxWindowSearch)
+	// windows have minute precision, checking every minute is enough
+	for next := t.Truncate(time.Minute).Add(time.Minute); next.Before(limit); next = next.Add(time.Minute) {
+		if s.Allowed(next) {
+			return next, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Next returns the time of the next run after a run at now. With a cron
+// expression it is the next allowed cron time and delay is not used, otherwise
+// it is now+delay moved to the next allowed time. It returns false if there is
+// no such time.
+func (s *Schedule) Next(now time.Time, delay time.Duration) (time.Time, bool) {
+	now = now.In(s.location())
+	if s.Cron == nil {
+		return s.NextAllowed(now.Add(delay))
+	}
+	next := s.Cron.Next(now)
+	for i := 0; i < maxCronSearch && !next.IsZero(); i++ {
+		allowed, ok := s.NextAllowed(next)
+		if !ok {
+			return time.Time{}, false
+		}
+		if allowed.Equal(next) {
+			return next, true
+		}
+		// skip the cron times before the window opens
+		next = s.Cron.Next(allowed.Add(-time.Nanosecond))
+	}
+	return time.Time{}, false
+}
+
+func (s *Schedule) String() string {
+	var parts []string
+	if s.Cron != nil {
+		parts = append(parts, "cron "+s.Cron.String())
+	}
+	for _, window := range s.Windows {
+		parts = append(parts, "window "+window.String())
+	}
+	for _, blackout := range s.Blackouts {
+		parts = append(parts, "blackout "+blackout.String())
+	}
+	if len(parts) == 0 {
+		return "always in " + s.location().String()
+	}
+	return strings.Join(parts, ", ") + " in " + s.location().String()
+}
@@ -0,0 +1,122 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return location
+}
+
+func TestCronNext(t *testing.T) {
+	istanbul := mustLoadLocation(t, "Europe/Istanbul")
+	tests := []struct {
+		expression string
+		from       time.Time
+		want       time.Time
+	}{
+		{"* * * * *", time.Date(2025, 11, 12, 10, 15, 30, 0, time.UTC), time.Date(2025, 11, 12, 10, 16, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2025, 11, 12, 10, 15, 0, 0, time.UTC), time.Date(2025, 11, 12, 10, 30, 0, 0, time.UTC)},
+		{"0 9 * * mon-fri", time.Date(2025, 11, 14, 9, 0, 0, 0, istanbul), time.Date(2025, 11, 17, 9, 0, 0, 0, istanbul)},
+		{"30 8 1 * *", time.Date(2025, 12, 1, 9, 0, 0, 0, time.UTC), time.Date(2026, 1, 1, 8, 30, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2025, 11, 12, 23, 59, 0, 0, time.UTC), time.Date(2025, 11, 13, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// day of month and day of week are both restricted, either one matches
+		{"0 12 1 * 5", time.Date(2025, 11, 12, 0, 0, 0, 0, time.UTC), time.Date(2025, 11, 14, 12, 0, 0, 0, time.UTC)},
+		{"0 12 * * 7", time.Date(2025, 11, 12, 0, 0, 0, 0, time.UTC), time.Date(2025, 11, 16, 12, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		cron, err := ParseCron(tt.expression)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.expression, err)
+		}
+		if got := cron.Next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("%s: Next(%s) = %s, want %s", tt.expression, tt.from, got, tt.want)
+		}
+	}
+}
+
+func TestParseCronInvalid(t *testing.T) {
+	for _, expression := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "*/0 * * * *", "5-1 * * * *", "* * * foo *"} {
+		if _, err := ParseCron(expression); err == nil {
+			t.Errorf("%q: expected error", expression)
+		}
+	}
+}
+
+func TestScheduleAllowed(t *testing.T) {
+	istanbul := mustLoadLocation(t, "Europe/Istanbul")
+	schedule, err := New("", "Mon-Fri 09:00-21:00", "Mon-Fri 12:00-13:00;Sat-Sun 00:00-24:00", istanbul)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		at   time.Time
+		want bool
+	}{
+		{time.Date(2025, 11, 12, 9, 0, 0, 0, istanbul), true},
+		{time.Date(2025, 11, 12, 8, 59, 0, 0, istanbul), false},
+		{time.Date(2025, 11, 12, 21, 0, 0, 0, istanbul), false},
+		{time.Date(2025, 11, 12, 12, 30, 0, 0, istanbul), false},
+		{time.Date(2025, 11, 15, 10, 0, 0, 0, istanbul), false},
+		// 07:00 UTC is 10:00 in Istanbul
+		{time.Date(2025, 11, 12, 7, 0, 0, 0, time.UTC), true},
+	}
+	for _, tt := range tests {
+		if got := schedule.Allowed(tt.at); got != tt.want {
+			t.Errorf("Allowed(%s) = %t, want %t", tt.at, got, tt.want)
+		}
+	}
+}
+
+func TestWindowCrossingMidnight(t *testing.T) {
+	window, err := ParseWindow("Fri 22:00-06:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !window.Contains(time.Date(2025, 11, 14, 23, 0, 0, 0, time.UTC)) {
+		t.Error("expected friday 23:00 in window")
+	}
+	if !window.Contains(time.Date(2025, 11, 15, 5, 59, 0, 0, time.UTC)) {
+		t.Error("expected saturday 05:59 in window")
+	}
+	if window.Contains(time.Date(2025, 11, 14, 5, 0, 0, 0, time.UTC)) {
+		t.Error("expected friday 05:00 out of window")
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	istanbul := mustLoadLocation(t, "Europe/Istanbul")
+	schedule, err := New("", "Mon-Fri 09:00-21:00", "", istanbul)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// friday evening, the next run is monday morning
+	next, ok := schedule.Next(time.Date(2025, 11, 14, 20, 59, 0, 0, istanbul), 2*time.Minute)
+	if want := time.Date(2025, 11, 17, 9, 0, 0, 0, istanbul); !ok || !next.Equal(want) {
+		t.Fatalf("Next = %s, want %s", next, want)
+	}
+
+	schedule, err = New("*/30 * * * *", "Mon-Fri 09:10-21:00", "", istanbul)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, ok = schedule.Next(time.Date(2025, 11, 12, 8, 0, 0, 0, istanbul), 0)
+	if want := time.Date(2025, 11, 12, 9, 30, 0, 0, istanbul); !ok || !next.Equal(want) {
+		t.Fatalf("Next = %s, want %s", next, want)
+	}
+}
+
+func TestScheduleNeverAllowed(t *testing.T) {
+	_, err := New("", "Mon 09:00-10:00", "Mon 00:00-24:00", time.UTC)
+	if err == nil {
+		t.Fatal("expected error for a schedule that never allows sending")
+	}
+}
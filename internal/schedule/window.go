@@ -0,0 +1,124 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const minutesPerDay = 24 * 60
+
+// Window is a weekly recurring time range like "Mon-Fri 09:00-21:00". A window
+// whose end is not after its start crosses midnight, "22:00-06:00" starts on the
+// given days and ends on the next day.
+type Window struct {
+	text string
+	days [7]bool
+	// start and end are minutes since midnight, end is exclusive
+	start int
+	end   int
+}
+
+// ParseWindow parses a window in the "[days] HH:MM-HH:MM" format. Days are a
+// comma separated list of weekday names or ranges ("Mon-Fri,Sun"), every day
+// is used when days are omitted or "*".
+func ParseWindow(text string) (Window, error) {
+	fields := strings.Fields(text)
+	var daysPart, timePart string
+	switch len(fields) {
+	case 1:
+		daysPart, timePart = "*", fields[0]
+	case 2:
+		daysPart, timePart = fields[0], fields[1]
+	default:
+		return Window{}, fmt.Errorf("invalid window %q: expected \"[days] HH:MM-HH:MM\"", text)
+	}
+	window := Window{
+		text: strings.Join(fields, " "),
+	}
+	var err error
+	window.days, err = parseDays(daysPart)
+	if err != nil {
+		return Window{}, fmt.Errorf("invalid window %q: %w", text, err)
+	}
+	startPart, endPart, ok := strings.Cut(timePart, "-")
+	if !ok {
+		return Window{}, fmt.Errorf("invalid window %q: expected HH:MM-HH:MM time range", text)
+	}
+	window.start, err = parseClock(startPart)
+	if err != nil {
+		return Window{}, fmt.Errorf("invalid window %q: %w", text, err)
+	}
+	window.end, err = parseClock(endPart)
+	if err != nil {
+		return Window{}, fmt.Errorf("invalid window %q: %w", text, err)
+	}
+	if window.start == minutesPerDay {
+		return Window{}, fmt.Errorf("invalid window %q: start can not be 24:00", text)
+	}
+	return window, nil
+}
+
+func parseDays(text string) ([7]bool, error) {
+	var days [7]bool
+	if text == "*" {
+		for i := range days {
+			days[i] = true
+		}
+		return days, nil
+	}
+	for part := range strings.SplitSeq(text, ",") {
+		startPart, endPart, isRange := strings.Cut(part, "-")
+		start, ok := weekdayNames[strings.ToLower(startPart)]
+		if !ok {
+			return days, fmt.Errorf("invalid weekday %q", startPart)
+		}
+		end := start
+		if isRange {
+			end, ok = weekdayNames[strings.ToLower(endPart)]
+			if !ok {
+				return days, fmt.Errorf("invalid weekday %q", endPart)
+			}
+		}
+		// ranges may wrap the week, e.g. "Sat-Sun"
+		for day := start; ; day = (day + 1) % 7 {
+			days[day] = true
+			if day == end {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+func parseClock(text string) (int, error) {
+	hourPart, minutePart, ok := strings.Cut(text, ":")
+	if !ok {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", text)
+	}
+	hour, err := strconv.Atoi(hourPart)
+	if err != nil || hour < 0 || hour > 24 {
+		return 0, fmt.Errorf("invalid hour in %q", text)
+	}
+	minute, err := strconv.Atoi(minutePart)
+	if err != nil || minute < 0 || minute > 59 || (hour == 24 && minute != 0) {
+		return 0, fmt.Errorf("invalid minute in %q", text)
+	}
+	return hour*60 + minute, nil
+}
+
+func (w Window) String() string {
+	return w.text
+}
+
+// Contains reports whether t, in the location it carries, is inside the window
+func (w Window) Contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	today := w.days[t.Weekday()]
+	if w.start < w.end {
+		return today && minute >= w.start && minute < w.end
+	}
+	yesterday := w.days[(t.Weekday()+6)%7]
+	return (today && minute >= w.start) || (yesterday && minute < w.end)
+}
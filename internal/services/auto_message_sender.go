@@ -2,15 +2,24 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"auto-message-sender/internal/models"
+	"auto-message-sender/internal/schedule"
 )
 
 type messageRepository interface {
 	GetUnsentMessages(ctx context.Context, limit int) ([]models.Message, error)
-	UpdateMessageStatus(ctx context.Context, messageID, sendingStatus string) error
+	MarkMessageSent(ctx context.Context, attempts []models.MessageAttempt) error
+	RecordProviderMessageID(ctx context.Context, messageID, provider, providerMessageID string) error
+	ScheduleMessageRetry(ctx context.Context, attempts []models.MessageAttempt, delay time.Duration) error
+	MarkMessageFailed(ctx context.Context, attempts []models.MessageAttempt) error
+	DeferMessage(ctx context.Context, messageID string, delay time.Duration) error
+	RenewLease(ctx context.Context, messageID string) error
+	NextDueAt(ctx context.Context) (*time.Time, error)
 }
 
 type messageSender interface {
@@ -21,80 +30,532 @@ type setCache interface {
 	Set(ctx context.Context, message models.MessageSenderResponse) error
 }
 
+// sendOutcome is what happened to a single message of a batch
+type sendOutcome int
+
+const (
+	outcomeSent sendOutcome = iota
+	outcomeRetried
+	outcomeFailed
+	// outcomeDeferred messages are returned to the queue without an attempt
+	outcomeDeferred
+	// outcomeAborted messages keep their lease until it expires
+	outcomeAborted
+	outcomeLeaseLost
+)
+
+// AutoMessageSender dispatches waiting messages in batches. Its state is changed
+// with Start and Stop, which never block and are safe for concurrent use, the
+// Run loop picks the change up.
 type AutoMessageSender struct {
-	messageRepository        messageRepository
-	messageSender            messageSender
-	cache                    setCache
-	maxGetUnsentMessageLimit int
-	stopSignal               chan struct{}
-	startSignal              chan struct{}
+	messageRepository messageRepository
+	messageSender     messageSender
+	cache             setCache
+	retryPolicy       RetryPolicy
+	schedule          *schedule.Schedule
+	configMu          sync.RWMutex
+	config            models.SenderConfig
+	configChanged     chan struct{}
+	stateChanged      chan struct{}
+	dueSignal         chan struct{}
+	stateMu           sync.RWMutex
+	state             string
+	batchInProgress   bool
+	lastRunAt         time.Time
+	lastBatch         *models.BatchResult
+	nextRunAt         time.Time
+	// nextDueAt is the earliest known time a waiting message becomes due
+	nextDueAt time.Time
 }
 
+// NewAutoMessageSender creates a running sender, config is expected to be validated
 func NewAutoMessageSender(
 	messageRepository messageRepository,
 	messageSender messageSender,
 	cache setCache,
-	maxGetUnsentMessageLimit int,
+	config models.SenderConfig,
+	retryPolicy RetryPolicy,
+	sendSchedule *schedule.Schedule,
 ) *AutoMessageSender {
 	return &AutoMessageSender{
-		messageRepository:        messageRepository,
-		messageSender:            messageSender,
-		cache:                    cache,
-		maxGetUnsentMessageLimit: maxGetUnsentMessageLimit,
-		stopSignal:               make(chan struct{}),
-		startSignal:              make(chan struct{}),
+		messageRepository: messageRepository,
+		messageSender:     messageSender,
+		cache:             cache,
+		retryPolicy:       retryPolicy,
+		schedule:          sendSchedule,
+		config:            config,
+		configChanged:     make(chan struct{}, 1),
+		stateChanged:      make(chan struct{}, 1),
+		dueSignal:         make(chan struct{}, 1),
+		state:             models.SenderStateRunning,
 	}
 }
 
+// Run dispatches batches until ctx is done, the sender is stopped afterwards.
+// Batch errors do not stop the sender, they are reported in the status.
 func (s *AutoMessageSender) Run(ctx context.Context) error {
+	defer s.setStopped()
 	// Uygulama başladığında gönderim işlemine başla
-	ticker := time.NewTicker(1 * time.Second)
-	defer ticker.Stop()
+	timer := time.NewTimer(0)
+	timer.Stop()
+	defer timer.Stop()
+	if s.isRunning() {
+		s.scheduleNextRun(timer, s.Config().InitialDelay)
+	}
 	for {
 		select {
 		case <-ctx.Done():
 			return nil
-		case <-ticker.C:
-			ticker.Stop()
-			err := s.sendMessages(ctx)
-			if err != nil {
-				return fmt.Errorf("sendMessages error: %w", err)
+		case <-timer.C:
+			if !s.beginBatch() {
+				continue
+			}
+			result := models.BatchResult{
+				StartedAt: time.Now(),
+			}
+			// the timer may fire slightly before a window opens or the schedule
+			// may not allow sending anymore, the batch is skipped then
+			if s.schedule.Allowed(result.StartedAt) {
+				err := s.sendMessages(ctx, &result)
+				if err != nil {
+					result.Error = err.Error()
+				}
+			}
+			result.FinishedAt = time.Now()
+			if s.endBatch(result) {
+				s.scheduleNextRun(timer, s.Config().Interval)
+			}
+		case <-s.stateChanged:
+			if !s.isRunning() {
+				timer.Stop()
+				s.setNextRunAt(time.Time{})
+				continue
+			}
+			if s.Status().NextRunAt == nil {
+				s.scheduleNextRun(timer, s.Config().Interval)
+			}
+		case <-s.configChanged:
+			if s.isRunning() {
+				s.scheduleNextRun(timer, s.Config().Interval)
+			}
+		case <-s.dueSignal:
+			if s.isRunning() {
+				s.scheduleNextRun(timer, s.untilNextRun())
 			}
-			ticker.Reset(2 * time.Minute)
-		case <-s.stopSignal:
-			ticker.Stop()
-		case <-s.startSignal:
-			ticker.Reset(2 * time.Minute)
 		}
 	}
 }
 
-func (s *AutoMessageSender) Start() {
-	s.startSignal <- struct{}{}
+// Start resumes a paused or draining sender, it has no effect on a running one.
+// A stopped sender can not be started and ErrSenderStopped is returned.
+func (s *AutoMessageSender) Start() (models.SenderStatus, error) {
+	s.stateMu.Lock()
+	switch s.state {
+	case models.SenderStateStopped:
+		s.stateMu.Unlock()
+		return s.Status(), models.ErrSenderStopped
+	case models.SenderStatePaused, models.SenderStateDraining:
+		s.state = models.SenderStateRunning
+		s.notifyStateChanged()
+	}
+	s.stateMu.Unlock()
+	return s.Status(), nil
+}
+
+// Stop pauses the sender. A batch in progress is finished first, the sender is
+// draining until then. Stopping a paused, draining or stopped sender has no effect.
+func (s *AutoMessageSender) Stop() (models.SenderStatus, error) {
+	s.stateMu.Lock()
+	if s.state == models.SenderStateRunning {
+		s.state = models.SenderStatePaused
+		if s.batchInProgress {
+			s.state = models.SenderStateDraining
+		}
+		s.notifyStateChanged()
+	}
+	s.stateMu.Unlock()
+	return s.Status(), nil
+}
+
+// Status returns the state of the sender and the result of the last batch
+func (s *AutoMessageSender) Status() models.SenderStatus {
+	s.stateMu.RLock()
+	defer s.stateMu.RUnlock()
+	status := models.SenderStatus{
+		State: s.state,
+	}
+	if !s.lastRunAt.IsZero() {
+		lastRunAt := s.lastRunAt
+		status.LastRunAt = &lastRunAt
+	}
+	if !s.nextRunAt.IsZero() {
+		nextRunAt := s.nextRunAt
+		status.NextRunAt = &nextRunAt
+	}
+	if s.lastBatch != nil {
+		lastBatch := *s.lastBatch
+		status.LastBatch = &lastBatch
+	}
+	return status
+}
+
+// notifyStateChanged wakes the Run loop, stateMu must be held
+func (s *AutoMessageSender) notifyStateChanged() {
+	select {
+	case s.stateChanged <- struct{}{}:
+	default:
+	}
+}
+
+func (s *AutoMessageSender) isRunning() bool {
+	s.stateMu.RLock()
+	defer s.stateMu.RUnlock()
+	return s.state == models.SenderStateRunning
+}
+
+func (s *AutoMessageSender) setStopped() {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	s.state = models.SenderStateStopped
+	s.nextRunAt = time.Time{}
+}
+
+// beginBatch marks a batch as in progress, it returns false if the sender is
+// not running anymore
+func (s *AutoMessageSender) beginBatch() bool {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	if s.state != models.SenderStateRunning {
+		return false
+	}
+	s.batchInProgress = true
+	s.lastRunAt = time.Now()
+	s.nextRunAt = time.Time{}
+	return true
+}
+
+// endBatch records the result of a batch, a draining sender becomes paused. It
+// returns true if the sender is still running.
+func (s *AutoMessageSender) endBatch(result models.BatchResult) bool {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	s.batchInProgress = false
+	s.lastBatch = &result
+	if s.state == models.SenderStateDraining {
+		s.state = models.SenderStatePaused
+	}
+	return s.state == models.SenderStateRunning
 }
 
-func (s *AutoMessageSender) Stop() {
-	s.stopSignal <- struct{}{}
+// scheduleNextRun resets timer to the next allowed run after delay or nextDueAt
+func (s *AutoMessageSender) scheduleNextRun(timer *time.Timer, delay time.Duration) {
+	now := time.Now()
+	s.stateMu.RLock()
+	nextDueAt := s.nextDueAt
+	s.stateMu.RUnlock()
+	if !nextDueAt.IsZero() {
+		delay = min(delay, max(nextDueAt.Sub(now), 0))
+	}
+	next, ok := s.schedule.Next(now, delay)
+	if !ok {
+		timer.Stop()
+		s.setNextRunAt(time.Time{})
+		return
+	}
+	timer.Reset(next.Sub(now))
+	s.setNextRunAt(next)
+}
+
+func (s *AutoMessageSender) untilNextRun() time.Duration {
+	s.stateMu.RLock()
+	defer s.stateMu.RUnlock()
+	if s.nextRunAt.IsZero() {
+		return s.Config().Interval
+	}
+	return max(time.Until(s.nextRunAt), 0)
+}
+
+// NotifyDue moves the next batch earlier if a message becomes due before it
+func (s *AutoMessageSender) NotifyDue(dueAt time.Time) {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	if !s.nextDueAt.IsZero() && !dueAt.Before(s.nextDueAt) {
+		return
+	}
+	s.nextDueAt = dueAt
+	select {
+	case s.dueSignal <- struct{}{}:
+	default:
+	}
 }
 
-func (s *AutoMessageSender) sendMessages(ctx context.Context) error {
-	messages, err := s.messageRepository.GetUnsentMessages(ctx, s.maxGetUnsentMessageLimit)
+func (s *AutoMessageSender) setNextRunAt(nextRunAt time.Time) {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	s.nextRunAt = nextRunAt
+}
+
+// ScheduleState reports the schedule of the sender and when the next batch runs
+func (s *AutoMessageSender) ScheduleState() models.ScheduleState {
+	now := time.Now()
+	state := models.ScheduleState{
+		Timezone:  s.schedule.Location.String(),
+		Windows:   make([]string, 0, len(s.schedule.Windows)),
+		Blackouts: make([]string, 0, len(s.schedule.Blackouts)),
+		Allowed:   s.schedule.Allowed(now),
+	}
+	if s.schedule.Cron != nil {
+		state.Cron = s.schedule.Cron.String()
+	}
+	for _, window := range s.schedule.Windows {
+		state.Windows = append(state.Windows, window.String())
+	}
+	for _, blackout := range s.schedule.Blackouts {
+		state.Blackouts = append(state.Blackouts, blackout.String())
+	}
+	if !state.Allowed {
+		if nextAllowedAt, ok := s.schedule.NextAllowed(now); ok {
+			state.NextAllowedAt = &nextAllowedAt
+		}
+	}
+	s.stateMu.RLock()
+	defer s.stateMu.RUnlock()
+	if !s.nextRunAt.IsZero() {
+		nextRunAt := s.nextRunAt
+		state.NextRunAt = &nextRunAt
+	}
+	return state
+}
+
+func (s *AutoMessageSender) Config() models.SenderConfig {
+	s.configMu.RLock()
+	defer s.configMu.RUnlock()
+	return s.config
+}
+
+// UpdateConfig applies update to the config of a running sender and returns
+// the new config, a new interval takes effect immediately and the next batch
+// is scheduled one interval from now
+func (s *AutoMessageSender) UpdateConfig(update func(config *models.SenderConfig)) (models.SenderConfig, error) {
+	s.configMu.Lock()
+	config := s.config
+	update(&config)
+	err := config.Validate()
+	if err != nil {
+		s.configMu.Unlock()
+		return models.SenderConfig{}, err
+	}
+	s.config = config
+	s.configMu.Unlock()
+	select {
+	case s.configChanged <- struct{}{}:
+	default:
+		// a change notification is already waiting, it picks up the latest config
+	}
+	return config, nil
+}
+
+func (s *AutoMessageSender) sendMessages(ctx context.Context, result *models.BatchResult) error {
+	config := s.Config()
+	messages, err := s.messageRepository.GetUnsentMessages(ctx, config.BatchSize)
 	if err != nil {
 		return fmt.Errorf("messageRepository.GetUnsentMessages error: %w", err)
 	}
-	for _, message := range messages {
-		sendMessageResponse, err2 := s.messageSender.SendMessage(ctx, message)
-		if err2 != nil {
-			return fmt.Errorf("messageSender.SendMessage error: %w", err2)
+	result.Claimed = len(messages)
+	var batchErr error
+	for _, messageResult := range NewWorkerPool(config.Concurrency).Process(ctx, messages, s.processMessage) {
+		switch messageResult.Outcome {
+		case outcomeSent:
+			result.Sent++
+		case outcomeRetried:
+			result.Retried++
+		case outcomeFailed:
+			result.Failed++
+		case outcomeDeferred:
+			result.Deferred++
+		case outcomeLeaseLost:
+			result.LeaseLost++
 		}
-		err2 = s.cache.Set(ctx, sendMessageResponse)
-		if err2 != nil {
-			return fmt.Errorf("cache.Set error: %w", err2)
-		}
-		err2 = s.messageRepository.UpdateMessageStatus(ctx, message.MessageID, "sent")
-		if err2 != nil {
-			return fmt.Errorf("messageRepository.UpdateMessageStatus error: %w", err2)
+		if messageResult.Err != nil && batchErr == nil {
+			batchErr = messageResult.Err
 		}
 	}
+	if batchErr != nil {
+		return batchErr
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	nextDueAt, err := s.messageRepository.NextDueAt(ctx)
+	if err != nil {
+		return fmt.Errorf("messageRepository.NextDueAt error: %w", err)
+	}
+	s.stateMu.Lock()
+	s.nextDueAt = time.Time{}
+	if nextDueAt != nil {
+		s.nextDueAt = *nextDueAt
+	}
+	s.stateMu.Unlock()
 	return nil
 }
+
+// processMessage sends a message, or defers it if the schedule does not allow
+// sending anymore
+func (s *AutoMessageSender) processMessage(ctx context.Context, message models.Message) (sendOutcome, error) {
+	if ctx.Err() != nil {
+		return outcomeAborted, nil
+	}
+	if !s.schedule.Allowed(time.Now()) {
+		return s.deferMessage(ctx, message)
+	}
+	return s.sendMessage(ctx, message)
+}
+
+// deferMessage returns a claimed message to the queue until the schedule allows
+// sending again, the claim is not counted as an attempt
+func (s *AutoMessageSender) deferMessage(ctx context.Context, message models.Message) (sendOutcome, error) {
+	now := time.Now()
+	nextAllowedAt, ok := s.schedule.NextAllowed(now)
+	if !ok {
+		nextAllowedAt = now
+	}
+	err := s.messageRepository.DeferMessage(ctx, message.MessageID, nextAllowedAt.Sub(now))
+	if err != nil {
+		return abortOutcome(fmt.Errorf("messageRepository.DeferMessage error: %w", err))
+	}
+	return outcomeDeferred, nil
+}
+
+// sendMessage renews the lease of message and sends it
+func (s *AutoMessageSender) sendMessage(ctx context.Context, message models.Message) (sendOutcome, error) {
+	err := s.messageRepository.RenewLease(ctx, message.MessageID)
+	if err != nil {
+		return abortOutcome(fmt.Errorf("messageRepository.RenewLease error: %w", err))
+	}
+	if message.ProviderMessageID != "" {
+		// the provider accepted the message in an earlier attempt that did not
+		// finish, e.g. the instance crashed before marking it sent
+		sendMessageResponse := models.MessageSenderResponse{
+			Message:   "Accepted",
+			MessageID: message.ProviderMessageID,
+		}
+		if message.ProviderAcceptedAt != nil {
+			sendMessageResponse.SentAt = message.ProviderAcceptedAt.UTC()
+		}
+		return s.markSent(ctx, message, sendMessageResponse, []models.MessageAttempt{newMessageAttempt(message, sendMessageResponse, nil, 0)})
+	}
+	startedAt := time.Now()
+	sendMessageResponse, err := s.messageSender.SendMessage(ctx, message)
+	attempts := newMessageAttempts(message, sendMessageResponse, err, time.Since(startedAt))
+	if err != nil {
+		if ctx.Err() != nil {
+			return outcomeAborted, nil
+		}
+		return s.handleSendError(ctx, message, attempts, err)
+	}
+	if sendMessageResponse.MessageID != "" {
+		// recorded before anything else so a crash does not lead to a second send
+		err = s.messageRepository.RecordProviderMessageID(context.WithoutCancel(ctx), message.MessageID, sendMessageResponse.Provider, sendMessageResponse.MessageID)
+		if err != nil {
+			return outcomeAborted, fmt.Errorf("messageRepository.RecordProviderMessageID error: %w", err)
+		}
+	}
+	return s.markSent(ctx, message, sendMessageResponse, attempts)
+}
+
+// newMessageAttempts describes a send of message that took latency. The
+// failover endpoints that failed before the last one are attempts of their own,
+// their latency is not counted for the last one.
+func newMessageAttempts(message models.Message, response models.MessageSenderResponse, sendErr error, latency time.Duration) []models.MessageAttempt {
+	failedEndpoints := response.FailedEndpoints
+	var endpointError *models.EndpointError
+	if errors.As(sendErr, &endpointError) {
+		failedEndpoints = endpointError.FailedEndpoints
+	}
+	attempts := make([]models.MessageAttempt, 0, len(failedEndpoints)+1)
+	for _, failedEndpoint := range failedEndpoints {
+		attempts = append(attempts, newMessageAttempt(message, models.MessageSenderResponse{}, failedEndpoint, failedEndpoint.Latency))
+		latency -= failedEndpoint.Latency
+	}
+	return append(attempts, newMessageAttempt(message, response, sendErr, max(latency, 0)))
+}
+
+// newMessageAttempt describes a send of message that took latency, the provider
+// and endpoint of a failed send are read from sendErr
+func newMessageAttempt(message models.Message, response models.MessageSenderResponse, sendErr error, latency time.Duration) models.MessageAttempt {
+	attempt := models.MessageAttempt{
+		MessageID:         message.MessageID,
+		AttemptNumber:     message.AttemptCount,
+		Provider:          response.Provider,
+		Endpoint:          response.Endpoint,
+		StatusCode:        response.StatusCode,
+		LatencyMS:         latency.Milliseconds(),
+		ProviderMessageID: response.MessageID,
+		CreatedAt:         time.Now().UTC(),
+	}
+	if sendErr == nil {
+		return attempt
+	}
+	attempt.Error = sendErr.Error()
+	var endpointError *models.EndpointError
+	if errors.As(sendErr, &endpointError) {
+		attempt.Provider = endpointError.Provider
+		attempt.Endpoint = endpointError.Endpoint
+	}
+	var sendError *models.SendError
+	if errors.As(sendErr, &sendError) {
+		attempt.StatusCode = sendError.StatusCode
+	}
+	return attempt
+}
+
+// abortOutcome is the outcome of a message whose status change failed with err
+func abortOutcome(err error) (sendOutcome, error) {
+	if errors.Is(err, models.ErrLeaseLost) {
+		return outcomeLeaseLost, nil
+	}
+	return outcomeAborted, err
+}
+
+// markSent marks the message sent before caching it, a message that is not
+// marked sent is sent again. The cache is best effort, errors are reported by
+// the cache logger.
+func (s *AutoMessageSender) markSent(ctx context.Context, message models.Message, sendMessageResponse models.MessageSenderResponse, attempts []models.MessageAttempt) (sendOutcome, error) {
+	err := s.messageRepository.MarkMessageSent(ctx, attempts)
+	if err != nil {
+		return abortOutcome(fmt.Errorf("messageRepository.MarkMessageSent error: %w", err))
+	}
+	_ = s.cache.Set(ctx, sendMessageResponse)
+	return outcomeSent, nil
+}
+
+// handleSendError records the failed attempts and retries, fails or defers the
+// message. Deferred sends did not reach a provider and are not recorded.
+func (s *AutoMessageSender) handleSendError(ctx context.Context, message models.Message, attempts []models.MessageAttempt, sendErr error) (sendOutcome, error) {
+	var deferredSendError *models.DeferredSendError
+	if errors.As(sendErr, &deferredSendError) {
+		err := s.messageRepository.DeferMessage(ctx, message.MessageID, deferredSendError.Delay)
+		if err != nil {
+			return abortOutcome(fmt.Errorf("messageRepository.DeferMessage error: %w", err))
+		}
+		return outcomeDeferred, nil
+	}
+	var sendError *models.SendError
+	permanent := errors.As(sendErr, &sendError) && !sendError.Retryable
+	if permanent || s.retryPolicy.Exhausted(message.AttemptsSinceReplay()) {
+		err := s.messageRepository.MarkMessageFailed(ctx, attempts)
+		if err != nil {
+			return abortOutcome(fmt.Errorf("messageRepository.MarkMessageFailed error: %w", err))
+		}
+		return outcomeFailed, nil
+	}
+	delay := s.retryPolicy.NextDelay(message.AttemptsSinceReplay())
+	if sendError != nil {
+		delay = max(delay, sendError.RetryAfter)
+	}
+	err := s.messageRepository.ScheduleMessageRetry(ctx, attempts, delay)
+	if err != nil {
+		return abortOutcome(fmt.Errorf("messageRepository.ScheduleMessageRetry error: %w", err))
+	}
+	return outcomeRetried, nil
+}
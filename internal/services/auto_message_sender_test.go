@@ -0,0 +1,541 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"auto-message-sender/infra/sender"
+	"auto-message-sender/internal/models"
+	"auto-message-sender/internal/schedule"
+)
+
+type fakeMessageRepository struct {
+	mu       sync.Mutex
+	messages []models.Message
+	statuses map[string]string
+	errors   map[string]string
+	delays   map[string]time.Duration
+	// providerMessageIDs is the dedupe record
+	providerMessageIDs map[string]string
+	attempts           []models.MessageAttempt
+	// leaseLost messages were claimed again by another instance, expiringLeases
+	// are lost after they are renewed
+	leaseLost      map[string]bool
+	expiringLeases map[string]bool
+}
+
+func newFakeMessageRepository(messages ...models.Message) *fakeMessageRepository {
+	return &fakeMessageRepository{
+		messages:           messages,
+		statuses:           make(map[string]string),
+		errors:             make(map[string]string),
+		delays:             make(map[string]time.Duration),
+		providerMessageIDs: make(map[string]string),
+		leaseLost:          make(map[string]bool),
+		expiringLeases:     make(map[string]bool),
+	}
+}
+
+func (r *fakeMessageRepository) GetUnsentMessages(_ context.Context, limit int) ([]models.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	messages := r.messages[:min(limit, len(r.messages))]
+	r.messages = r.messages[len(messages):]
+	for i := range messages {
+		messages[i].AttemptCount++
+		r.statuses[messages[i].MessageID] = "pending"
+	}
+	return messages, nil
+}
+
+func (r *fakeMessageRepository) MarkMessageSent(_ context.Context, attempts []models.MessageAttempt) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	attempt := attempts[len(attempts)-1]
+	if r.leaseLost[attempt.MessageID] {
+		return models.ErrLeaseLost
+	}
+	r.statuses[attempt.MessageID] = "sent"
+	r.attempts = append(r.attempts, attempts...)
+	return nil
+}
+
+func (r *fakeMessageRepository) RecordProviderMessageID(_ context.Context, messageID, _, providerMessageID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.providerMessageIDs[messageID] = providerMessageID
+	return nil
+}
+
+func (r *fakeMessageRepository) ScheduleMessageRetry(_ context.Context, attempts []models.MessageAttempt, delay time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	attempt := attempts[len(attempts)-1]
+	if r.leaseLost[attempt.MessageID] {
+		return models.ErrLeaseLost
+	}
+	r.statuses[attempt.MessageID] = "waiting"
+	r.errors[attempt.MessageID] = attempt.Error
+	r.delays[attempt.MessageID] = delay
+	r.attempts = append(r.attempts, attempts...)
+	return nil
+}
+
+func (r *fakeMessageRepository) MarkMessageFailed(_ context.Context, attempts []models.MessageAttempt) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	attempt := attempts[len(attempts)-1]
+	if r.leaseLost[attempt.MessageID] {
+		return models.ErrLeaseLost
+	}
+	r.statuses[attempt.MessageID] = "failed"
+	r.errors[attempt.MessageID] = attempt.Error
+	r.attempts = append(r.attempts, attempts...)
+	return nil
+}
+
+func (r *fakeMessageRepository) DeferMessage(_ context.Context, messageID string, delay time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.leaseLost[messageID] {
+		return models.ErrLeaseLost
+	}
+	r.statuses[messageID] = "waiting"
+	r.delays[messageID] = delay
+	return nil
+}
+
+func (r *fakeMessageRepository) RenewLease(_ context.Context, messageID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.leaseLost[messageID] {
+		return models.ErrLeaseLost
+	}
+	r.leaseLost[messageID] = r.expiringLeases[messageID]
+	return nil
+}
+
+func (r *fakeMessageRepository) NextDueAt(_ context.Context) (*time.Time, error) {
+	return nil, nil
+}
+
+func (r *fakeMessageRepository) status(messageID string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.statuses[messageID]
+}
+
+type fakeMessageSender struct {
+	failures map[string]error
+}
+
+func (s *fakeMessageSender) SendMessage(_ context.Context, message models.Message) (models.MessageSenderResponse, error) {
+	if err, ok := s.failures[message.MessageID]; ok {
+		return models.MessageSenderResponse{}, err
+	}
+	return models.MessageSenderResponse{
+		Message:   "Accepted",
+		MessageID: "provider-" + message.MessageID,
+		SentAt:    time.Now().UTC(),
+	}, nil
+}
+
+// blockingMessageSender blocks every send until release is closed
+type blockingMessageSender struct {
+	started chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (s *blockingMessageSender) SendMessage(ctx context.Context, message models.Message) (models.MessageSenderResponse, error) {
+	s.once.Do(func() { close(s.started) })
+	select {
+	case <-s.release:
+	case <-ctx.Done():
+		return models.MessageSenderResponse{}, ctx.Err()
+	}
+	return models.MessageSenderResponse{Message: "Accepted", MessageID: "provider-" + message.MessageID}, nil
+}
+
+type fakeSetCache struct {
+	mu       sync.Mutex
+	messages []models.MessageSenderResponse
+	err      error
+}
+
+func (c *fakeSetCache) Set(_ context.Context, message models.MessageSenderResponse) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	c.messages = append(c.messages, message)
+	return nil
+}
+
+func TestAutoMessageSenderRetriesFailedMessages(t *testing.T) {
+	repository := newFakeMessageRepository(
+		models.Message{MessageID: "1"},
+		models.Message{MessageID: "2"},
+		models.Message{MessageID: "3", AttemptCount: 2},
+		models.Message{MessageID: "4", AttemptCount: 3},
+		models.Message{MessageID: "5"},
+		models.Message{MessageID: "6"},
+		// replayed after 4 attempts, the earlier attempts do not count
+		models.Message{MessageID: "7", AttemptCount: 4, ReplayCount: 1, ReplayedAttempts: 4},
+	)
+	messageSender := &fakeMessageSender{
+		failures: map[string]error{
+			"2": errors.New("webhook unavailable"),
+			"3": errors.New("webhook unavailable"),
+			"4": &models.DeferredSendError{Err: models.ErrRateLimited, Delay: time.Minute},
+			"5": &models.SendError{StatusCode: 400, Body: "invalid phone number", Err: errors.New("webhook response 400")},
+			"6": &models.SendError{StatusCode: 429, Retryable: true, RetryAfter: time.Hour, Err: errors.New("webhook response 429")},
+			"7": errors.New("webhook unavailable"),
+		},
+	}
+	cache := &fakeSetCache{}
+	retryPolicy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Minute}
+	autoMessageSender := NewAutoMessageSender(repository, messageSender, cache, models.SenderConfig{
+		InitialDelay: time.Second,
+		Interval:     time.Minute,
+		BatchSize:    10,
+		Concurrency:  4,
+	}, retryPolicy, &schedule.Schedule{})
+
+	var result models.BatchResult
+	err := autoMessageSender.sendMessages(context.Background(), &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Claimed != 7 || result.Sent != 1 || result.Retried != 3 || result.Failed != 2 || result.Deferred != 1 {
+		t.Fatalf("unexpected batch result %+v", result)
+	}
+	if status := repository.status("1"); status != "sent" {
+		t.Fatalf("message 1: expected sent, got %s", status)
+	}
+	if status := repository.status("2"); status != "waiting" {
+		t.Fatalf("message 2: expected waiting, got %s", status)
+	}
+	if delay := repository.delays["2"]; delay < retryPolicy.BaseDelay/2 || delay > retryPolicy.BaseDelay {
+		t.Fatalf("message 2: unexpected retry delay %s", delay)
+	}
+	if status := repository.status("3"); status != "failed" {
+		t.Fatalf("message 3: expected failed, got %s", status)
+	}
+	if repository.errors["3"] != "webhook unavailable" {
+		t.Fatalf("message 3: unexpected last error %q", repository.errors["3"])
+	}
+	if status := repository.status("4"); status != "waiting" || repository.delays["4"] != time.Minute {
+		t.Fatalf("message 4: expected deferred for 1m, got %s %s", status, repository.delays["4"])
+	}
+	if status := repository.status("5"); status != "failed" || repository.errors["5"] != "webhook response 400: invalid phone number" {
+		t.Fatalf("message 5: expected failed at the first attempt, got %s %q", status, repository.errors["5"])
+	}
+	if status := repository.status("6"); status != "waiting" || repository.delays["6"] != time.Hour {
+		t.Fatalf("message 6: expected retry after 1h, got %s %s", status, repository.delays["6"])
+	}
+	if status := repository.status("7"); status != "waiting" {
+		t.Fatalf("message 7: expected a retry after the replay, got %s", status)
+	}
+	if len(cache.messages) != 1 {
+		t.Fatalf("expected 1 cached response, got %d", len(cache.messages))
+	}
+}
+
+func TestAutoMessageSenderLeaseLost(t *testing.T) {
+	repository := newFakeMessageRepository(
+		models.Message{MessageID: "1"},
+		models.Message{MessageID: "2"},
+		models.Message{MessageID: "3"},
+		models.Message{MessageID: "4"},
+	)
+	// 1 is claimed again before it is sent, 2 and 3 while they are being sent
+	repository.leaseLost["1"] = true
+	repository.expiringLeases["2"] = true
+	repository.expiringLeases["3"] = true
+	messageSender := &fakeMessageSender{
+		failures: map[string]error{
+			"3": errors.New("webhook unavailable"),
+		},
+	}
+	autoMessageSender := NewAutoMessageSender(repository, messageSender, &fakeSetCache{}, models.SenderConfig{
+		InitialDelay: time.Second,
+		Interval:     time.Minute,
+		BatchSize:    10,
+		Concurrency:  4,
+	}, RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Minute}, &schedule.Schedule{})
+
+	var result models.BatchResult
+	err := autoMessageSender.sendMessages(context.Background(), &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Claimed != 4 || result.Sent != 1 || result.LeaseLost != 3 {
+		t.Fatalf("unexpected batch result %+v", result)
+	}
+	if _, ok := repository.providerMessageIDs["1"]; ok {
+		t.Fatal("message 1: expected no send after the lease was lost")
+	}
+	for _, messageID := range []string{"1", "2", "3"} {
+		if status := repository.status(messageID); status != "pending" {
+			t.Fatalf("message %s: expected the status to be left to the new claim, got %s", messageID, status)
+		}
+	}
+	if status := repository.status("4"); status != "sent" {
+		t.Fatalf("message 4: expected sent, got %s", status)
+	}
+}
+
+func TestAutoMessageSenderUpdateConfigConcurrently(t *testing.T) {
+	autoMessageSender := NewAutoMessageSender(newFakeMessageRepository(), &fakeMessageSender{}, &fakeSetCache{}, models.SenderConfig{
+		InitialDelay: time.Millisecond,
+		Interval:     time.Hour,
+		BatchSize:    10,
+		Concurrency:  4,
+	}, RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Minute}, &schedule.Schedule{})
+
+	const updates = 50
+	wg := sync.WaitGroup{}
+	for range updates {
+		wg.Go(func() {
+			_, _ = autoMessageSender.UpdateConfig(func(config *models.SenderConfig) { config.BatchSize++ })
+		})
+		wg.Go(func() {
+			_, _ = autoMessageSender.UpdateConfig(func(config *models.SenderConfig) { config.Concurrency++ })
+		})
+	}
+	wg.Wait()
+	config := autoMessageSender.Config()
+	if config.BatchSize != 10+updates || config.Concurrency != 4+updates {
+		t.Fatalf("expected every update to be applied, got %+v", config)
+	}
+
+	_, err := autoMessageSender.UpdateConfig(func(config *models.SenderConfig) { config.BatchSize = 0 })
+	if !errors.Is(err, models.ErrInvalidSenderConfig) {
+		t.Fatalf("expected ErrInvalidSenderConfig, got %v", err)
+	}
+	if autoMessageSender.Config() != config {
+		t.Fatalf("expected an invalid update to keep %+v, got %+v", config, autoMessageSender.Config())
+	}
+}
+
+func TestAutoMessageSenderStateMachine(t *testing.T) {
+	repository := newFakeMessageRepository(models.Message{MessageID: "1"})
+	messageSender := &blockingMessageSender{
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	autoMessageSender := NewAutoMessageSender(repository, messageSender, &fakeSetCache{}, models.SenderConfig{
+		InitialDelay: time.Millisecond,
+		Interval:     time.Hour,
+		BatchSize:    10,
+		Concurrency:  4,
+	}, RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Minute}, &schedule.Schedule{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = autoMessageSender.Run(ctx)
+	}()
+	<-messageSender.started
+
+	status, err := autoMessageSender.Stop()
+	if err != nil || status.State != models.SenderStateDraining {
+		t.Fatalf("expected draining, got %s (%v)", status.State, err)
+	}
+	status, _ = autoMessageSender.Stop()
+	if status.State != models.SenderStateDraining {
+		t.Fatalf("second stop: expected draining, got %s", status.State)
+	}
+	close(messageSender.release)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for autoMessageSender.Status().State != models.SenderStatePaused {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected paused, got %s", autoMessageSender.Status().State)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	status = autoMessageSender.Status()
+	if status.LastBatch == nil || status.LastBatch.Sent != 1 || status.LastRunAt == nil {
+		t.Fatalf("unexpected last batch %+v", status.LastBatch)
+	}
+	if repository.status("1") != "sent" {
+		t.Fatalf("message 1: expected sent, got %s", repository.status("1"))
+	}
+
+	status, err = autoMessageSender.Start()
+	if err != nil || status.State != models.SenderStateRunning {
+		t.Fatalf("expected running, got %s (%v)", status.State, err)
+	}
+	status, err = autoMessageSender.Start()
+	if err != nil || status.State != models.SenderStateRunning {
+		t.Fatalf("second start: expected running, got %s (%v)", status.State, err)
+	}
+
+	cancel()
+	<-done
+	_, err = autoMessageSender.Start()
+	if !errors.Is(err, models.ErrSenderStopped) {
+		t.Fatalf("expected ErrSenderStopped, got %v", err)
+	}
+	if status = autoMessageSender.Status(); status.State != models.SenderStateStopped {
+		t.Fatalf("expected stopped, got %s", status.State)
+	}
+}
+
+// taggedMessageSender answers like a failover sender, the first attempt of a
+// message fails on the primary endpoint and the second fails over from the
+// primary to the backup that accepts it
+type taggedMessageSender struct{}
+
+func (taggedMessageSender) SendMessage(_ context.Context, message models.Message) (models.MessageSenderResponse, error) {
+	if message.AttemptCount == 1 {
+		return models.MessageSenderResponse{}, &models.EndpointError{
+			Provider: "webhook",
+			Endpoint: "primary",
+			Err:      &models.SendError{StatusCode: 503, Retryable: true, Err: errors.New("webhook response 503")},
+		}
+	}
+	return models.MessageSenderResponse{
+		Message:    "Accepted",
+		MessageID:  "provider-" + message.MessageID,
+		Provider:   "webhook",
+		Endpoint:   "backup",
+		StatusCode: 202,
+		FailedEndpoints: []*models.EndpointError{{
+			Provider: "webhook",
+			Endpoint: "primary",
+			Latency:  40 * time.Millisecond,
+			Err:      &models.SendError{StatusCode: 502, Retryable: true, Unprocessed: true, Err: errors.New("webhook response 502")},
+		}},
+	}, nil
+}
+
+func TestAutoMessageSenderRecordsAttempts(t *testing.T) {
+	repository := newFakeMessageRepository(models.Message{MessageID: "1"})
+	autoMessageSender := NewAutoMessageSender(repository, taggedMessageSender{}, &fakeSetCache{}, models.SenderConfig{
+		InitialDelay: time.Second,
+		Interval:     time.Minute,
+		BatchSize:    10,
+		Concurrency:  1,
+	}, RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Minute}, &schedule.Schedule{})
+
+	for _, message := range []models.Message{{MessageID: "1", AttemptCount: 1}, {MessageID: "1", AttemptCount: 2}} {
+		_, err := autoMessageSender.sendMessage(context.Background(), message)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(repository.attempts) != 3 {
+		t.Fatalf("expected 3 attempts, got %+v", repository.attempts)
+	}
+	failed, failedOver, sent := repository.attempts[0], repository.attempts[1], repository.attempts[2]
+	if failed.AttemptNumber != 1 || failed.Provider != "webhook" || failed.Endpoint != "primary" || failed.StatusCode != 503 ||
+		failed.Error != "endpoint primary: webhook response 503" || failed.ProviderMessageID != "" {
+		t.Fatalf("unexpected failed attempt %+v", failed)
+	}
+	if failedOver.AttemptNumber != 2 || failedOver.Endpoint != "primary" || failedOver.StatusCode != 502 || failedOver.LatencyMS != 40 ||
+		failedOver.Error != "endpoint primary: webhook response 502" {
+		t.Fatalf("unexpected failed over attempt %+v", failedOver)
+	}
+	if sent.AttemptNumber != 2 || sent.Endpoint != "backup" || sent.StatusCode != 202 || sent.ProviderMessageID != "provider-1" || sent.Error != "" {
+		t.Fatalf("unexpected sent attempt %+v", sent)
+	}
+}
+
+func TestAutoMessageSenderDoesNotResendAcceptedMessages(t *testing.T) {
+	acceptedAt := time.Date(2025, 11, 13, 10, 0, 0, 0, time.UTC)
+	repository := newFakeMessageRepository(
+		models.Message{MessageID: "1", AttemptCount: 1, ProviderMessageID: "provider-1", ProviderAcceptedAt: &acceptedAt},
+		models.Message{MessageID: "2"},
+	)
+	messageSender := &fakeMessageSender{
+		failures: map[string]error{
+			"1": errors.New("accepted message must not be sent again"),
+		},
+	}
+	cache := &fakeSetCache{}
+	autoMessageSender := NewAutoMessageSender(repository, messageSender, cache, models.SenderConfig{
+		InitialDelay: time.Second,
+		Interval:     time.Minute,
+		BatchSize:    10,
+		Concurrency:  1,
+	}, RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Minute}, &schedule.Schedule{})
+
+	var result models.BatchResult
+	err := autoMessageSender.sendMessages(context.Background(), &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Sent != 2 {
+		t.Fatalf("expected 2 sent messages, got %+v", result)
+	}
+	if repository.providerMessageIDs["2"] != "provider-2" {
+		t.Fatalf("message 2: expected the provider message id to be recorded, got %q", repository.providerMessageIDs["2"])
+	}
+	if cache.messages[0].MessageID != "provider-1" || !cache.messages[0].SentAt.Equal(acceptedAt) {
+		t.Fatalf("message 1: unexpected cached response %+v", cache.messages[0])
+	}
+	if repository.attempts[0].MessageID != "1" || repository.attempts[0].ProviderMessageID != "provider-1" {
+		t.Fatalf("message 1: expected the accepted attempt to be recorded, got %+v", repository.attempts[0])
+	}
+}
+
+func TestAutoMessageSenderMarksSentWhenCacheFails(t *testing.T) {
+	repository := newFakeMessageRepository(models.Message{MessageID: "1"})
+	cache := &fakeSetCache{err: errors.New("redis unavailable")}
+	autoMessageSender := NewAutoMessageSender(repository, &fakeMessageSender{}, cache, models.SenderConfig{
+		InitialDelay: time.Second,
+		Interval:     time.Minute,
+		BatchSize:    10,
+		Concurrency:  1,
+	}, RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Minute}, &schedule.Schedule{})
+
+	var result models.BatchResult
+	err := autoMessageSender.sendMessages(context.Background(), &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Sent != 1 || repository.status("1") != "sent" {
+		t.Fatalf("expected the message to be marked sent without the cache, got %+v, %s", result, repository.status("1"))
+	}
+}
+
+func TestAutoMessageSenderCachesChatMessages(t *testing.T) {
+	// incoming chat webhooks answer with a plain "ok" and no message id
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	repository := newFakeMessageRepository(
+		models.Message{MessageID: "1", Channel: models.ChannelChat, Recipient: "#orders"},
+		models.Message{MessageID: "2", Channel: models.ChannelChat, Recipient: "#orders"},
+	)
+	cache := &fakeSetCache{}
+	autoMessageSender := NewAutoMessageSender(repository, sender.NewChatWebhookMessageSender(server.URL), cache, models.SenderConfig{
+		InitialDelay: time.Second,
+		Interval:     time.Minute,
+		BatchSize:    10,
+		Concurrency:  1,
+	}, RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Minute}, &schedule.Schedule{})
+
+	var result models.BatchResult
+	err := autoMessageSender.sendMessages(context.Background(), &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Sent != 2 || len(cache.messages) != 2 {
+		t.Fatalf("expected 2 cached messages, got %+v, %+v", result, cache.messages)
+	}
+	if cache.messages[0].MessageID == "" || cache.messages[0].MessageID == cache.messages[1].MessageID {
+		t.Fatalf("expected the chat messages to be cached under their own ids, got %+v", cache.messages)
+	}
+}
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type cacheRetention interface {
+	Trim(ctx context.Context) (expired int64, overflow int64, err error)
+}
+
+// CacheTrimmer periodically trims the sent message cache to its retention
+// policy, so the index does not grow without bound between the key expiries
+type CacheTrimmer struct {
+	cache    cacheRetention
+	interval time.Duration
+}
+
+func NewCacheTrimmer(cache cacheRetention, interval time.Duration) *CacheTrimmer {
+	return &CacheTrimmer{
+		cache:    cache,
+		interval: interval,
+	}
+}
+
+// Run trims the cache every interval until ctx is done. A failing trim is
+// retried on the next tick, errors are reported by the cache logger.
+func (t *CacheTrimmer) Run(ctx context.Context) error {
+	if t.interval <= 0 {
+		return fmt.Errorf("cache trimmer interval must be positive: %s", t.interval)
+	}
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			_, _, _ = t.cache.Trim(ctx)
+		}
+	}
+}
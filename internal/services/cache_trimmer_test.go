@@ -0,0 +1,54 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type fakeCacheRetention struct {
+	trims chan struct{}
+	err   error
+}
+
+func (c *fakeCacheRetention) Trim(ctx context.Context) (int64, int64, error) {
+	select {
+	case c.trims <- struct{}{}:
+	case <-ctx.Done():
+	}
+	return 0, 0, c.err
+}
+
+func TestCacheTrimmer(t *testing.T) {
+	// a failing trim does not stop the trimmer, it is retried on the next tick
+	cache := &fakeCacheRetention{trims: make(chan struct{}), err: errors.New("redis unavailable")}
+	trimmer := NewCacheTrimmer(cache, time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- trimmer.Run(ctx)
+	}()
+
+	for range 2 {
+		select {
+		case <-cache.trims:
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected the cache to be trimmed every interval")
+		}
+	}
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("expected nil after the context is done, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the trimmer to stop with the context")
+	}
+
+	err := NewCacheTrimmer(cache, 0).Run(context.Background())
+	if err == nil {
+		t.Fatal("expected an error for a zero interval")
+	}
+}
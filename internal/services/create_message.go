@@ -0,0 +1,45 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"auto-message-sender/internal/models"
+)
+
+type createMessageRepository interface {
+	CreateMessage(ctx context.Context, message models.Message) (string, error)
+}
+
+// dueNotifier is told the send time of scheduled messages
+type dueNotifier interface {
+	NotifyDue(dueAt time.Time)
+}
+
+type CreateMessageService struct {
+	messageRepository createMessageRepository
+	dueNotifier       dueNotifier
+}
+
+func NewCreateMessageService(messageRepository createMessageRepository, dueNotifier dueNotifier) *CreateMessageService {
+	return &CreateMessageService{
+		messageRepository: messageRepository,
+		dueNotifier:       dueNotifier,
+	}
+}
+
+func (s *CreateMessageService) CreateMessage(ctx context.Context, message models.Message) (string, error) {
+	err := message.Validate()
+	if err != nil {
+		return "", err
+	}
+	messageID, err := s.messageRepository.CreateMessage(ctx, message)
+	if err != nil {
+		return "", fmt.Errorf("messageRepository.CreateMessage error: %w", err)
+	}
+	if message.SendAt != nil {
+		s.dueNotifier.NotifyDue(*message.SendAt)
+	}
+	return messageID, nil
+}
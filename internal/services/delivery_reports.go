@@ -0,0 +1,60 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"auto-message-sender/internal/models"
+)
+
+type deliveryReportRepository interface {
+	RecordDeliveryReport(ctx context.Context, report models.DeliveryReport) (string, error)
+}
+
+type deliveryStatusCache interface {
+	SetDeliveryStatus(ctx context.Context, report models.DeliveryReport) error
+}
+
+type messageInvalidator interface {
+	DeleteMessage(ctx context.Context, messageID string) error
+}
+
+// DeliveryReportService records the delivery receipts (DLR) posted by the
+// provider on the message and on the cached sent message
+type DeliveryReportService struct {
+	messageRepository deliveryReportRepository
+	cache             deliveryStatusCache
+	messageCache      messageInvalidator
+}
+
+func NewDeliveryReportService(messageRepository deliveryReportRepository, cache deliveryStatusCache, messageCache messageInvalidator) *DeliveryReportService {
+	return &DeliveryReportService{
+		messageRepository: messageRepository,
+		cache:             cache,
+		messageCache:      messageCache,
+	}
+}
+
+// RecordDeliveryReport returns the message id of the reported message. A stale
+// report is acknowledged without changing the recorded delivery state.
+func (s *DeliveryReportService) RecordDeliveryReport(ctx context.Context, report models.DeliveryReport) (string, error) {
+	err := report.Validate()
+	if err != nil {
+		return "", err
+	}
+	messageID, err := s.messageRepository.RecordDeliveryReport(ctx, report)
+	if errors.Is(err, models.ErrStaleDeliveryReport) {
+		return messageID, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("messageRepository.RecordDeliveryReport error: %w", err)
+	}
+	err = s.cache.SetDeliveryStatus(ctx, report)
+	if err != nil {
+		return "", fmt.Errorf("cache.SetDeliveryStatus error: %w", err)
+	}
+	// a snapshot that can not be deleted expires on its own
+	_ = s.messageCache.DeleteMessage(ctx, messageID)
+	return messageID, nil
+}
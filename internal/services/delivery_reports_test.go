@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"auto-message-sender/internal/models"
+)
+
+type fakeDeliveryReportRepository struct {
+	// messageIDs maps "<provider>/<provider message id>" to message ids
+	messageIDs map[string]string
+	reports    map[string]models.DeliveryReport
+}
+
+func (r *fakeDeliveryReportRepository) RecordDeliveryReport(_ context.Context, report models.DeliveryReport) (string, error) {
+	messageID, ok := r.messageIDs[report.Provider+"/"+report.ProviderMessageID]
+	if !ok {
+		return "", models.ErrMessageNotFound
+	}
+	if recorded, ok := r.reports[messageID]; ok && recorded.Timestamp.After(report.Timestamp) {
+		return messageID, models.ErrStaleDeliveryReport
+	}
+	r.reports[messageID] = report
+	return messageID, nil
+}
+
+type fakeDeliveryStatusCache struct {
+	reports []models.DeliveryReport
+}
+
+func (c *fakeDeliveryStatusCache) SetDeliveryStatus(_ context.Context, report models.DeliveryReport) error {
+	c.reports = append(c.reports, report)
+	return nil
+}
+
+func TestDeliveryReportService(t *testing.T) {
+	now := time.Date(2025, 11, 13, 10, 0, 0, 0, time.UTC)
+	repository := &fakeDeliveryReportRepository{
+		messageIDs: map[string]string{"webhook/provider-1": "message-1"},
+		reports:    make(map[string]models.DeliveryReport),
+	}
+	cache := &fakeDeliveryStatusCache{}
+	messageCache := newFakeMessageCache()
+	messageCache.snapshots["message-1"] = models.MessageDetails{Message: models.Message{MessageID: "message-1", SendingStatus: "sent"}}
+	service := NewDeliveryReportService(repository, cache, messageCache)
+	ctx := context.Background()
+
+	delivered := models.DeliveryReport{Provider: "webhook", ProviderMessageID: "provider-1", Status: models.DeliveryStatusDelivered, Timestamp: now}
+	messageID, err := service.RecordDeliveryReport(ctx, delivered)
+	if err != nil || messageID != "message-1" {
+		t.Fatalf("expected message-1, got %q, %v", messageID, err)
+	}
+	if _, ok := messageCache.snapshots["message-1"]; ok {
+		t.Fatal("expected the snapshot of message-1 to be deleted")
+	}
+	// an older report that arrives late is acknowledged but does not change the state
+	expired := models.DeliveryReport{Provider: "webhook", ProviderMessageID: "provider-1", Status: models.DeliveryStatusExpired, Timestamp: now.Add(-time.Minute)}
+	messageID, err = service.RecordDeliveryReport(ctx, expired)
+	if err != nil || messageID != "message-1" {
+		t.Fatalf("expected stale report to be acknowledged, got %q, %v", messageID, err)
+	}
+	if repository.reports["message-1"] != delivered || len(cache.reports) != 1 || cache.reports[0] != delivered {
+		t.Fatalf("expected only the delivered state to be recorded, got %+v, cached %+v", repository.reports, cache.reports)
+	}
+
+	for _, report := range []models.DeliveryReport{
+		{Provider: "webhook", ProviderMessageID: "provider-2", Status: models.DeliveryStatusDelivered, Timestamp: now},
+		// the same id of another provider is another message
+		{Provider: "smtp", ProviderMessageID: "provider-1", Status: models.DeliveryStatusDelivered, Timestamp: now},
+	} {
+		_, err = service.RecordDeliveryReport(ctx, report)
+		if !errors.Is(err, models.ErrMessageNotFound) {
+			t.Fatalf("%+v: expected ErrMessageNotFound, got %v", report, err)
+		}
+	}
+	for _, report := range []models.DeliveryReport{
+		{Provider: "webhook", ProviderMessageID: "provider-1", Status: "read", Timestamp: now},
+		{ProviderMessageID: "provider-1", Status: models.DeliveryStatusDelivered, Timestamp: now},
+	} {
+		_, err = service.RecordDeliveryReport(ctx, report)
+		if !errors.Is(err, models.ErrInvalidDeliveryReport) {
+			t.Fatalf("%+v: expected ErrInvalidDeliveryReport, got %v", report, err)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"auto-message-sender/internal/models"
+)
+
+type failedMessageRepository interface {
+	ListFailedMessages(ctx context.Context, filter models.FailedMessageFilter) ([]models.Message, error)
+	RetryFailedMessage(ctx context.Context, messageID string) error
+	RetryFailedMessages(ctx context.Context, filter models.FailedMessageFilter) ([]string, error)
+}
+
+// FailedMessagesService is the dead letter queue of the auto sender, it lists the
+// messages that ran out of attempts and moves them back to the queue
+type FailedMessagesService struct {
+	messageRepository failedMessageRepository
+	messageCache      messageInvalidator
+}
+
+func NewFailedMessagesService(messageRepository failedMessageRepository, messageCache messageInvalidator) *FailedMessagesService {
+	return &FailedMessagesService{
+		messageRepository: messageRepository,
+		messageCache:      messageCache,
+	}
+}
+
+func (s *FailedMessagesService) ListFailedMessages(ctx context.Context, filter models.FailedMessageFilter) ([]models.Message, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = models.DefaultFailedMessageLimit
+	}
+	filter.Limit = min(filter.Limit, models.MaxFailedMessageLimit)
+	filter.Offset = max(filter.Offset, 0)
+	messages, err := s.messageRepository.ListFailedMessages(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("messageRepository.ListFailedMessages error: %w", err)
+	}
+	return messages, nil
+}
+
+func (s *FailedMessagesService) RetryFailedMessage(ctx context.Context, messageID string) error {
+	err := s.messageRepository.RetryFailedMessage(ctx, messageID)
+	if err != nil {
+		return fmt.Errorf("messageRepository.RetryFailedMessage error: %w", err)
+	}
+	// a snapshot that can not be deleted expires on its own
+	_ = s.messageCache.DeleteMessage(ctx, messageID)
+	return nil
+}
+
+// RetryFailedMessages retries every failed message matching filter and returns
+// how many were retried
+func (s *FailedMessagesService) RetryFailedMessages(ctx context.Context, filter models.FailedMessageFilter) (int64, error) {
+	messageIDs, err := s.messageRepository.RetryFailedMessages(ctx, filter)
+	if err != nil {
+		return 0, fmt.Errorf("messageRepository.RetryFailedMessages error: %w", err)
+	}
+	for _, messageID := range messageIDs {
+		// a snapshot that can not be deleted expires on its own
+		_ = s.messageCache.DeleteMessage(ctx, messageID)
+	}
+	return int64(len(messageIDs)), nil
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"auto-message-sender/internal/models"
+)
+
+type fakeFailedMessageRepository struct {
+	failed  map[string]bool
+	filters []models.FailedMessageFilter
+}
+
+func (r *fakeFailedMessageRepository) ListFailedMessages(_ context.Context, filter models.FailedMessageFilter) ([]models.Message, error) {
+	r.filters = append(r.filters, filter)
+	return []models.Message{}, nil
+}
+
+func (r *fakeFailedMessageRepository) RetryFailedMessage(_ context.Context, messageID string) error {
+	failed, ok := r.failed[messageID]
+	if !ok {
+		return models.ErrMessageNotFound
+	}
+	if !failed {
+		return models.ErrMessageNotFailed
+	}
+	r.failed[messageID] = false
+	return nil
+}
+
+func (r *fakeFailedMessageRepository) RetryFailedMessages(_ context.Context, filter models.FailedMessageFilter) ([]string, error) {
+	r.filters = append(r.filters, filter)
+	var retried []string
+	for messageID, failed := range r.failed {
+		if failed {
+			r.failed[messageID] = false
+			retried = append(retried, messageID)
+		}
+	}
+	return retried, nil
+}
+
+func TestFailedMessagesServiceListLimits(t *testing.T) {
+	repository := &fakeFailedMessageRepository{}
+	service := NewFailedMessagesService(repository, newFakeMessageCache())
+	for _, filter := range []models.FailedMessageFilter{
+		{},
+		{Limit: models.MaxFailedMessageLimit + 1, Offset: -1},
+		{PhoneNumber: "+905558889900", Limit: 5, Offset: 10},
+	} {
+		_, err := service.ListFailedMessages(context.Background(), filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []models.FailedMessageFilter{
+		{Limit: models.DefaultFailedMessageLimit},
+		{Limit: models.MaxFailedMessageLimit},
+		{PhoneNumber: "+905558889900", Limit: 5, Offset: 10},
+	}
+	for i, filter := range repository.filters {
+		if filter != want[i] {
+			t.Fatalf("filter %d: expected %+v, got %+v", i, want[i], filter)
+		}
+	}
+}
+
+func TestFailedMessagesServiceRetry(t *testing.T) {
+	repository := &fakeFailedMessageRepository{failed: map[string]bool{"1": true, "2": false, "3": true, "4": true}}
+	messageCache := newFakeMessageCache()
+	for _, messageID := range []string{"1", "2", "3"} {
+		messageCache.snapshots[messageID] = models.MessageDetails{Message: models.Message{MessageID: messageID, SendingStatus: "failed"}}
+	}
+	service := NewFailedMessagesService(repository, messageCache)
+	ctx := context.Background()
+
+	err := service.RetryFailedMessage(ctx, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := messageCache.snapshots["1"]; ok {
+		t.Fatal("expected the snapshot of the retried message to be deleted")
+	}
+	err = service.RetryFailedMessage(ctx, "2")
+	if !errors.Is(err, models.ErrMessageNotFailed) {
+		t.Fatalf("expected ErrMessageNotFailed, got %v", err)
+	}
+	if _, ok := messageCache.snapshots["2"]; !ok {
+		t.Fatal("expected the snapshot of a message that was not retried to be kept")
+	}
+	err = service.RetryFailedMessage(ctx, "5")
+	if !errors.Is(err, models.ErrMessageNotFound) {
+		t.Fatalf("expected ErrMessageNotFound, got %v", err)
+	}
+
+	retried, err := service.RetryFailedMessages(ctx, models.FailedMessageFilter{ErrorContains: "unavailable"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retried != 2 || repository.filters[0].ErrorContains != "unavailable" {
+		t.Fatalf("expected 2 retried messages, got %d with %+v", retried, repository.filters)
+	}
+	if _, ok := messageCache.snapshots["3"]; ok {
+		t.Fatal("expected the snapshots of the retried messages to be deleted")
+	}
+	if _, ok := messageCache.snapshots["2"]; !ok {
+		t.Fatal("expected the snapshot of a message that was not retried to be kept")
+	}
+}
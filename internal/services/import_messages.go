@@ -0,0 +1,231 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"strings"
+	"time"
+
+	"auto-message-sender/internal/models"
+)
+
+// maxNDJSONLineSize limits a single NDJSON line, a valid message is far below it
+const maxNDJSONLineSize = 64 << 10
+
+type importMessagesRepository interface {
+	ImportMessages(ctx context.Context, messages iter.Seq2[models.Message, error]) (int64, error)
+}
+
+type ImportMessagesService struct {
+	messageRepository importMessagesRepository
+	dueNotifier       dueNotifier
+}
+
+func NewImportMessagesService(messageRepository importMessagesRepository, dueNotifier dueNotifier) *ImportMessagesService {
+	return &ImportMessagesService{
+		messageRepository: messageRepository,
+		dueNotifier:       dueNotifier,
+	}
+}
+
+// ImportMessages parses body in the given format and stores every valid row.
+// Invalid rows are rejected and reported, a malformed stream aborts the whole
+// import and nothing is stored. The body is copied to a temporary file first,
+// the repository holds the database connection while it reads the rows.
+func (s *ImportMessagesService) ImportMessages(ctx context.Context, format string, body io.Reader) (models.ImportReport, error) {
+	report := models.ImportReport{
+		Rows: make([]models.ImportRowResult, 0),
+	}
+	var parse func(io.Reader, *models.ImportReport) iter.Seq2[models.Message, error]
+	switch format {
+	case models.ImportFormatCSV:
+		parse = csvMessages
+	case models.ImportFormatNDJSON:
+		parse = ndjsonMessages
+	default:
+		return models.ImportReport{}, fmt.Errorf("%w: unsupported format %q", models.ErrMalformedImport, format)
+	}
+	file, err := os.CreateTemp("", "message-import-*")
+	if err != nil {
+		return models.ImportReport{}, fmt.Errorf("create import file error: %w", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	_, err = io.Copy(file, body)
+	if err != nil {
+		return models.ImportReport{}, fmt.Errorf("read import error: %w", err)
+	}
+	_, err = file.Seek(0, io.SeekStart)
+	if err != nil {
+		return models.ImportReport{}, fmt.Errorf("seek import file error: %w", err)
+	}
+	messages := parse(file, &report)
+	var earliestSendAt time.Time
+	_, err = s.messageRepository.ImportMessages(ctx, func(yield func(models.Message, error) bool) {
+		for message, err2 := range messages {
+			if message.SendAt != nil && (earliestSendAt.IsZero() || message.SendAt.Before(earliestSendAt)) {
+				earliestSendAt = *message.SendAt
+			}
+			if !yield(message, err2) {
+				return
+			}
+		}
+	})
+	if err != nil {
+		return models.ImportReport{}, fmt.Errorf("messageRepository.ImportMessages error: %w", err)
+	}
+	if !earliestSendAt.IsZero() {
+		s.dueNotifier.NotifyDue(earliestSendAt)
+	}
+	return report, nil
+}
+
+// validatedMessage validates the message and records the result into report,
+// it returns false when the row is rejected
+func validatedMessage(report *models.ImportReport, line int, message models.Message) bool {
+	err := message.Validate()
+	if err != nil {
+		report.Reject(line, err)
+		return false
+	}
+	report.Accept(line)
+	return true
+}
+
+func csvMessages(body io.Reader, report *models.ImportReport) iter.Seq2[models.Message, error] {
+	return func(yield func(models.Message, error) bool) {
+		reader := csv.NewReader(body)
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		header, err := reader.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = errors.New("missing csv header")
+			}
+			yield(models.Message{}, fmt.Errorf("%w: %w", models.ErrMalformedImport, err))
+			return
+		}
+		channelIndex, phoneNumberIndex, recipientIndex, messageContentIndex, sendAtIndex := -1, -1, -1, -1, -1
+		for i, column := range header {
+			switch strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff"))) {
+			case "channel":
+				channelIndex = i
+			case "phone_number":
+				phoneNumberIndex = i
+			case "recipient":
+				recipientIndex = i
+			case "message_content":
+				messageContentIndex = i
+			case "send_at":
+				sendAtIndex = i
+			}
+		}
+		if (phoneNumberIndex < 0 && recipientIndex < 0) || messageContentIndex < 0 {
+			yield(models.Message{}, fmt.Errorf("%w: csv header must contain phone_number or recipient and message_content columns", models.ErrMalformedImport))
+			return
+		}
+		// field returns the trimmed value of an optional column
+		field := func(record []string, index int) string {
+			if index < 0 {
+				return ""
+			}
+			return strings.TrimSpace(record[index])
+		}
+		for {
+			record, err2 := reader.Read()
+			if errors.Is(err2, io.EOF) {
+				return
+			}
+			if err2 != nil {
+				var parseErr *csv.ParseError
+				if errors.As(err2, &parseErr) {
+					err2 = fmt.Errorf("%w: %w", models.ErrMalformedImport, err2)
+				}
+				yield(models.Message{}, err2)
+				return
+			}
+			line, _ := reader.FieldPos(0)
+			if len(record) != len(header) {
+				report.Reject(line, fmt.Errorf("expected %d fields, got %d", len(header), len(record)))
+				continue
+			}
+			message := models.Message{
+				Channel:        field(record, channelIndex),
+				PhoneNumber:    field(record, phoneNumberIndex),
+				Recipient:      field(record, recipientIndex),
+				MessageContent: record[messageContentIndex],
+			}
+			if sendAtIndex >= 0 && strings.TrimSpace(record[sendAtIndex]) != "" {
+				sendAt, err3 := time.Parse(time.RFC3339, strings.TrimSpace(record[sendAtIndex]))
+				if err3 != nil {
+					report.Reject(line, fmt.Errorf("invalid send_at, RFC3339 time expected: %w", err3))
+					continue
+				}
+				message.SendAt = &sendAt
+			}
+			if !validatedMessage(report, line, message) {
+				continue
+			}
+			if !yield(message, nil) {
+				return
+			}
+		}
+	}
+}
+
+type importMessageRow struct {
+	Channel        string     `json:"channel"`
+	PhoneNumber    string     `json:"phone_number"`
+	Recipient      string     `json:"recipient"`
+	MessageContent string     `json:"message_content"`
+	SendAt         *time.Time `json:"send_at"`
+}
+
+func ndjsonMessages(body io.Reader, report *models.ImportReport) iter.Seq2[models.Message, error] {
+	return func(yield func(models.Message, error) bool) {
+		scanner := bufio.NewScanner(body)
+		scanner.Buffer(make([]byte, 0, 4<<10), maxNDJSONLineSize)
+		line := 0
+		for scanner.Scan() {
+			line++
+			data := bytes.TrimSpace(scanner.Bytes())
+			if len(data) == 0 {
+				continue
+			}
+			var row importMessageRow
+			err := json.Unmarshal(data, &row)
+			if err != nil {
+				report.Reject(line, fmt.Errorf("invalid json: %w", err))
+				continue
+			}
+			message := models.Message{
+				Channel:        strings.TrimSpace(row.Channel),
+				PhoneNumber:    strings.TrimSpace(row.PhoneNumber),
+				Recipient:      strings.TrimSpace(row.Recipient),
+				MessageContent: row.MessageContent,
+				SendAt:         row.SendAt,
+			}
+			if !validatedMessage(report, line, message) {
+				continue
+			}
+			if !yield(message, nil) {
+				return
+			}
+		}
+		err := scanner.Err()
+		if err != nil {
+			if errors.Is(err, bufio.ErrTooLong) {
+				err = fmt.Errorf("%w: line %d is longer than %d bytes", models.ErrMalformedImport, line+1, maxNDJSONLineSize)
+			}
+			yield(models.Message{}, err)
+		}
+	}
+}
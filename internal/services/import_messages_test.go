@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"iter"
+	"strings"
+	"testing"
+	"time"
+
+	"auto-message-sender/internal/models"
+)
+
+type fakeImportMessagesRepository struct {
+	imported []models.Message
+}
+
+func (r *fakeImportMessagesRepository) ImportMessages(_ context.Context, messages iter.Seq2[models.Message, error]) (int64, error) {
+	var imported []models.Message
+	for message, err := range messages {
+		if err != nil {
+			return 0, err
+		}
+		imported = append(imported, message)
+	}
+	r.imported = imported
+	return int64(len(imported)), nil
+}
+
+// eofReader records whether its reader was read to the end
+type eofReader struct {
+	reader io.Reader
+	eof    bool
+}
+
+func (r *eofReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.eof = r.eof || errors.Is(err, io.EOF)
+	return n, err
+}
+
+// bodyReadRepository records whether the body was read before the import started
+type bodyReadRepository struct {
+	fakeImportMessagesRepository
+	body           *eofReader
+	readBeforeCopy bool
+}
+
+func (r *bodyReadRepository) ImportMessages(ctx context.Context, messages iter.Seq2[models.Message, error]) (int64, error) {
+	r.readBeforeCopy = r.body.eof
+	return r.fakeImportMessagesRepository.ImportMessages(ctx, messages)
+}
+
+type fakeDueNotifier struct {
+	dueAt time.Time
+}
+
+func (n *fakeDueNotifier) NotifyDue(dueAt time.Time) {
+	n.dueAt = dueAt
+}
+
+func TestImportMessagesCSV(t *testing.T) {
+	body := "phone_number,message_content,send_at\n" +
+		"+905558889900,first message,\n" +
+		"invalid,second message,\n" +
+		"+905558889911\n" +
+		"+905558889922,\"quoted, message\",2025-11-12T09:00:00+03:00\n" +
+		"+905558889933,bad send time,tomorrow\n"
+	repository := &fakeImportMessagesRepository{}
+	notifier := &fakeDueNotifier{}
+	service := NewImportMessagesService(repository, notifier)
+	report, err := service.ImportMessages(context.Background(), models.ImportFormatCSV, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Accepted != 2 || report.Rejected != 3 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if len(repository.imported) != 2 || repository.imported[1].MessageContent != "quoted, message" {
+		t.Fatalf("unexpected imported messages: %+v", repository.imported)
+	}
+	wantSendAt := time.Date(2025, 11, 12, 6, 0, 0, 0, time.UTC)
+	if sendAt := repository.imported[1].SendAt; sendAt == nil || !sendAt.Equal(wantSendAt) || !notifier.dueAt.Equal(wantSendAt) {
+		t.Fatalf("unexpected send time %v, notified %s", sendAt, notifier.dueAt)
+	}
+	wantStatuses := []string{models.ImportRowAccepted, models.ImportRowRejected, models.ImportRowRejected, models.ImportRowAccepted, models.ImportRowRejected}
+	for i, row := range report.Rows {
+		if row.Line != i+2 || row.Status != wantStatuses[i] {
+			t.Fatalf("unexpected row %d: %+v", i, row)
+		}
+	}
+}
+
+func TestImportMessagesReadsBodyBeforeImport(t *testing.T) {
+	body := &eofReader{reader: strings.NewReader("phone_number,message_content\n+905558889900,first message\n")}
+	repository := &bodyReadRepository{body: body}
+	service := NewImportMessagesService(repository, &fakeDueNotifier{})
+	report, err := service.ImportMessages(context.Background(), models.ImportFormatCSV, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !repository.readBeforeCopy {
+		t.Fatal("expected the body to be read before the repository import")
+	}
+	if report.Accepted != 1 || len(repository.imported) != 1 {
+		t.Fatalf("unexpected import %+v of %+v", report, repository.imported)
+	}
+}
+
+func TestImportMessagesCSVMissingHeader(t *testing.T) {
+	repository := &fakeImportMessagesRepository{}
+	service := NewImportMessagesService(repository, &fakeDueNotifier{})
+	_, err := service.ImportMessages(context.Background(), models.ImportFormatCSV, strings.NewReader("+905558889900,first message\n"))
+	if !errors.Is(err, models.ErrMalformedImport) {
+		t.Fatalf("expected malformed import error, got %v", err)
+	}
+}
+
+func TestImportMessagesNDJSON(t *testing.T) {
+	body := `{"phone_number": "+905558889900", "message_content": "first message"}` + "\n" +
+		"\n" +
+		`{"phone_number": "+905558889911", "message_content": ` + "\n" +
+		`{"phone_number": "+905558889922", "message_content": ""}` + "\n" +
+		`{"phone_number": "+905558889933", "message_content": "last message"}`
+	repository := &fakeImportMessagesRepository{}
+	service := NewImportMessagesService(repository, &fakeDueNotifier{})
+	report, err := service.ImportMessages(context.Background(), models.ImportFormatNDJSON, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Accepted != 2 || report.Rejected != 2 || len(repository.imported) != 2 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if report.Rows[1].Line != 3 || report.Rows[3].Line != 5 {
+		t.Fatalf("unexpected line numbers: %+v", report.Rows)
+	}
+}
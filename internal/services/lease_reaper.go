@@ -0,0 +1,45 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type leaseRepository interface {
+	ReleaseExpiredLeases(ctx context.Context, leaseTimeout time.Duration, maxAttempts int) (released int64, failed int64, err error)
+}
+
+// LeaseReaper periodically returns messages with an expired lease to the queue
+type LeaseReaper struct {
+	messageRepository leaseRepository
+	interval          time.Duration
+	leaseTimeout      time.Duration
+	maxAttempts       int
+}
+
+func NewLeaseReaper(messageRepository leaseRepository, interval, leaseTimeout time.Duration, maxAttempts int) *LeaseReaper {
+	return &LeaseReaper{
+		messageRepository: messageRepository,
+		interval:          interval,
+		leaseTimeout:      leaseTimeout,
+		maxAttempts:       maxAttempts,
+	}
+}
+
+// Run releases expired leases every interval until ctx is done
+func (r *LeaseReaper) Run(ctx context.Context) error {
+	if r.interval <= 0 {
+		return fmt.Errorf("lease reaper interval must be positive: %s", r.interval)
+	}
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			_, _, _ = r.messageRepository.ReleaseExpiredLeases(ctx, r.leaseTimeout, r.maxAttempts)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type leaseRelease struct {
+	leaseTimeout time.Duration
+	maxAttempts  int
+}
+
+type fakeLeaseRepository struct {
+	releases chan leaseRelease
+}
+
+func (r *fakeLeaseRepository) ReleaseExpiredLeases(ctx context.Context, leaseTimeout time.Duration, maxAttempts int) (int64, int64, error) {
+	select {
+	case r.releases <- leaseRelease{leaseTimeout: leaseTimeout, maxAttempts: maxAttempts}:
+	case <-ctx.Done():
+	}
+	return 0, 0, nil
+}
+
+func TestLeaseReaper(t *testing.T) {
+	repository := &fakeLeaseRepository{releases: make(chan leaseRelease)}
+	reaper := NewLeaseReaper(repository, time.Millisecond, time.Minute, 3)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- reaper.Run(ctx)
+	}()
+
+	for range 2 {
+		select {
+		case release := <-repository.releases:
+			if release.leaseTimeout != time.Minute || release.maxAttempts != 3 {
+				t.Fatalf("unexpected release %+v", release)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected the expired leases to be released every interval")
+		}
+	}
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("expected nil after the context is done, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the reaper to stop with the context")
+	}
+
+	err := NewLeaseReaper(repository, 0, time.Minute, 3).Run(context.Background())
+	if err == nil {
+		t.Fatal("expected an error for a zero interval")
+	}
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"auto-message-sender/internal/models"
+)
+
+type messageLookupRepository interface {
+	GetMessage(ctx context.Context, messageID string) (models.Message, error)
+	ListMessageAttempts(ctx context.Context, messageID string) ([]models.MessageAttempt, error)
+}
+
+type sentMessageCache interface {
+	Get(ctx context.Context, messageID string) (models.MessageSenderResponse, bool, error)
+}
+
+type messageDetailsCache interface {
+	GetMessage(ctx context.Context, messageID string) (models.MessageDetails, bool, error)
+	SetMessage(ctx context.Context, details models.MessageDetails) error
+}
+
+// MessageLookupService answers what happened to a single message. Lookups read
+// through the message cache, PostgreSQL is used when the cache misses or fails.
+type MessageLookupService struct {
+	messageRepository messageLookupRepository
+	sentMessageCache  sentMessageCache
+	messageCache      messageDetailsCache
+}
+
+func NewMessageLookupService(messageRepository messageLookupRepository, sentMessageCache sentMessageCache, messageCache messageDetailsCache) *MessageLookupService {
+	return &MessageLookupService{
+		messageRepository: messageRepository,
+		sentMessageCache:  sentMessageCache,
+		messageCache:      messageCache,
+	}
+}
+
+// GetMessage returns the message with its attempts and provider response,
+// ErrMessageNotFound is returned for an unknown message id. Only sent and failed
+// messages are cached, the others change with every attempt.
+func (s *MessageLookupService) GetMessage(ctx context.Context, messageID string) (models.MessageDetails, error) {
+	if !models.ValidMessageID(messageID) {
+		return models.MessageDetails{}, models.ErrMessageNotFound
+	}
+	// cache errors are reported by the cache logger, the lookup falls back to postgresql
+	details, found, err := s.messageCache.GetMessage(ctx, messageID)
+	if err == nil && found {
+		return details, nil
+	}
+	message, err := s.messageRepository.GetMessage(ctx, messageID)
+	if err != nil {
+		return models.MessageDetails{}, fmt.Errorf("messageRepository.GetMessage error: %w", err)
+	}
+	attempts, err := s.messageRepository.ListMessageAttempts(ctx, messageID)
+	if err != nil {
+		return models.MessageDetails{}, fmt.Errorf("messageRepository.ListMessageAttempts error: %w", err)
+	}
+	details = models.MessageDetails{
+		Message:          message,
+		Attempts:         attempts,
+		ProviderResponse: s.providerResponse(ctx, message),
+	}
+	if message.SendingStatus == "sent" || message.SendingStatus == "failed" {
+		_ = s.messageCache.SetMessage(ctx, details)
+	}
+	return details, nil
+}
+
+// providerResponse returns the cached response of the provider that accepted
+// message, it is rebuilt from the message once it left the sent message cache
+func (s *MessageLookupService) providerResponse(ctx context.Context, message models.Message) *models.MessageSenderResponse {
+	if message.ProviderMessageID == "" {
+		return nil
+	}
+	response, found, err := s.sentMessageCache.Get(ctx, message.ProviderMessageID)
+	if err == nil && found {
+		return &response
+	}
+	response = models.MessageSenderResponse{
+		Message:          "Accepted",
+		MessageID:        message.ProviderMessageID,
+		DeliveryStatus:   message.DeliveryStatus,
+		DeliveryStatusAt: message.DeliveryStatusAt,
+	}
+	if message.ProviderAcceptedAt != nil {
+		response.SentAt = message.ProviderAcceptedAt.UTC()
+	}
+	return &response
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"auto-message-sender/internal/models"
+)
+
+type fakeMessageCache struct {
+	snapshots map[string]models.MessageDetails
+	err       error
+}
+
+func newFakeMessageCache() *fakeMessageCache {
+	return &fakeMessageCache{
+		snapshots: make(map[string]models.MessageDetails),
+	}
+}
+
+func (c *fakeMessageCache) GetMessage(_ context.Context, messageID string) (models.MessageDetails, bool, error) {
+	if c.err != nil {
+		return models.MessageDetails{}, false, c.err
+	}
+	details, ok := c.snapshots[messageID]
+	return details, ok, nil
+}
+
+func (c *fakeMessageCache) SetMessage(_ context.Context, details models.MessageDetails) error {
+	c.snapshots[details.MessageID] = details
+	return nil
+}
+
+func (c *fakeMessageCache) DeleteMessage(_ context.Context, messageID string) error {
+	delete(c.snapshots, messageID)
+	return nil
+}
+
+type fakeMessageLookupRepository struct {
+	messages map[string]models.Message
+	attempts map[string][]models.MessageAttempt
+	lookups  int
+}
+
+func (r *fakeMessageLookupRepository) GetMessage(_ context.Context, messageID string) (models.Message, error) {
+	r.lookups++
+	message, ok := r.messages[messageID]
+	if !ok {
+		return models.Message{}, models.ErrMessageNotFound
+	}
+	return message, nil
+}
+
+func (r *fakeMessageLookupRepository) ListMessageAttempts(_ context.Context, messageID string) ([]models.MessageAttempt, error) {
+	return r.attempts[messageID], nil
+}
+
+type fakeSentMessageCache map[string]models.MessageSenderResponse
+
+func (c fakeSentMessageCache) Get(_ context.Context, messageID string) (models.MessageSenderResponse, bool, error) {
+	response, ok := c[messageID]
+	return response, ok, nil
+}
+
+func TestMessageLookupService(t *testing.T) {
+	const (
+		sentID    = "3f846a61-2e99-42f9-a9ab-1e6cf1703476"
+		evictedID = "31a9f1f5-1ea2-4f74-bf8d-bcf4e587a482"
+		waitingID = "9c0b6d64-5f0e-4b7e-a4a3-1f1d7e3c2a10"
+	)
+	acceptedAt := time.Date(2025, 11, 13, 10, 0, 0, 0, time.UTC)
+	repository := &fakeMessageLookupRepository{
+		messages: map[string]models.Message{
+			sentID:    {MessageID: sentID, SendingStatus: "sent", ProviderMessageID: "provider-1", ProviderAcceptedAt: &acceptedAt},
+			evictedID: {MessageID: evictedID, SendingStatus: "sent", ProviderMessageID: "provider-2", ProviderAcceptedAt: &acceptedAt, DeliveryStatus: models.DeliveryStatusDelivered},
+			waitingID: {MessageID: waitingID, SendingStatus: "waiting", LastError: "webhook response 503"},
+		},
+		attempts: map[string][]models.MessageAttempt{
+			sentID:    {{MessageID: sentID, AttemptNumber: 1, StatusCode: 202, ProviderMessageID: "provider-1"}},
+			waitingID: {{MessageID: waitingID, AttemptNumber: 1, StatusCode: 503, Error: "webhook response 503"}},
+		},
+	}
+	sentMessages := fakeSentMessageCache{
+		"provider-1": {Message: "Accepted", MessageID: "provider-1", SentAt: acceptedAt, DeliveryStatus: models.DeliveryStatusUndelivered},
+	}
+	messageCache := newFakeMessageCache()
+	service := NewMessageLookupService(repository, sentMessages, messageCache)
+	ctx := context.Background()
+
+	details, err := service.GetMessage(ctx, sentID)
+	if err != nil || len(details.Attempts) != 1 || details.ProviderResponse == nil || details.ProviderResponse.DeliveryStatus != models.DeliveryStatusUndelivered {
+		t.Fatalf("expected the sent message with the cached provider response, got %+v, %v", details, err)
+	}
+	_, err = service.GetMessage(ctx, sentID)
+	if err != nil || repository.lookups != 1 {
+		t.Fatalf("expected the second lookup to be served from the cache, %d lookups, %v", repository.lookups, err)
+	}
+
+	// the provider response is rebuilt from postgresql once it left the cache
+	details, err = service.GetMessage(ctx, evictedID)
+	if err != nil || details.ProviderResponse == nil || details.ProviderResponse.MessageID != "provider-2" ||
+		!details.ProviderResponse.SentAt.Equal(acceptedAt) || details.ProviderResponse.DeliveryStatus != models.DeliveryStatusDelivered {
+		t.Fatalf("expected the provider response from the message, got %+v, %v", details.ProviderResponse, err)
+	}
+
+	details, err = service.GetMessage(ctx, waitingID)
+	if err != nil || details.SendingStatus != "waiting" || details.ProviderResponse != nil || len(details.Attempts) != 1 {
+		t.Fatalf("unexpected waiting message %+v, %v", details, err)
+	}
+	if _, ok := messageCache.snapshots[waitingID]; ok {
+		t.Fatal("expected the waiting message not to be cached")
+	}
+
+	// a failing cache falls back to postgresql
+	messageCache.err = errors.New("redis unavailable")
+	details, err = service.GetMessage(ctx, sentID)
+	if err != nil || details.MessageID != sentID || repository.lookups != 4 {
+		t.Fatalf("expected the lookup to fall back to postgresql, %d lookups, %v", repository.lookups, err)
+	}
+
+	for _, messageID := range []string{"00000000-0000-0000-0000-000000000000", "not-a-uuid"} {
+		_, err = service.GetMessage(ctx, messageID)
+		if !errors.Is(err, models.ErrMessageNotFound) {
+			t.Fatalf("%s: expected ErrMessageNotFound, got %v", messageID, err)
+		}
+	}
+}
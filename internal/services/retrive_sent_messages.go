@@ -8,7 +8,7 @@ import (
 )
 
 type getListCache interface {
-	GetList(ctx context.Context) ([]models.MessageSenderResponse, error)
+	GetList(ctx context.Context, filter models.SentMessageFilter) (models.SentMessagePage, error)
 }
 
 type RetrieveSentMessagesService struct {
@@ -21,10 +21,15 @@ func NewRetrieveSentMessagesService(getListCache getListCache) *RetrieveSentMess
 	}
 }
 
-func (s *RetrieveSentMessagesService) RetrieveSentMessages(ctx context.Context) ([]models.MessageSenderResponse, error) {
-	messages, err := s.getListCache.GetList(ctx)
+// RetrieveSentMessages returns a page of the sent messages, newest first
+func (s *RetrieveSentMessagesService) RetrieveSentMessages(ctx context.Context, filter models.SentMessageFilter) (models.SentMessagePage, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = models.DefaultSentMessageLimit
+	}
+	filter.Limit = min(filter.Limit, models.MaxSentMessageLimit)
+	page, err := s.getListCache.GetList(ctx, filter)
 	if err != nil {
-		return nil, fmt.Errorf("getListCache.GetList error: %w", err)
+		return models.SentMessagePage{}, fmt.Errorf("getListCache.GetList error: %w", err)
 	}
-	return messages, nil
+	return page, nil
 }
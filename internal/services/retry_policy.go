@@ -0,0 +1,54 @@
+package services
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy decides what happens to a message after a failed send attempt.
+// Delays grow exponentially with the attempt number and are jittered so that
+// messages failed in the same batch are not retried at the same moment.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of send attempts, including the first one
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+func (p RetryPolicy) Validate() error {
+	if p.MaxAttempts < 1 {
+		return fmt.Errorf("retry max attempts must be at least 1: %d", p.MaxAttempts)
+	}
+	if p.BaseDelay <= 0 {
+		return fmt.Errorf("retry base delay must be positive: %s", p.BaseDelay)
+	}
+	if p.MaxDelay < p.BaseDelay {
+		return fmt.Errorf("retry max delay %s must not be less than base delay %s", p.MaxDelay, p.BaseDelay)
+	}
+	return nil
+}
+
+// Exhausted reports whether a message that failed its attempt-th attempt must
+// not be retried anymore
+func (p RetryPolicy) Exhausted(attempt int) bool {
+	return attempt >= p.MaxAttempts
+}
+
+// NextDelay returns the delay before the retry that follows the attempt-th
+// attempt. It is BaseDelay*2^(attempt-1) capped at MaxDelay, half of which is
+// randomized.
+func (p RetryPolicy) NextDelay(attempt int) time.Duration {
+	delay := p.MaxDelay
+	if attempt < 1 {
+		attempt = 1
+	}
+	// stop doubling before overflowing, the cap is reached long before that
+	if shift := attempt - 1; shift < 32 {
+		if exponential := p.BaseDelay << shift; exponential > 0 && exponential < p.MaxDelay {
+			delay = exponential
+		}
+	}
+	half := delay / 2
+	return half + rand.N(delay-half+1)
+}
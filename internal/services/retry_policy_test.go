@@ -0,0 +1,42 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyNextDelay(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts: 5,
+		BaseDelay:   10 * time.Second,
+		MaxDelay:    time.Minute,
+	}
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{attempt: 1, max: 10 * time.Second},
+		{attempt: 2, max: 20 * time.Second},
+		{attempt: 3, max: 40 * time.Second},
+		{attempt: 4, max: time.Minute},
+		{attempt: 100, max: time.Minute},
+	}
+	for _, tt := range tests {
+		for range 100 {
+			delay := policy.NextDelay(tt.attempt)
+			if delay < tt.max/2 || delay > tt.max {
+				t.Fatalf("attempt %d: delay %s out of range [%s, %s]", tt.attempt, delay, tt.max/2, tt.max)
+			}
+		}
+	}
+}
+
+func TestRetryPolicyExhausted(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Minute}
+	if policy.Exhausted(2) {
+		t.Fatal("attempt 2 of 3 must be retried")
+	}
+	if !policy.Exhausted(3) {
+		t.Fatal("attempt 3 of 3 must not be retried")
+	}
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"sync"
+
+	"auto-message-sender/internal/models"
+)
+
+// MessageResult is the result of processing a single message in a WorkerPool
+type MessageResult struct {
+	Message models.Message
+	Outcome sendOutcome
+	Err     error
+}
+
+// WorkerPool processes messages with at most concurrency messages in flight
+type WorkerPool struct {
+	concurrency int
+}
+
+func NewWorkerPool(concurrency int) *WorkerPool {
+	return &WorkerPool{
+		concurrency: max(concurrency, 1),
+	}
+}
+
+// Process calls process for every message and returns the results in the order
+// of messages. No new message is handed out once ctx is done or process returned
+// an error, messages in flight are finished. Messages that were not processed
+// are reported as aborted.
+func (p *WorkerPool) Process(
+	ctx context.Context,
+	messages []models.Message,
+	process func(ctx context.Context, message models.Message) (sendOutcome, error),
+) []MessageResult {
+	results := make([]MessageResult, len(messages))
+	for i, message := range messages {
+		results[i] = MessageResult{
+			Message: message,
+			Outcome: outcomeAborted,
+		}
+	}
+	indexes := make(chan int)
+	failed := make(chan struct{})
+	var failOnce sync.Once
+	var wg sync.WaitGroup
+	for range min(p.concurrency, len(messages)) {
+		wg.Go(func() {
+			for i := range indexes {
+				outcome, err := process(ctx, messages[i])
+				results[i].Outcome = outcome
+				results[i].Err = err
+				if err != nil {
+					failOnce.Do(func() { close(failed) })
+				}
+			}
+		})
+	}
+dispatch:
+	for i := range messages {
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case <-failed:
+			break dispatch
+		case indexes <- i:
+		}
+	}
+	close(indexes)
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"auto-message-sender/infra/sender"
+	"auto-message-sender/internal/models"
+	"auto-message-sender/internal/schedule"
+)
+
+func newTestMessages(n int) []models.Message {
+	messages := make([]models.Message, n)
+	for i := range messages {
+		messages[i] = models.Message{
+			MessageID:      strconv.Itoa(i),
+			PhoneNumber:    "+905551234567",
+			MessageContent: "hello",
+		}
+	}
+	return messages
+}
+
+func TestWorkerPoolBoundsConcurrency(t *testing.T) {
+	const concurrency = 4
+	var inFlight, maxInFlight atomic.Int64
+	results := NewWorkerPool(concurrency).Process(context.Background(), newTestMessages(50), func(_ context.Context, message models.Message) (sendOutcome, error) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			current := maxInFlight.Load()
+			if n <= current || maxInFlight.CompareAndSwap(current, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		if message.MessageID == "7" {
+			return outcomeFailed, nil
+		}
+		return outcomeSent, nil
+	})
+	if maxInFlight.Load() > concurrency {
+		t.Fatalf("expected at most %d messages in flight, got %d", concurrency, maxInFlight.Load())
+	}
+	for i, result := range results {
+		if result.Message.MessageID != strconv.Itoa(i) {
+			t.Fatalf("result %d: unexpected message %s", i, result.Message.MessageID)
+		}
+		expected := outcomeSent
+		if i == 7 {
+			expected = outcomeFailed
+		}
+		if result.Outcome != expected || result.Err != nil {
+			t.Fatalf("result %d: unexpected outcome %v (%v)", i, result.Outcome, result.Err)
+		}
+	}
+}
+
+func TestWorkerPoolStopsHandingOutMessages(t *testing.T) {
+	tests := []struct {
+		name   string
+		cancel bool
+		err    error
+	}{
+		{name: "context cancelled", cancel: true},
+		{name: "process error", err: errors.New("repository unavailable")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			var processed atomic.Int64
+			results := NewWorkerPool(2).Process(ctx, newTestMessages(100), func(_ context.Context, message models.Message) (sendOutcome, error) {
+				if processed.Add(1) == 5 {
+					if tt.cancel {
+						cancel()
+					}
+					return outcomeAborted, tt.err
+				}
+				return outcomeSent, nil
+			})
+			aborted := 0
+			for _, result := range results {
+				if result.Outcome == outcomeAborted {
+					aborted++
+				}
+			}
+			if processed.Load() > 10 {
+				t.Fatalf("expected the pool to stop early, processed %d", processed.Load())
+			}
+			if aborted != len(results)-int(processed.Load())+1 {
+				t.Fatalf("expected unprocessed messages to be aborted, got %d aborted of %d processed", aborted, processed.Load())
+			}
+		})
+	}
+}
+
+func BenchmarkAutoMessageSenderConcurrency(b *testing.B) {
+	const latency = 5 * time.Millisecond
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(latency)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		_ = json.NewEncoder(w).Encode(map[string]string{"message": "Accepted", "messageId": "provider-message-id"})
+	}))
+	defer server.Close()
+	webhookMessageSender := sender.NewWebhookMessageSender(server.URL)
+
+	const batchSize = 100
+	for _, concurrency := range []int{1, 8, 32} {
+		b.Run(fmt.Sprintf("concurrency=%d", concurrency), func(b *testing.B) {
+			for b.Loop() {
+				b.StopTimer()
+				repository := newFakeMessageRepository(newTestMessages(batchSize)...)
+				autoMessageSender := NewAutoMessageSender(repository, webhookMessageSender, &fakeSetCache{}, models.SenderConfig{
+					InitialDelay: time.Second,
+					Interval:     time.Minute,
+					BatchSize:    batchSize,
+					Concurrency:  concurrency,
+				}, RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Minute}, &schedule.Schedule{})
+				b.StartTimer()
+
+				var result models.BatchResult
+				err := autoMessageSender.sendMessages(context.Background(), &result)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if result.Sent != batchSize {
+					b.Fatalf("expected %d sent messages, got %+v", batchSize, result)
+				}
+			}
+			b.ReportMetric(float64(batchSize*b.N)/b.Elapsed().Seconds(), "msgs/s")
+		})
+	}
+}